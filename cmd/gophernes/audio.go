@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/audio"
+	"github.com/sirupsen/logrus"
+	"github.com/tomnz/gophernes"
+	"github.com/tomnz/gophernes/internal/apu"
+)
+
+const glitchReportInterval = 5 * time.Second
+
+// playAudio streams the console's audio output through an ebiten audio player.
+func playAudio(console *gophernes.Console, volume float64) error {
+	context, err := audio.NewContext(apu.DefaultSampleRate)
+	if err != nil {
+		return err
+	}
+	player, err := audio.NewPlayer(context, &stereoStream{src: console.Audio()})
+	if err != nil {
+		return err
+	}
+	player.SetVolume(volume)
+	if err := player.Play(); err != nil {
+		return err
+	}
+
+	go reportGlitches(console.Audio())
+	return nil
+}
+
+// reportGlitches periodically logs any audio buffer underruns or overruns.
+func reportGlitches(buf gophernes.AudioBuffer) {
+	var underruns, overruns uint64
+	for range time.Tick(glitchReportInterval) {
+		currUnderruns, currOverruns := buf.Underruns(), buf.Overruns()
+		if currUnderruns != underruns || currOverruns != overruns {
+			logrus.Warnf(
+				"Audio glitches in the last %s: %d underruns, %d overruns",
+				glitchReportInterval,
+				currUnderruns-underruns,
+				currOverruns-overruns,
+			)
+		}
+		underruns, overruns = currUnderruns, currOverruns
+	}
+}
+
+// stereoStream converts the mono APU output into the 16-bit stereo stream expected by ebiten.
+// Underruns are padded by holding the last sample, to avoid popping.
+type stereoStream struct {
+	src  io.Reader
+	mono []byte
+	last [2]byte
+}
+
+func (s *stereoStream) Read(out []byte) (int, error) {
+	samples := len(out) / 4
+	if cap(s.mono) < samples*2 {
+		s.mono = make([]byte, samples*2)
+	}
+	mono := s.mono[:samples*2]
+	n, err := s.src.Read(mono)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < samples; i++ {
+		if i*2+1 < n {
+			s.last[0], s.last[1] = mono[i*2], mono[i*2+1]
+		}
+		copy(out[i*4:], s.last[:])
+		copy(out[i*4+2:], s.last[:])
+	}
+	return samples * 4, nil
+}
+
+func (s *stereoStream) Close() error {
+	return nil
+}
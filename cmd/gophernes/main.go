@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"image"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/hajimehoshi/ebiten"
 	"github.com/sirupsen/logrus"
@@ -18,11 +24,28 @@ import (
 )
 
 var (
-	rom      = flag.String("rom", "", "ROM file to load")
-	cycles   = flag.Uint64("cycles", 0, "If non-zero, run for a limited number of master clock cycles")
-	frames   = flag.Uint64("frames", 0, "If non-zero, run for a limited number of frames")
-	rate     = flag.Float64("rate", 1.0, "Emulation rate - 1.0 runs at normal speed or slower, 0 runs without any delays")
-	headless = flag.Bool("headless", false, "If true, don't launch a graphical window")
+	rom       = flag.String("rom", "", "ROM file to load")
+	nsf       = flag.String("nsf", "", "NSF music file to play, instead of a ROM")
+	track     = flag.Int("track", 0, "If non-zero, the NSF track to play, starting from 1")
+	tracks    = flag.Bool("tracks", false, "If true, list the tracks in the NSF file and exit")
+	advance   = flag.Bool("advance", false, "If true, advance through the NSF playlist as each track finishes")
+	fds       = flag.String("fds", "", "FDS disk image to load, instead of a ROM - requires -bios")
+	bios      = flag.String("bios", "", "FDS BIOS ROM image")
+	save      = flag.String("save", "", "File to load the cartridge's save data from, such as an EEPROM's contents, and write it to on exit")
+	side      = flag.Int("side", 0, "If non-zero, the FDS disk side to insert, starting from 1")
+	length    = flag.Duration("length", 150*time.Second, "When advancing, how long to play NSF tracks that don't specify a length - 0 plays them indefinitely")
+	cycles    = flag.Uint64("cycles", 0, "If non-zero, run for a limited number of master clock cycles")
+	frames    = flag.Uint64("frames", 0, "If non-zero, run for a limited number of frames")
+	rate      = flag.Float64("rate", 1.0, "Emulation rate - 1.0 runs at normal speed or slower, 0 runs without any delays")
+	headless  = flag.Bool("headless", false, "If true, don't launch a graphical window")
+	wav       = flag.String("wav", "", "In headless mode, write the audio output to this WAV file - requires -frames or -cycles")
+	wavch     = flag.String("wavch", "", "With -wav, comma-separated list of APU channels to also write to their own WAV files, alongside the mixed output")
+	mute      = flag.Bool("mute", false, "If true, don't play any audio")
+	volume    = flag.Float64("volume", 1.0, "Audio volume, from 0.0 to 1.0")
+	audiosync = flag.Bool("audiosync", true, "If true and audio is playing, pace emulation against the audio device instead of the wall clock")
+
+	mutech = flag.String("mutech", "", "Comma-separated list of APU channels to mute (pulse1, pulse2, triangle, noise, dmc)")
+	solo   = flag.String("solo", "", "Comma-separated list of APU channels to solo (pulse1, pulse2, triangle, noise, dmc)")
 
 	cputrace = flag.Bool("cputrace", false, "Include the CPU trace")
 	pputrace = flag.Bool("pputrace", false, "Include the PPU trace")
@@ -35,9 +58,46 @@ var (
 var (
 	lastFrame   *image.RGBA
 	lastFrameMu sync.Mutex
+
+	joypad = gophernes.NewJoypad()
+
+	// Disk side flipping, when using the disk system
+	diskConsole *gophernes.Console
+	diskSide    int
+	flipPressed bool
 )
 
+// flipKey ejects the disk, and inserts the next side shortly after.
+const flipKey = ebiten.KeyF
+
+var keyMap = map[ebiten.Key]gophernes.Buttons{
+	ebiten.KeyX:     gophernes.ButtonA,
+	ebiten.KeyZ:     gophernes.ButtonB,
+	ebiten.KeyShift: gophernes.ButtonSelect,
+	ebiten.KeyEnter: gophernes.ButtonStart,
+	ebiten.KeyUp:    gophernes.ButtonUp,
+	ebiten.KeyDown:  gophernes.ButtonDown,
+	ebiten.KeyLeft:  gophernes.ButtonLeft,
+	ebiten.KeyRight: gophernes.ButtonRight,
+}
+
 func update(screen *ebiten.Image) error {
+	var buttons gophernes.Buttons
+	for key, button := range keyMap {
+		if ebiten.IsKeyPressed(key) {
+			buttons |= button
+		}
+	}
+	joypad.SetButtons(buttons)
+
+	if diskConsole != nil {
+		pressed := ebiten.IsKeyPressed(flipKey)
+		if pressed && !flipPressed {
+			flipDisk()
+		}
+		flipPressed = pressed
+	}
+
 	if ebiten.IsRunningSlowly() {
 		return nil
 	}
@@ -50,6 +110,22 @@ func update(screen *ebiten.Image) error {
 	return nil
 }
 
+// flipDisk ejects the current disk side, then inserts the next one after a delay, so that games
+// notice the change.
+func flipDisk() {
+	diskSide = (diskSide + 1) % diskConsole.DiskSides()
+	if err := diskConsole.EjectDisk(); err != nil {
+		logrus.Error(err)
+		return
+	}
+	side := diskSide
+	time.AfterFunc(time.Second, func() {
+		if err := diskConsole.InsertDisk(side); err != nil {
+			logrus.Error(err)
+		}
+	})
+}
+
 func draw(frame *image.RGBA) {
 	lastFrameMu.Lock()
 	defer lastFrameMu.Unlock()
@@ -58,12 +134,21 @@ func draw(frame *image.RGBA) {
 
 func main() {
 	flag.Parse()
-	if *rom == "" {
-		logrus.Fatalf("Must specify rom file!")
+	if *rom == "" && *nsf == "" && *fds == "" {
+		logrus.Fatalf("Must specify rom, nsf or fds file!")
 	}
-	romFile, err := os.Open(*rom)
+	if *volume < 0 || *volume > 1 {
+		logrus.Fatalf("Volume must be from 0.0 to 1.0, got %v", *volume)
+	}
+	path := *rom
+	if *nsf != "" {
+		path = *nsf
+	} else if *fds != "" {
+		path = *fds
+	}
+	romFile, err := os.Open(path)
 	if os.IsNotExist(err) {
-		logrus.Fatalf("ROM file not found: %q", *rom)
+		logrus.Fatalf("File not found: %q", path)
 	}
 
 	if *cpuprofile != "" {
@@ -85,11 +170,15 @@ func main() {
 	}
 	apuopts := []apu.Option{
 		apu.WithTrace(*aputrace),
+		apu.WithMute(parseChannels(*mutech)...),
+		apu.WithSolo(parseChannels(*solo)...),
 	}
 
 	logrus.SetLevel(logrus.DebugLevel)
 
-	if *headless {
+	if *tracks {
+		listTracks(romFile)
+	} else if *headless {
 		runHeadless(romFile, cpuopts, ppuopts, apuopts)
 	} else {
 		run(romFile, cpuopts, ppuopts, apuopts)
@@ -109,17 +198,36 @@ func main() {
 }
 
 func run(romFile io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option) {
-	console, err := gophernes.NewConsole(
+	pacing := gophernes.PacingSleep
+	if !*mute && *audiosync {
+		pacing = gophernes.PacingAudio
+	}
+
+	console, err := newConsole(
 		romFile,
 		cpuopts,
 		ppuopts,
 		apuopts,
 		gophernes.WithRate(*rate),
+		gophernes.WithPacing(pacing),
 		gophernes.WithDraw(draw),
+		gophernes.WithInputDevice(gophernes.Port1, joypad),
 	)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	if *fds != "" {
+		diskConsole = console
+		if *side > 1 {
+			diskSide = *side - 1
+		}
+	}
+
+	if !*mute {
+		if err := playAudio(console, *volume); err != nil {
+			logrus.Fatalf("Could not start audio: %s", err)
+		}
+	}
 
 	go func(console *gophernes.Console) {
 		if *frames != 0 {
@@ -134,10 +242,33 @@ func run(romFile io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts
 	if err := ebiten.Run(update, ppu.DisplayWidth, ppu.DisplayHeight, 1, "NES"); err != nil {
 		logrus.Fatal(err)
 	}
+	writeSave(console)
 }
 
 func runHeadless(romFile io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option) {
-	console, err := gophernes.NewConsole(
+	var recorders []*wavWriter
+	if *wav != "" {
+		if *frames == 0 && *cycles == 0 {
+			logrus.Fatalf("Must specify -frames or -cycles when writing a WAV file")
+		}
+		wavFile, recorder := createWAV(*wav)
+		defer wavFile.Close()
+		recorders = append(recorders, recorder)
+		apuopts = append(apuopts, apu.WithRecorder(recorder))
+
+		// Each channel is written next to the mixed output, such as out.pulse1.wav for out.wav
+		ext := filepath.Ext(*wav)
+		for _, channel := range parseChannels(*wavch) {
+			wavFile, recorder := createWAV(fmt.Sprintf("%s.%s%s", strings.TrimSuffix(*wav, ext), channel, ext))
+			defer wavFile.Close()
+			recorders = append(recorders, recorder)
+			apuopts = append(apuopts, apu.WithChannelRecorder(channel, recorder))
+		}
+	} else if *wavch != "" {
+		logrus.Fatalf("Must specify -wav when writing channel WAV files")
+	}
+
+	console, err := newConsole(
 		romFile,
 		cpuopts,
 		ppuopts,
@@ -155,4 +286,134 @@ func runHeadless(romFile io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option,
 	} else {
 		console.Run()
 	}
+
+	for _, recorder := range recorders {
+		if err := recorder.Close(); err != nil {
+			logrus.Fatalf("Could not write WAV file: %s", err)
+		}
+	}
+	writeSave(console)
+}
+
+// createWAV creates a WAV file at the given path, at the APU's sample rate. The file must be closed
+// after the writer.
+func createWAV(path string) (*os.File, *wavWriter) {
+	wavFile, err := os.Create(path)
+	if err != nil {
+		logrus.Fatalf("Could not create WAV file: %q", path)
+	}
+	recorder, err := newWAVWriter(wavFile, apu.DefaultSampleRate)
+	if err != nil {
+		logrus.Fatalf("Could not write WAV file: %s", err)
+	}
+	return wavFile, recorder
+}
+
+// writeSave writes the cartridge's save data to the save file, if one was given.
+func writeSave(console *gophernes.Console) {
+	if *save == "" {
+		return
+	}
+	data := console.SaveData()
+	if data == nil {
+		return
+	}
+	if err := ioutil.WriteFile(*save, data, 0644); err != nil {
+		logrus.Errorf("Could not write save file: %s", err)
+	}
+}
+
+// newConsole initializes a console for the ROM, NSF or FDS file, depending on the flags.
+func newConsole(file io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option, opts ...gophernes.Option) (*gophernes.Console, error) {
+	if *fds != "" {
+		return newFDSConsole(file, cpuopts, ppuopts, apuopts, opts...)
+	}
+	if *nsf == "" {
+		if *save != "" {
+			data, err := ioutil.ReadFile(*save)
+			if err == nil {
+				opts = append(opts, gophernes.WithSaveData(data))
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		return gophernes.NewConsole(file, cpuopts, ppuopts, apuopts, opts...)
+	}
+
+	if *advance {
+		opts = append(opts, gophernes.WithAutoAdvance(*length))
+	}
+	console, err := gophernes.NewNSFConsole(file, cpuopts, ppuopts, apuopts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if *track != 0 {
+		if err := console.SelectTrack(*track - 1); err != nil {
+			return nil, err
+		}
+	}
+	return console, nil
+}
+
+func newFDSConsole(file io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option, opts ...gophernes.Option) (*gophernes.Console, error) {
+	if *bios == "" {
+		return nil, errors.New("must specify -bios to load an FDS image")
+	}
+	biosFile, err := os.Open(*bios)
+	if err != nil {
+		return nil, err
+	}
+	defer biosFile.Close()
+
+	console, err := gophernes.NewFDSConsole(file, biosFile, cpuopts, ppuopts, apuopts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if *side > 1 {
+		if err := console.InsertDisk(*side - 1); err != nil {
+			return nil, err
+		}
+	}
+	return console, nil
+}
+
+// listTracks prints the metadata and tracks for an NSF file.
+func listTracks(file io.Reader) {
+	if *nsf == "" {
+		logrus.Fatalf("Must specify nsf file to list tracks")
+	}
+	console, err := gophernes.NewNSFConsole(file, nil, nil, nil)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	info := console.NSFInfo()
+	fmt.Printf("%s - %s (%s)\n", info.Title, info.Artist, info.Copyright)
+	for _, i := range info.Playlist {
+		track := info.Tracks[i]
+		name := track.Name
+		if name == "" {
+			name = fmt.Sprintf("Track %d", i+1)
+		}
+		if track.Duration == 0 {
+			fmt.Printf("%3d  %s\n", i+1, name)
+		} else {
+			fmt.Printf("%3d  %s [%s]\n", i+1, name, track.Duration)
+		}
+	}
+}
+
+func parseChannels(names string) []apu.Channel {
+	if names == "" {
+		return nil
+	}
+	var channels []apu.Channel
+	for _, name := range strings.Split(names, ",") {
+		channel, err := apu.ParseChannel(strings.TrimSpace(name))
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		channels = append(channels, channel)
+	}
+	return channels
 }
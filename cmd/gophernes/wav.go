@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// wavHeader is the canonical 44-byte header for a PCM WAV file.
+// http://soundfile.sapp.org/doc/WaveFormat/
+type wavHeader struct {
+	ChunkID       [4]byte
+	ChunkSize     uint32
+	Format        [4]byte
+	Subchunk1ID   [4]byte
+	Subchunk1Size uint32
+	AudioFormat   uint16
+	NumChannels   uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Subchunk2ID   [4]byte
+	Subchunk2Size uint32
+}
+
+const wavHeaderSize = 44
+
+// newWAVWriter writes a WAV file containing signed 16-bit mono PCM at the given sample rate.
+// Close must be called to finalize the header once all samples have been written.
+func newWAVWriter(file io.WriteSeeker, sampleRate uint32) (*wavWriter, error) {
+	w := &wavWriter{
+		file:       file,
+		buf:        bufio.NewWriter(file),
+		sampleRate: sampleRate,
+	}
+	// Sizes are unknown until closed, so write a placeholder
+	if err := w.writeHeader(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+type wavWriter struct {
+	file       io.WriteSeeker
+	buf        *bufio.Writer
+	sampleRate uint32
+	dataSize   uint32
+	// err holds the first write error, which is reported on Close
+	err error
+}
+
+func (w *wavWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.buf.Write(p)
+	w.dataSize += uint32(n)
+	w.err = err
+	return n, err
+}
+
+func (w *wavWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.writeHeader()
+}
+
+func (w *wavWriter) writeHeader() error {
+	header := wavHeader{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     wavHeaderSize - 8 + w.dataSize,
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   1,
+		NumChannels:   1,
+		SampleRate:    w.sampleRate,
+		ByteRate:      w.sampleRate * 2,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: w.dataSize,
+	}
+	if err := binary.Write(w.buf, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
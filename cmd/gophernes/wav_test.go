@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// testFile is an in-memory io.WriteSeeker.
+type testFile struct {
+	data []byte
+	pos  int
+}
+
+func (f *testFile) Write(p []byte) (int, error) {
+	if end := f.pos + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	n := copy(f.data[f.pos:], p)
+	f.pos += n
+	return n, nil
+}
+
+func (f *testFile) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("unsupported whence")
+	}
+	f.pos = int(offset)
+	return offset, nil
+}
+
+func TestWAVWriter(t *testing.T) {
+	file := &testFile{}
+	w, err := newWAVWriter(file, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := []byte{0x01, 0x00, 0xFF, 0x7F, 0x00, 0x80}
+	if _, err := w.Write(samples[:4]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(samples[4:]); err != nil {
+		t.Fatal(err)
+	}
+	// Sizes are placeholders until closed
+	if size := binary.LittleEndian.Uint32(file.data[40:]); size != 0 {
+		t.Errorf("expected placeholder data size before Close, got %d", size)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.data) != wavHeaderSize+len(samples) {
+		t.Fatalf("expected %d bytes, got %d", wavHeaderSize+len(samples), len(file.data))
+	}
+	var header wavHeader
+	if err := binary.Read(bytes.NewReader(file.data), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	expected := wavHeader{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     36 + uint32(len(samples)),
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   1,
+		NumChannels:   1,
+		SampleRate:    44100,
+		ByteRate:      88200,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: uint32(len(samples)),
+	}
+	if header != expected {
+		t.Errorf("expected header %+v, got %+v", expected, header)
+	}
+	if !bytes.Equal(file.data[wavHeaderSize:], samples) {
+		t.Errorf("expected samples %v, got %v", samples, file.data[wavHeaderSize:])
+	}
+}
@@ -1,11 +1,21 @@
 package gophernes
 
-import "image"
+import (
+	"image"
+	"time"
+)
 
 type config struct {
 	rate    float64
+	pacing  Pacing
 	palette Palette
 	draw    func(*image.RGBA)
+	inputs  [2]InputDevice
+	// saveData is loaded into the cartridge's save storage
+	saveData []byte
+
+	autoAdvance     bool
+	defaultDuration time.Duration
 }
 
 func defaultConfig() *config {
@@ -15,6 +25,26 @@ func defaultConfig() *config {
 	}
 }
 
+func newConfig(opts ...Option) *config {
+	config := defaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// Pacing determines how emulation speed is regulated.
+type Pacing int
+
+const (
+	// PacingSleep sleeps after each frame to keep up with the wall clock, at the configured rate.
+	PacingSleep Pacing = iota
+	// PacingAudio blocks while the audio buffer is full, and adjusts the audio sample rate
+	// slightly to keep the buffer near a target fill level. The configured rate is ignored, and
+	// the audio buffer must be continuously read or emulation will stall.
+	PacingAudio
+)
+
 type Option func(*config)
 
 func WithRate(rate float64) Option {
@@ -23,6 +53,12 @@ func WithRate(rate float64) Option {
 	}
 }
 
+func WithPacing(pacing Pacing) Option {
+	return func(config *config) {
+		config.pacing = pacing
+	}
+}
+
 func WithPalette(palette Palette) Option {
 	return func(config *config) {
 		config.palette = palette
@@ -34,3 +70,28 @@ func WithDraw(draw func(rgba *image.RGBA)) Option {
 		config.draw = draw
 	}
 }
+
+// WithInputDevice connects the given device to one of the controller ports.
+func WithInputDevice(port Port, device InputDevice) Option {
+	return func(config *config) {
+		config.inputs[port] = device
+	}
+}
+
+// WithSaveData loads the given contents into the cartridge's save storage, as previously returned
+// by Console.SaveData.
+func WithSaveData(data []byte) Option {
+	return func(config *config) {
+		config.saveData = data
+	}
+}
+
+// WithAutoAdvance moves on to the next track in an NSF file's playlist once the current track
+// (including its fade out) has finished. Tracks without an authored duration play for the given
+// default duration, or indefinitely if it is 0. Output is silenced after the last track.
+func WithAutoAdvance(defaultDuration time.Duration) Option {
+	return func(config *config) {
+		config.autoAdvance = true
+		config.defaultDuration = defaultDuration
+	}
+}
@@ -1,7 +1,11 @@
 package gophernes
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"sync/atomic"
 
 	"github.com/tomnz/gophernes/internal/apu"
 	"github.com/tomnz/gophernes/internal/cartridge"
@@ -20,45 +24,268 @@ type Console struct {
 	apu       *apu.APU
 	img       *image.RGBA
 	cartridge cartridge.Cartridge
+	// clocked is set if the cartridge has hardware driven by the CPU clock
+	clocked cartridge.Clocked
+	// interrupter is set if the cartridge can raise IRQs
+	interrupter cartridge.Interrupter
+	// renderObserver is set if the cartridge tracks the PPU's rendering
+	renderObserver cartridge.RenderObserver
+	ports          [2]controllerPort
+
+	// NSF player state
+	nsf   *cartridge.NSF
+	info  *NSFInfo
+	track int
+	// playlistPos is the position of the current track in the playlist, or -1 if it isn't in it
+	playlistPos int
+	// trackStart is the CPU cycle that the current track started playing at
+	trackStart uint64
+
+	// Disk system state
+	fds *cartridge.FDS
+	// diskRequest holds a pending disk change, which is applied between frames
+	diskRequest int32
 }
 
+const (
+	noDiskRequest    = -2
+	ejectDiskRequest = -1
+)
+
 const (
 	internalRAMSize uint16 = 0x800
 	frameTime              = 1.0 / 60
+	cpuClockRate           = 1789773
+
+	// Dynamic rate control parameters for audio pacing
+	audioTargetLatency = 60 * time.Millisecond
+	audioMaxRateDelta  = 0.005
 )
 
 // NewConsole initializes a new console.
 func NewConsole(rom io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option, opts ...Option) (*Console, error) {
-	config := defaultConfig()
-	for _, opt := range opts {
-		opt(config)
+	config := newConfig(opts...)
+	cartridge, err := loadINES(rom, config.saveData)
+	if err != nil {
+		return nil, err
+	}
+
+	console := newConsole(cartridge, config, cpuopts, ppuopts, apuopts)
+	console.reset()
+	return console, nil
+}
+
+// NewNSFConsole initializes a new console that plays the given NSF music file, starting at the
+// file's default track.
+func NewNSFConsole(nsf io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option, opts ...Option) (*Console, error) {
+	file, err := loadNSF(nsf)
+	if err != nil {
+		return nil, err
 	}
 
-	cartridge, err := loadINES(rom)
+	console := newConsole(file.cartridge, newConfig(opts...), cpuopts, ppuopts, apuopts)
+	console.nsf = file.cartridge
+	console.info = file.info
+	console.ppu.Reset()
+	if err := console.SelectTrack(file.startingTrack); err != nil {
+		return nil, err
+	}
+	return console, nil
+}
+
+// NewFDSConsole initializes a new console with the Famicom Disk System, using the given BIOS ROM
+// image. The first side of the disk is inserted.
+func NewFDSConsole(disk io.Reader, bios io.Reader, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option, opts ...Option) (*Console, error) {
+	sides, err := loadFDS(disk)
+	if err != nil {
+		return nil, err
+	}
+	biosData, err := ioutil.ReadAll(bios)
 	if err != nil {
 		return nil, err
 	}
+	fds, err := cartridge.NewFDS(biosData, sides)
+	if err != nil {
+		return nil, err
+	}
+	if err := fds.InsertDisk(0); err != nil {
+		return nil, err
+	}
 
+	console := newConsole(fds, newConfig(opts...), cpuopts, ppuopts, apuopts)
+	console.fds = fds
+	console.reset()
+	return console, nil
+}
+
+func newConsole(cart cartridge.Cartridge, config *config, cpuopts []cpu.Option, ppuopts []ppu.Option, apuopts []apu.Option) *Console {
 	console := &Console{
-		config:    config,
-		ram:       make([]byte, internalRAMSize),
-		img:       image.NewRGBA(image.Rect(0, 0, ppu.DisplayWidth, ppu.DisplayHeight)),
-		cartridge: cartridge,
+		config:      config,
+		ram:         make([]byte, internalRAMSize),
+		img:         image.NewRGBA(image.Rect(0, 0, ppu.DisplayWidth, ppu.DisplayHeight)),
+		cartridge:   cart,
+		diskRequest: noDiskRequest,
+	}
+	if clocked, ok := cart.(cartridge.Clocked); ok {
+		console.clocked = clocked
+	}
+	if interrupter, ok := cart.(cartridge.Interrupter); ok {
+		console.interrupter = interrupter
+	}
+	if renderObserver, ok := cart.(cartridge.RenderObserver); ok {
+		console.renderObserver = renderObserver
+	}
+	if audio, ok := cart.(cartridge.Audio); ok {
+		// Copy the options to avoid modifying the caller's slice
+		apuopts = append(apuopts[:len(apuopts):len(apuopts)], apu.WithExpansionAudio(audio))
+	}
+	for i, device := range config.inputs {
+		console.ports[i].device = device
 	}
 
-	cpu := cpu.NewCPU(&cpuMemory{console}, cpuopts...)
-	ppu := ppu.NewPPU(&ppuMemory{console}, ppuopts...)
-	apu := apu.NewAPU(cpu, apuopts...)
+	console.cpu = cpu.NewCPU(&cpuMemory{console}, cpuopts...)
+	console.ppu = ppu.NewPPU(&ppuMemory{console}, ppuopts...)
+	console.apu = apu.NewAPU(console.cpu, &cpuMemory{console}, apuopts...)
 
-	cpu.Reset()
-	ppu.Reset()
-	apu.Reset()
+	return console
+}
 
-	console.cpu = cpu
-	console.ppu = ppu
-	console.apu = apu
+func (c *Console) reset() {
+	c.cpu.Reset()
+	c.ppu.Reset()
+	c.apu.Reset()
+}
 
-	return console, nil
+// SaveData returns the contents of the cartridge's save storage, such as a serial EEPROM, or nil if
+// it has none. The contents can be restored with WithSaveData.
+func (c *Console) SaveData() []byte {
+	if saver, ok := c.cartridge.(cartridge.Saver); ok {
+		return saver.SaveData()
+	}
+	return nil
+}
+
+// Tracks returns the number of tracks available when playing an NSF file, or 0 otherwise.
+func (c *Console) Tracks() int {
+	if c.info == nil {
+		return 0
+	}
+	return len(c.info.Tracks)
+}
+
+// NSFInfo returns the metadata for the NSF file being played, or nil if playing a ROM.
+func (c *Console) NSFInfo() *NSFInfo {
+	return c.info
+}
+
+// Track returns the (zero-based) NSF track currently playing.
+func (c *Console) Track() int {
+	return c.track
+}
+
+// SelectTrack resets the console to begin playing the given (zero-based) track from an NSF file.
+// This must not be called while the console is running.
+func (c *Console) SelectTrack(track int) error {
+	if c.nsf == nil {
+		return errors.New("not playing an NSF file")
+	}
+	if track < 0 || track >= c.Tracks() {
+		return fmt.Errorf("track %d out of range, NSF has %d tracks", track, c.Tracks())
+	}
+
+	c.nsf.SetTrack(byte(track))
+	for i := range c.ram {
+		c.ram[i] = 0
+	}
+	// The PPU is unused, so is left running to keep the frame count consistent
+	c.cpu.Reset()
+	c.apu.Reset()
+	c.apu.SetGain(1)
+	c.track = track
+	c.playlistPos = -1
+	for i, entry := range c.info.Playlist {
+		if entry == track {
+			c.playlistPos = i
+			break
+		}
+	}
+	c.trackStart = c.cpu.Cycles()
+	return nil
+}
+
+// DiskSides returns the number of disk sides available when using the Famicom Disk System, or 0
+// otherwise.
+func (c *Console) DiskSides() int {
+	if c.fds == nil {
+		return 0
+	}
+	return c.fds.Sides()
+}
+
+// InsertDisk inserts the given (zero-based) disk side. Games usually expect the previous disk to
+// be ejected for a short time first. The change is applied at the next frame, so this is safe to
+// call while the console is running.
+func (c *Console) InsertDisk(side int) error {
+	if c.fds == nil {
+		return errors.New("not using the disk system")
+	}
+	if side < 0 || side >= c.fds.Sides() {
+		return fmt.Errorf("disk side %d out of range, image has %d sides", side, c.fds.Sides())
+	}
+	atomic.StoreInt32(&c.diskRequest, int32(side))
+	return nil
+}
+
+// EjectDisk removes the disk. The change is applied at the next frame, so this is safe to call
+// while the console is running.
+func (c *Console) EjectDisk() error {
+	if c.fds == nil {
+		return errors.New("not using the disk system")
+	}
+	atomic.StoreInt32(&c.diskRequest, ejectDiskRequest)
+	return nil
+}
+
+// applyDiskRequest carries out any pending disk change.
+func (c *Console) applyDiskRequest() {
+	switch request := atomic.SwapInt32(&c.diskRequest, noDiskRequest); request {
+	case noDiskRequest:
+	case ejectDiskRequest:
+		c.fds.EjectDisk()
+	default:
+		c.fds.InsertDisk(int(request))
+	}
+}
+
+// advanceTrack fades out the current NSF track once its duration has elapsed, then moves on to
+// the next track in the playlist.
+func (c *Console) advanceTrack() {
+	duration := c.info.Tracks[c.track].Duration
+	if duration == 0 {
+		duration = c.config.defaultDuration
+	}
+	if duration == 0 {
+		return
+	}
+	elapsed := time.Duration(float64(c.cpu.Cycles()-c.trackStart) / cpuClockRate * float64(time.Second))
+	if elapsed < duration {
+		return
+	}
+
+	fade := c.info.Tracks[c.track].Fade
+	if elapsed < duration+fade {
+		c.apu.SetGain(1 - float64(elapsed-duration)/float64(fade))
+		return
+	}
+
+	next := c.playlistPos + 1
+	if c.playlistPos < 0 || next >= len(c.info.Playlist) {
+		// Reached the end of the playlist
+		c.apu.SetGain(0)
+		return
+	}
+	c.SelectTrack(c.info.Playlist[next])
+	c.playlistPos = next
 }
 
 const (
@@ -69,15 +296,16 @@ const (
 
 func (c *Console) Run() {
 	startTime := time.Now()
+	startFrames := c.ppu.Frames()
 	var clock, frames uint64
 
 	for {
 		if clock%cpuClockDivisor == 0 {
-			c.cpu.Step()
+			c.stepCPU()
 		}
 		if clock%ppuClockDivisor == 0 {
 			c.ppu.Step()
-			currFrames := c.ppu.Frames()
+			currFrames := c.ppu.Frames() - startFrames
 			if frames != currFrames {
 				frames = currFrames
 				c.handleFrame(startTime, frames)
@@ -92,49 +320,79 @@ func (c *Console) Run() {
 
 func (c *Console) RunFrames(frames uint64) {
 	startTime := time.Now()
+	startFrames := c.ppu.Frames()
 	var clock, currFrames uint64
 
 	for currFrames <= frames {
 		if clock%cpuClockDivisor == 0 {
-			c.cpu.Step()
+			c.stepCPU()
 		}
 		if clock%ppuClockDivisor == 0 {
 			c.ppu.Step()
-			nextFrames := c.ppu.Frames()
+			nextFrames := c.ppu.Frames() - startFrames
 			if currFrames != nextFrames {
 				currFrames = nextFrames
 				c.handleFrame(startTime, currFrames)
 			}
 		}
+		if clock%apuClockDivisor == 0 {
+			c.apu.Step()
+		}
 		clock++
 	}
 }
 
 func (c *Console) RunCycles(cycles uint64) {
 	startTime := time.Now()
+	startFrames := c.ppu.Frames()
 	var clock, frames uint64
 
 	for clock < cycles {
 		if clock%cpuClockDivisor == 0 {
-			c.cpu.Step()
+			c.stepCPU()
 		}
 		if clock%ppuClockDivisor == 0 {
 			c.ppu.Step()
-			currFrames := c.ppu.Frames()
+			currFrames := c.ppu.Frames() - startFrames
 			if frames != currFrames {
 				frames = currFrames
 				c.handleFrame(startTime, frames)
 			}
 		}
+		if clock%apuClockDivisor == 0 {
+			c.apu.Step()
+		}
 		clock++
 	}
 }
 
+// stepCPU advances the CPU, along with any cartridge hardware clocked alongside it.
+func (c *Console) stepCPU() {
+	c.cpu.Step()
+	if c.clocked != nil {
+		c.clocked.Step()
+	}
+	// IRQ line is held for as long as the cartridge asserts it
+	if c.interrupter != nil && c.interrupter.IRQ() {
+		c.cpu.IRQ()
+	}
+}
+
 func (c *Console) handleFrame(startTime time.Time, frames uint64) {
+	if c.nsf != nil && c.config.autoAdvance {
+		c.advanceTrack()
+	}
+	if c.fds != nil {
+		c.applyDiskRequest()
+	}
 	if c.config.draw != nil {
 		c.drawFrame()
 		c.config.draw(c.img)
 	}
+	if c.config.pacing == PacingAudio {
+		paceAudio(c.apu)
+		return
+	}
 	if c.config.rate <= 0 {
 		return
 	}
@@ -144,6 +402,53 @@ func (c *Console) handleFrame(startTime time.Time, frames uint64) {
 	time.Sleep(sleepDuration)
 }
 
+// audioOutput is the part of the APU that audio pacing regulates against.
+type audioOutput interface {
+	Buffered() time.Duration
+	SetRateAdjust(ratio float64)
+}
+
+// paceAudio regulates emulation speed against the consumption of the audio buffer.
+func paceAudio(out audioOutput) {
+	buffered := out.Buffered()
+	// Too far ahead of the audio device - wait for it to catch up
+	for buffered > 2*audioTargetLatency {
+		time.Sleep(time.Millisecond)
+		buffered = out.Buffered()
+	}
+
+	// Nudge the sample rate so that the buffer settles around the target
+	delta := float64(audioTargetLatency-buffered) / float64(audioTargetLatency)
+	if delta > 1 {
+		delta = 1
+	} else if delta < -1 {
+		delta = -1
+	}
+	out.SetRateAdjust(1 + delta*audioMaxRateDelta)
+}
+
+// AudioBuffer provides the APU output as signed 16-bit little endian mono PCM.
+type AudioBuffer interface {
+	io.Reader
+	Underruns() uint64
+	Overruns() uint64
+}
+
+// Audio returns the buffer that the APU mixes its output into.
+func (c *Console) Audio() AudioBuffer {
+	return c.apu.Buffer()
+}
+
+// ChannelAudio returns the captured output of a single APU channel, or nil if the channel is not
+// being captured via apu.WithCapture.
+func (c *Console) ChannelAudio(channel apu.Channel) AudioBuffer {
+	buf := c.apu.CaptureBuffer(channel)
+	if buf == nil {
+		return nil
+	}
+	return buf
+}
+
 func (c *Console) drawFrame() {
 	buf := c.ppu.Buffer()
 	for y, row := range buf {
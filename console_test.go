@@ -0,0 +1,79 @@
+package gophernes
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAudioOutput is an audio output with a buffer level that is set by the test.
+type testAudioOutput struct {
+	mu       sync.Mutex
+	buffered time.Duration
+	ratio    float64
+}
+
+func (t *testAudioOutput) Buffered() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buffered
+}
+
+func (t *testAudioOutput) SetRateAdjust(ratio float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ratio = ratio
+}
+
+func (t *testAudioOutput) setBuffered(buffered time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buffered = buffered
+}
+
+func TestPaceAudioBlocks(t *testing.T) {
+	out := &testAudioOutput{buffered: 3 * audioTargetLatency}
+	done := make(chan struct{})
+	go func() {
+		paceAudio(out)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected pacing to block while the buffer is over twice the target")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Draining to the threshold resumes emulation
+	out.setBuffered(2 * audioTargetLatency)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected pacing to resume once the buffer drained")
+	}
+	if ratio := out.ratio; ratio != 1-audioMaxRateDelta {
+		t.Errorf("expected rate adjust %v, got %v", 1-audioMaxRateDelta, ratio)
+	}
+}
+
+func TestPaceAudioRateAdjust(t *testing.T) {
+	tests := []struct {
+		buffered time.Duration
+		ratio    float64
+	}{
+		{0, 1 + audioMaxRateDelta},
+		{audioTargetLatency / 2, 1 + audioMaxRateDelta/2},
+		{audioTargetLatency, 1},
+		{audioTargetLatency * 3 / 2, 1 - audioMaxRateDelta/2},
+		{2 * audioTargetLatency, 1 - audioMaxRateDelta},
+	}
+	for _, test := range tests {
+		out := &testAudioOutput{buffered: test.buffered}
+		paceAudio(out)
+		if math.Abs(out.ratio-test.ratio) > 1e-9 {
+			t.Errorf("buffered %v: expected rate adjust %v, got %v", test.buffered, test.ratio, out.ratio)
+		}
+	}
+}
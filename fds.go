@@ -0,0 +1,54 @@
+package gophernes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/tomnz/gophernes/internal/cartridge"
+)
+
+// http://wiki.nesdev.com/w/index.php/FDS_file_format
+
+var (
+	fdsMagic = []byte{'F', 'D', 'S', 0x1a}
+	// fdsDiskMagic appears at the start of every disk side, in the disk info block
+	fdsDiskMagic = []byte("\x01*NINTENDO-HVC*")
+)
+
+const fdsHeaderSize = 16
+
+// loadFDS reads the disk sides from an .fds image, which may or may not have a header.
+func loadFDS(file io.Reader) ([][]byte, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, fdsMagic) {
+		if len(data) < fdsHeaderSize {
+			return nil, errors.New("truncated FDS header")
+		}
+		sides := int(data[4])
+		data = data[fdsHeaderSize:]
+		if len(data) < sides*cartridge.FDSSideSize {
+			return nil, fmt.Errorf("FDS header specifies %d sides, but the image is too short", sides)
+		}
+		data = data[:sides*cartridge.FDSSideSize]
+	}
+	if len(data) == 0 || len(data)%cartridge.FDSSideSize != 0 {
+		return nil, fmt.Errorf("FDS image size %d B is not a multiple of the side size", len(data))
+	}
+
+	var sides [][]byte
+	for len(data) > 0 {
+		side := data[:cartridge.FDSSideSize]
+		if !bytes.HasPrefix(side, fdsDiskMagic) {
+			return nil, fmt.Errorf("disk side %d does not appear to be an FDS disk", len(sides))
+		}
+		sides = append(sides, side)
+		data = data[cartridge.FDSSideSize:]
+	}
+	return sides, nil
+}
@@ -0,0 +1,85 @@
+package gophernes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tomnz/gophernes/internal/cartridge"
+)
+
+func testFDSImage(sides int, header bool) []byte {
+	buf := &bytes.Buffer{}
+	if header {
+		buf.Write(fdsMagic)
+		buf.WriteByte(byte(sides))
+		buf.Write(make([]byte, fdsHeaderSize-5))
+	}
+	for i := 0; i < sides; i++ {
+		side := make([]byte, cartridge.FDSSideSize)
+		copy(side, fdsDiskMagic)
+		side[56] = 2
+		buf.Write(side)
+	}
+	return buf.Bytes()
+}
+
+// testBIOS builds a BIOS which loops forever.
+func testBIOS() []byte {
+	bios := make([]byte, cartridge.FDSBIOSSize)
+	// JMP $E000
+	copy(bios, []byte{0x4C, 0x00, 0xE0})
+	// Reset vector
+	bios[0x1FFC] = 0x00
+	bios[0x1FFD] = 0xE0
+	return bios
+}
+
+func TestLoadFDS(t *testing.T) {
+	for _, header := range []bool{false, true} {
+		sides, err := loadFDS(bytes.NewReader(testFDSImage(2, header)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sides) != 2 {
+			t.Errorf("expected 2 sides, got %d", len(sides))
+		}
+	}
+
+	if _, err := loadFDS(bytes.NewReader(make([]byte, cartridge.FDSSideSize))); err == nil {
+		t.Error("expected error loading image without disk info")
+	}
+}
+
+func TestFDSDiskSides(t *testing.T) {
+	console, err := NewFDSConsole(bytes.NewReader(testFDSImage(2, true)), bytes.NewReader(testBIOS()), nil, nil, nil, WithRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if console.DiskSides() != 2 {
+		t.Errorf("expected 2 disk sides, got %d", console.DiskSides())
+	}
+	// Enable the disk registers, so the drive status can be read
+	console.CPUWrite(0x4023, 0x01)
+	if status := console.CPURead(0x4032); status&1 != 0 {
+		t.Errorf("expected disk to be inserted, got status %#x", status)
+	}
+
+	if err := console.EjectDisk(); err != nil {
+		t.Fatal(err)
+	}
+	console.RunFrames(0)
+	if status := console.CPURead(0x4032); status&1 == 0 {
+		t.Errorf("expected disk to be ejected, got status %#x", status)
+	}
+
+	if err := console.InsertDisk(2); err == nil {
+		t.Error("expected error inserting out of range side")
+	}
+	if err := console.InsertDisk(1); err != nil {
+		t.Fatal(err)
+	}
+	console.RunFrames(0)
+	if status := console.CPURead(0x4032); status&1 != 0 {
+		t.Errorf("expected disk to be inserted, got status %#x", status)
+	}
+}
@@ -3,15 +3,25 @@ package gophernes
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/tomnz/gophernes/internal/cartridge"
 )
 
+// http://wiki.nesdev.com/w/index.php/INES
+// http://wiki.nesdev.com/w/index.php/NES_2.0
+
 const (
 	inesMagic        = 0x1a53454e
 	prgLenMultiplier = 16384
 	chrLenMultiplier = 8192
+	trainerSize      = 512
+	// defaultCHRRAMSize is provided when a cartridge has no CHR ROM
+	defaultCHRRAMSize = 8192
+	// maxROMSize limits the PRG and CHR ROM sizes, to reject malformed headers before allocating.
+	// This covers every size NES 2.0 can express without exponent-multiplier notation.
+	maxROMSize = 64 << 20
 )
 
 type inesHeader struct {
@@ -25,47 +35,160 @@ type inesHeader struct {
 	Flags10,
 	Flags11,
 	Flags12,
-	Flags13 byte
-	_ [2]byte
+	Flags13,
+	Flags14,
+	Flags15 byte
+}
+
+// loadINES creates the cartridge for an iNES or NES 2.0 file, loading any previously saved data.
+func loadINES(file io.Reader, saveData []byte) (cartridge.Cartridge, error) {
+	info, prg, chr, err := readINES(file)
+	if err != nil {
+		return nil, err
+	}
+	info.SaveData = saveData
+	return cartridge.NewCartridge(info, prg, chr)
 }
 
-func loadINES(file io.Reader) (cartridge.Cartridge, error) {
+// readINES parses an iNES or NES 2.0 file, and returns its header details and ROM contents.
+func readINES(file io.Reader) (*cartridge.RomInfo, []byte, []byte, error) {
 	header := inesHeader{}
 	if err := binary.Read(file, binary.LittleEndian, &header); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	if header.Magic != inesMagic {
-		return nil, errors.New("does not appear to be an iNES file: invalid header")
+		return nil, nil, nil, errors.New("does not appear to be an iNES file: invalid header")
 	}
 
-	// TODO: Detect iNES version
+	var info *cartridge.RomInfo
+	var prgLen, chrLen int
 	// https://wiki.nesdev.com/w/index.php/INES#Variant_comparison
-	// For now, assume iNES 1.0
-
-	mapper := uint16(header.Flags6>>4 | header.Flags7&0xf0)
+	if header.Flags7&0x0C == 0x08 {
+		info, prgLen, chrLen = parseNES2Header(&header)
+	} else {
+		info, prgLen, chrLen = parseINESHeader(&header)
+	}
+	if prgLen > maxROMSize {
+		return nil, nil, nil, fmt.Errorf("PRG ROM size %d B exceeds the maximum of %d B", prgLen, maxROMSize)
+	}
+	if chrLen > maxROMSize {
+		return nil, nil, nil, fmt.Errorf("CHR ROM size %d B exceeds the maximum of %d B", chrLen, maxROMSize)
+	}
 
-	if (header.Flags6>>3)&1 == 1 {
-		// Trainer is present in the ROM - ignore
-		if _, err := io.ReadFull(file, make([]byte, 512)); err != nil {
-			return nil, err
+	if (header.Flags6>>2)&1 == 1 {
+		info.Trainer = make([]byte, trainerSize)
+		if _, err := io.ReadFull(file, info.Trainer); err != nil {
+			return nil, nil, nil, err
 		}
 	}
 
-	prg := make([]byte, prgLenMultiplier*int(header.PrgLen))
+	prg := make([]byte, prgLen)
 	if _, err := io.ReadFull(file, prg); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	var chr []byte
-	if header.ChrLen == 0 {
-		// Special case - provide an empty block
-		chr = make([]byte, 8192)
+	if chrLen == 0 {
+		// Special case - provide RAM instead
+		size := info.CHRRAMSize + info.CHRNVRAMSize
+		if size == 0 {
+			size = defaultCHRRAMSize
+		}
+		chr = make([]byte, size)
 	} else {
-		chr = make([]byte, chrLenMultiplier*int(header.ChrLen))
+		chr = make([]byte, chrLen)
 		if _, err := io.ReadFull(file, chr); err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 	}
 
-	return cartridge.NewCartridge(mapper, prg, chr)
+	return info, prg, chr, nil
+}
+
+// parseINESHeader reads the fields supported by iNES 1.0, and returns the PRG and CHR ROM sizes.
+func parseINESHeader(header *inesHeader) (*cartridge.RomInfo, int, int) {
+	mapper := uint16(header.Flags6>>4 | header.Flags7&0xf0)
+	if header.Flags12 != 0 || header.Flags13 != 0 || header.Flags14 != 0 || header.Flags15 != 0 {
+		// Old ROM tools wrote junk (such as "DiskDude!") into the unused bytes, including the
+		// upper mapper nibble
+		mapper &= 0xf
+	}
+
+	info := &cartridge.RomInfo{
+		Mapper:    mapper,
+		Mirroring: inesMirroring(header.Flags6),
+		Battery:   (header.Flags6>>1)&1 == 1,
+		Console:   cartridge.ConsoleType(header.Flags7 & 3),
+	}
+	if header.Flags9&1 == 1 {
+		info.Timing = cartridge.TimingPAL
+	}
+	// PRG RAM is specified in 8KB units, where 0 implies 8KB for compatibility
+	ramSize := 0x2000 * int(header.Flags8)
+	if ramSize == 0 {
+		ramSize = 0x2000
+	}
+	if info.Battery {
+		info.PRGNVRAMSize = ramSize
+	} else {
+		info.PRGRAMSize = ramSize
+	}
+
+	return info, prgLenMultiplier * int(header.PrgLen), chrLenMultiplier * int(header.ChrLen)
+}
+
+// parseNES2Header reads an NES 2.0 header, and returns the PRG and CHR ROM sizes.
+func parseNES2Header(header *inesHeader) (*cartridge.RomInfo, int, int) {
+	info := &cartridge.RomInfo{
+		NES2:            true,
+		Mapper:          uint16(header.Flags6>>4) | uint16(header.Flags7&0xf0) | uint16(header.Flags8&0xf)<<8,
+		Submapper:       header.Flags8 >> 4,
+		Mirroring:       inesMirroring(header.Flags6),
+		Battery:         (header.Flags6>>1)&1 == 1,
+		PRGRAMSize:      nes2RAMSize(header.Flags10 & 0xf),
+		PRGNVRAMSize:    nes2RAMSize(header.Flags10 >> 4),
+		CHRRAMSize:      nes2RAMSize(header.Flags11 & 0xf),
+		CHRNVRAMSize:    nes2RAMSize(header.Flags11 >> 4),
+		Timing:          cartridge.Timing(header.Flags12 & 3),
+		Console:         cartridge.ConsoleType(header.Flags7 & 3),
+		ConsoleDetails:  header.Flags13,
+		MiscROMs:        header.Flags14 & 3,
+		ExpansionDevice: header.Flags15 & 0x3f,
+	}
+	prgLen := nes2ROMSize(header.PrgLen, header.Flags9&0xf, prgLenMultiplier)
+	chrLen := nes2ROMSize(header.ChrLen, header.Flags9>>4, chrLenMultiplier)
+	return info, prgLen, chrLen
+}
+
+// nes2ROMSize decodes a ROM size, given the LSB from bytes 4/5 and MSB nibble from byte 9.
+func nes2ROMSize(lsb, msb byte, multiplier int) int {
+	if msb == 0xf {
+		// Exponent-multiplier notation: EEEEEEMM gives 2^E * (MM*2+1)
+		exponent := uint(lsb >> 2)
+		if exponent > 27 {
+			// Still over maxROMSize, but without overflowing
+			exponent = 27
+		}
+		return (1 << exponent) * (int(lsb&3)*2 + 1)
+	}
+	return (int(msb)<<8 | int(lsb)) * multiplier
+}
+
+// nes2RAMSize decodes a RAM size shift count.
+func nes2RAMSize(shift byte) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << uint(shift)
+}
+
+// inesMirroring decodes the nametable mirroring from flags 6, which is common to both formats.
+func inesMirroring(flags6 byte) cartridge.Mirroring {
+	if (flags6>>3)&1 == 1 {
+		return cartridge.MirrorFourScreen
+	}
+	if flags6&1 == 1 {
+		return cartridge.MirrorVertical
+	}
+	return cartridge.MirrorHorizontal
 }
@@ -0,0 +1,165 @@
+package gophernes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/tomnz/gophernes/internal/cartridge"
+)
+
+func testINESImage(header inesHeader, trainer []byte, prgLen, chrLen int) []byte {
+	header.Magic = inesMagic
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, &header)
+	buf.Write(trainer)
+	buf.Write(make([]byte, prgLen+chrLen))
+	return buf.Bytes()
+}
+
+func TestReadINES(t *testing.T) {
+	image := testINESImage(inesHeader{
+		PrgLen: 2,
+		ChrLen: 1,
+		Flags6: 0x13,
+		Flags7: 0x10,
+		Flags8: 2,
+		Flags9: 1,
+	}, nil, 2*prgLenMultiplier, chrLenMultiplier)
+	info, prg, chr, err := readINES(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NES2 {
+		t.Error("expected iNES header")
+	}
+	if info.Mapper != 0x11 {
+		t.Errorf("expected mapper 17, got %d", info.Mapper)
+	}
+	if !info.Battery || info.PRGNVRAMSize != 0x4000 {
+		t.Errorf("expected 16KB battery backed PRG RAM, got %d B", info.PRGNVRAMSize)
+	}
+	if info.Mirroring != cartridge.MirrorVertical {
+		t.Errorf("expected vertical mirroring, got %d", info.Mirroring)
+	}
+	if info.Timing != cartridge.TimingPAL {
+		t.Errorf("expected PAL timing, got %d", info.Timing)
+	}
+	if len(prg) != 2*prgLenMultiplier || len(chr) != chrLenMultiplier {
+		t.Errorf("unexpected ROM sizes: PRG %d B, CHR %d B", len(prg), len(chr))
+	}
+
+	// Junk in the unused bytes means the upper mapper nibble can't be trusted
+	image = testINESImage(inesHeader{
+		PrgLen:  1,
+		Flags6:  0x10,
+		Flags7:  0x40,
+		Flags12: 'D',
+		Flags13: 'i',
+	}, nil, prgLenMultiplier, 0)
+	info, _, chr, err = readINES(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mapper != 1 {
+		t.Errorf("expected mapper 1, got %d", info.Mapper)
+	}
+	if len(chr) != defaultCHRRAMSize {
+		t.Errorf("expected %d B CHR RAM, got %d B", defaultCHRRAMSize, len(chr))
+	}
+}
+
+func TestReadNES2(t *testing.T) {
+	image := testINESImage(inesHeader{
+		PrgLen:  2,
+		Flags6:  0x42,
+		Flags7:  0x59,
+		Flags8:  0x31,
+		Flags9:  0x00,
+		Flags10: 0x70,
+		Flags11: 0x09,
+		Flags12: 3,
+		Flags13: 0x12,
+		Flags14: 1,
+		Flags15: 0x21,
+	}, nil, 2*prgLenMultiplier, 0)
+	info, prg, chr, err := readINES(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cartridge.RomInfo{
+		NES2:            true,
+		Mapper:          0x154,
+		Submapper:       3,
+		Battery:         true,
+		PRGNVRAMSize:    0x2000,
+		CHRRAMSize:      0x8000,
+		Timing:          cartridge.TimingDendy,
+		Console:         cartridge.ConsoleVsSystem,
+		ConsoleDetails:  0x12,
+		MiscROMs:        1,
+		ExpansionDevice: 0x21,
+	}
+	if !reflect.DeepEqual(*info, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *info)
+	}
+	if len(prg) != 2*prgLenMultiplier {
+		t.Errorf("expected %d B PRG ROM, got %d B", 2*prgLenMultiplier, len(prg))
+	}
+	if len(chr) != 0x8000 {
+		t.Errorf("expected 32KB CHR RAM, got %d B", len(chr))
+	}
+
+	// Exponent-multiplier notation: 2^10 * 3
+	image = testINESImage(inesHeader{
+		PrgLen: 10<<2 | 1,
+		Flags7: 0x08,
+		Flags9: 0x0F,
+	}, nil, 3072, 0)
+	_, prg, _, err = readINES(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prg) != 3072 {
+		t.Errorf("expected 3072 B PRG ROM, got %d B", len(prg))
+	}
+}
+
+func TestReadNES2Oversized(t *testing.T) {
+	tests := []struct {
+		name   string
+		header inesHeader
+	}{
+		// 2^63 * 7 overflows without a bound
+		{"PRG exponent", inesHeader{PrgLen: 63<<2 | 3, Flags7: 0x08, Flags9: 0x0F}},
+		{"CHR exponent", inesHeader{PrgLen: 1, ChrLen: 40 << 2, Flags7: 0x08, Flags9: 0xF0}},
+	}
+	for _, test := range tests {
+		image := testINESImage(test.header, nil, prgLenMultiplier, 0)
+		if _, _, _, err := readINES(bytes.NewReader(image)); err == nil {
+			t.Errorf("%s: expected an error for an oversized ROM", test.name)
+		}
+	}
+}
+
+func TestINESTrainer(t *testing.T) {
+	trainer := make([]byte, trainerSize)
+	for i := range trainer {
+		trainer[i] = byte(i)
+	}
+	image := testINESImage(inesHeader{
+		PrgLen: 1,
+		ChrLen: 1,
+		Flags6: 0x04,
+	}, trainer, prgLenMultiplier, chrLenMultiplier)
+	cart, err := loadINES(bytes.NewReader(image), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []uint16{0x7000, 0x7001, 0x70FF, 0x71FF} {
+		if val := cart.CPURead(addr); val != byte(addr) {
+			t.Errorf("expected %#x at %#x, got %#x", byte(addr), addr, val)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package gophernes
+
+import "sync"
+
+// Port identifies one of the console's two controller ports.
+type Port int
+
+const (
+	Port1 Port = iota
+	Port2
+)
+
+// InputDevice is implemented by anything that can be plugged into a controller port.
+// http://wiki.nesdev.com/w/index.php/Input_devices
+type InputDevice interface {
+	// Strobe is called whenever the CPU writes to $4016, with the state of bit 0 (OUT0).
+	Strobe(on bool)
+	// Read is called whenever the CPU reads the port. Only bits 0-4 are driven by the device,
+	// the remainder come from the open bus.
+	Read() byte
+}
+
+// controllerPort handles the data lines between the CPU and an (optional) input device.
+type controllerPort struct {
+	device InputDevice
+}
+
+func (c *controllerPort) strobe(on bool) {
+	if c.device != nil {
+		c.device.Strobe(on)
+	}
+}
+
+func (c *controllerPort) read(bus byte) byte {
+	// Upper bits are not driven, so we get whatever was last on the bus - usually the high
+	// byte of the address
+	val := bus & 0xE0
+	if c.device != nil {
+		val |= c.device.Read() & 0x1F
+	}
+	return val
+}
+
+// Buttons is a bitmask of standard controller buttons, in the order that they are reported.
+type Buttons byte
+
+const (
+	ButtonA Buttons = 1 << iota
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonUp
+	ButtonDown
+	ButtonLeft
+	ButtonRight
+)
+
+// NewJoypad initializes a new standard controller with no buttons pressed.
+func NewJoypad() *Joypad {
+	return &Joypad{}
+}
+
+// Joypad implements the standard NES controller, which reports its buttons through an 8-bit
+// shift register that is latched while strobe is high.
+// http://wiki.nesdev.com/w/index.php/Standard_controller
+type Joypad struct {
+	// Buttons are typically set from a separate UI goroutine
+	mu      sync.Mutex
+	buttons Buttons
+
+	strobe bool
+	shift  byte
+}
+
+// SetButtons replaces the set of currently pressed buttons.
+func (j *Joypad) SetButtons(buttons Buttons) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.buttons = buttons
+}
+
+func (j *Joypad) Strobe(on bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.strobe = on
+	if on {
+		j.shift = byte(j.buttons)
+	}
+}
+
+func (j *Joypad) Read() byte {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.strobe {
+		// Shift register is continuously reloaded, so we only ever see the A button
+		return byte(j.buttons & ButtonA)
+	}
+	val := j.shift & 1
+	// Official controllers shift in 1s, so all reads after the first 8 report 1
+	j.shift = j.shift>>1 | 0x80
+	return val
+}
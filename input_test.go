@@ -0,0 +1,32 @@
+package gophernes
+
+import "testing"
+
+func TestJoypad(t *testing.T) {
+	joypad := NewJoypad()
+	joypad.SetButtons(ButtonA | ButtonStart | ButtonLeft)
+	port := controllerPort{device: joypad}
+
+	joypad.Strobe(true)
+	// Reads while strobe is high always report A
+	for i := 0; i < 3; i++ {
+		if val := port.read(0x40); val != 0x41 {
+			t.Fatalf("expected strobed read to return %#x, got %#x", 0x41, val)
+		}
+	}
+	joypad.Strobe(false)
+
+	expected := []byte{1, 0, 0, 1, 0, 0, 1, 0, 1, 1}
+	for i, bit := range expected {
+		if val := port.read(0x40); val != 0x40|bit {
+			t.Errorf("read %d: expected %#x, got %#x", i, 0x40|bit, val)
+		}
+	}
+}
+
+func TestControllerPortDisconnected(t *testing.T) {
+	port := controllerPort{}
+	if val := port.read(0x40); val != 0x40 {
+		t.Errorf("expected open bus value %#x, got %#x", 0x40, val)
+	}
+}
@@ -1,23 +1,45 @@
 package apu
 
-import "fmt"
+import (
+	"time"
 
-func NewAPU(irqer IRQer, opts ...Option) *APU {
+	"github.com/sirupsen/logrus"
+)
+
+func NewAPU(cpu CPU, mem Memory, opts ...Option) *APU {
 	config := defaultConfig()
 	for _, opt := range opts {
 		opt(config)
 	}
 
-	return &APU{
+	apu := &APU{
 		config:   config,
-		irqer:    irqer,
-		buf:      newBuffer(int(config.sampleRate)),
-		pulse1:   newPulseChannel(),
-		pulse2:   newPulseChannel(),
+		cpu:      cpu,
+		gain:     1,
+		buf:      newBuffer(int(config.sampleRate) / 2),
+		mixer:    newMixer(float64(config.sampleRate)),
+		pulse1:   newPulseChannel(true),
+		pulse2:   newPulseChannel(false),
 		triangle: newTriangleChannel(),
 		noise:    newNoiseChannel(),
-		dmc:      newDMCChannel(),
+		dmc:      newDMCChannel(cpu, mem),
+	}
+
+	var solo bool
+	for _, soloed := range config.soloed {
+		solo = solo || soloed
+	}
+	for channel := range apu.audible {
+		if solo {
+			apu.audible[channel] = config.soloed[channel]
+		} else {
+			apu.audible[channel] = !config.muted[channel]
+		}
+		if config.captured[channel] || config.channelRecorders[channel] != nil {
+			apu.captures[channel] = newCapture(config.sampleRate, config.captured[channel], config.channelRecorders[channel])
+		}
 	}
+	return apu
 }
 
 // APU is the main APU of the NES system.
@@ -25,9 +47,15 @@ type APU struct {
 	config *config
 	cycles uint64
 
-	flags Flags
-	irqer IRQer
-	buf   *buffer
+	flags        Flags
+	frameCounter frameCounter
+	cpu          CPU
+	mixer        *mixer
+	buf          *buffer
+	pcm          [bytesPerSample]byte
+	audible      [numChannels]bool
+	captures     [numChannels]*capture
+	gain         float64
 
 	// Channels
 	pulse1,
@@ -41,81 +69,22 @@ type IRQer interface {
 	IRQ()
 }
 
-func newPulseChannel() *pulseChannel {
-	return &pulseChannel{}
+// CPU is the interface the APU uses to interrupt and stall the CPU.
+type CPU interface {
+	IRQer
+	Sleep(cycles uint64)
 }
 
-type pulseChannel struct {
-	// Registers
-	duty byte
-	counterDisable,
-	constantVol bool
-	envelopePeriod  byte
-	sweepEnabled    bool
-	sweepPeriod     byte
-	sweepNegative   bool
-	sweepShiftCount byte
-	timerLoad       uint16
-	dividerLoad     byte
-
-	// State
-	divider byte
-	timer   uint16
+// ExpansionAudio is an additional source of audio, such as a cartridge's sound chip.
+type ExpansionAudio interface {
+	// AudioOutput returns the current output level, on the same scale as the APU mixer. It is
+	// sampled once per CPU cycle.
+	AudioOutput() float64
 }
 
-func newTriangleChannel() *triangleChannel {
-	return &triangleChannel{}
-}
-
-type triangleChannel struct {
-	counterDisable   bool
-	counterReloadVal byte
-	timer            uint16
-	dividerLoad      byte
-}
-
-func newNoiseChannel() *noiseChannel {
-	return &noiseChannel{}
-}
-
-type noiseChannel struct {
-	counterDisable,
-	constantVol bool
-	envelopePeriod byte
-	loopNoise      bool
-	period         byte
-	dividerLoad    byte
-}
-
-func newDMCChannel() *dmcChannel {
-	return &dmcChannel{}
-}
-
-type dmcChannel struct {
-	irqEnable     bool
-	loopSample    bool
-	freqIndex     byte
-	directLoad    byte
-	sampleAddress byte
-	sampleLength  byte
-}
-
-func (p *pulseChannel) step() {
-	//if p.timer == 0 {
-	//	p.timer =
-	//}
-	//
-	//if p.divider == 0 {
-	//	tick = true
-	//	p.divider = p.dividerLoad
-	//} else {
-	//	p.divider--
-	//}
-	//
-	//if !tick {
-	//	return
-	//}
-
+// Memory is used by the DMC to fetch sample bytes from CPU memory.
+type Memory interface {
+	Read(addr uint16) byte
 }
 
 type Flags struct {
@@ -131,9 +100,83 @@ func (a *APU) Flags() Flags {
 }
 
 func (a *APU) Reset() {
+	// Reset behaves as though $4015 was written with 0, silencing all channels
+	a.WriteReg(regControl, 0)
 }
 
+// Step advances the APU by a single CPU cycle.
 func (a *APU) Step() {
+	// Triangle timer runs at the CPU rate, the rest are clocked every other cycle
+	a.triangle.clockTimer()
+	a.noise.clockTimer()
+	a.dmc.clockTimer()
+	if a.cycles%2 == 0 {
+		a.pulse1.clockTimer()
+		a.pulse2.clockTimer()
+	}
+
+	quarter, half := a.frameCounter.step()
+	if quarter {
+		a.clockQuarterFrame()
+	}
+	if half {
+		a.clockHalfFrame()
+	}
+
+	// IRQ line is held for as long as the flag remains set
+	if a.frameCounter.irq || a.dmc.irq {
+		a.cpu.IRQ()
+	}
+
+	levels := [numChannels]byte{
+		ChannelPulse1:   a.pulse1.output(),
+		ChannelPulse2:   a.pulse2.output(),
+		ChannelTriangle: a.triangle.output(),
+		ChannelNoise:    a.noise.output(),
+		ChannelDMC:      a.dmc.output(),
+	}
+	for channel, capture := range a.captures {
+		if capture != nil {
+			var solo [numChannels]byte
+			solo[channel] = levels[channel]
+			capture.step(mix(solo))
+		}
+		if !a.audible[channel] {
+			levels[channel] = 0
+		}
+	}
+
+	level := mix(levels)
+	if a.config.expansion != nil {
+		level += a.config.expansion.AudioOutput()
+	}
+	level *= a.gain
+	if sample, ok := a.mixer.step(level); ok {
+		a.pcm[0] = byte(sample)
+		a.pcm[1] = byte(uint16(sample) >> 8)
+		a.buf.push(a.pcm[:])
+		if a.config.recorder != nil {
+			a.config.recorder.Write(a.pcm[:])
+		}
+	}
+
+	a.cycles++
+}
+
+// clockQuarterFrame clocks the envelopes and triangle linear counter.
+func (a *APU) clockQuarterFrame() {
+	a.pulse1.clockQuarterFrame()
+	a.pulse2.clockQuarterFrame()
+	a.triangle.clockQuarterFrame()
+	a.noise.clockQuarterFrame()
+}
+
+// clockHalfFrame clocks the length counters and sweep units.
+func (a *APU) clockHalfFrame() {
+	a.pulse1.clockHalfFrame()
+	a.pulse2.clockHalfFrame()
+	a.triangle.clockHalfFrame()
+	a.noise.clockHalfFrame()
 }
 
 func (a *APU) Close() {
@@ -143,85 +186,91 @@ func (a *APU) Buffer() *buffer {
 	return a.buf
 }
 
+// CaptureBuffer returns the buffer holding the output of the given channel, or nil if the
+// channel is not being captured.
+func (a *APU) CaptureBuffer(channel Channel) *buffer {
+	if a.captures[channel] == nil {
+		return nil
+	}
+	return a.captures[channel].buf
+}
+
+// Buffered returns the duration of audio currently waiting to be read from the buffer.
+func (a *APU) Buffered() time.Duration {
+	samples := a.buf.size() / bytesPerSample
+	return time.Duration(samples) * time.Second / time.Duration(a.config.sampleRate)
+}
+
+// SetRateAdjust scales the rate that samples are produced at. This can be used for dynamic rate
+// control, to keep the buffer from draining or filling while emulation is paced by the audio
+// device's consumption. A ratio of 1.0 produces samples at the configured sample rate.
+func (a *APU) SetRateAdjust(ratio float64) {
+	a.mixer.resampler.setRatio(ratio)
+}
+
+// SetGain scales the mixed output, from 0.0 (silent) to 1.0. Channel captures are unaffected.
+func (a *APU) SetGain(gain float64) {
+	a.gain = gain
+}
+
+// Gain returns the scale applied to the mixed output.
+func (a *APU) Gain() float64 {
+	return a.gain
+}
+
 func (a *APU) WriteReg(reg byte, val byte) {
+	if a.config.trace {
+		logrus.Debugf("APU: Write %#02x to register %#02x", val, reg)
+	}
+
 	switch reg {
 	case regPulse1_1:
-		a.pulse1.duty = val >> 6
-		a.pulse1.counterDisable = val>>5&1 == 1
-		a.pulse1.constantVol = val>>4&1 == 1
-		a.pulse1.envelopePeriod = val & 0xF
+		a.pulse1.writeControl(val)
 
 	case regPulse1_2:
-		a.pulse1.sweepEnabled = val>>7&1 == 1
-		a.pulse1.sweepPeriod = val >> 4 & 0x7
-		a.pulse1.sweepNegative = val>>3&1 == 1
-		a.pulse1.sweepShiftCount = val & 0x7
+		a.pulse1.writeSweep(val)
 
 	case regPulse1_3:
-		a.pulse1.timer &= 0xFF00
-		a.pulse1.timer |= uint16(val)
+		a.pulse1.writeTimerLow(val)
 
 	case regPulse1_4:
-		a.pulse1.timer &= 0x00FF
-		a.pulse1.timer |= uint16(val&0x7) << 8
-		a.pulse1.dividerLoad = val >> 3
-		// TODO: Reset duty and start envelope?
+		a.pulse1.writeTimerHigh(val)
 
 	case regPulse2_1:
-		a.pulse2.duty = val >> 6
-		a.pulse2.counterDisable = val>>5&1 == 1
-		a.pulse2.constantVol = val>>4&1 == 1
-		a.pulse2.envelopePeriod = val & 0xF
+		a.pulse2.writeControl(val)
 
 	case regPulse2_2:
-		a.pulse2.sweepEnabled = val>>7&1 == 1
-		a.pulse2.sweepPeriod = val >> 4 & 0x7
-		a.pulse2.sweepNegative = val>>3&1 == 1
-		a.pulse2.sweepShiftCount = val & 0x7
+		a.pulse2.writeSweep(val)
 
 	case regPulse2_3:
-		a.pulse2.timer &= 0xFF00
-		a.pulse2.timer |= uint16(val)
+		a.pulse2.writeTimerLow(val)
 
 	case regPulse2_4:
-		a.pulse2.timer &= 0x00FF
-		a.pulse2.timer |= uint16(val&0x7) << 8
-		a.pulse2.dividerLoad = val >> 3
-		// TODO: Reset duty and start envelope?
+		a.pulse2.writeTimerHigh(val)
 
 	case regTriangle_1:
-		a.triangle.counterDisable = val>>7&1 == 1
-		a.triangle.counterReloadVal = val & 0x7F
+		a.triangle.writeControl(val)
 
 	case regTriangle_2:
-		a.triangle.timer &= 0xFF00
-		a.triangle.timer |= uint16(val)
+		a.triangle.writeTimerLow(val)
 
 	case regTriangle_3:
-		a.triangle.timer &= 0x00FF
-		a.triangle.timer |= uint16(val&0x7) << 8
-		a.triangle.dividerLoad = val >> 3
-		// TODO: Reload linear counter?
+		a.triangle.writeTimerHigh(val)
 
 	case regNoise_1:
-		a.noise.counterDisable = val>>5&1 == 1
-		a.noise.constantVol = val>>4&1 == 1
-		a.noise.envelopePeriod = val & 0xF
+		a.noise.writeControl(val)
 
 	case regNoise_2:
-		a.noise.loopNoise = val>>7&1 == 1
-		a.noise.period = val & 0xF
+		a.noise.writePeriod(val)
 
 	case regNoise_3:
-		a.noise.dividerLoad = val >> 3
+		a.noise.writeLength(val)
 
 	case regDMC_1:
-		a.dmc.irqEnable = val>>7&1 == 1
-		a.dmc.loopSample = val>>6&1 == 1
-		a.dmc.freqIndex = val & 0xF
+		a.dmc.writeControl(val)
 
 	case regDMC_2:
-		a.dmc.directLoad = val & 0x7F
+		a.dmc.writeDirectLoad(val)
 
 	case regDMC_3:
 		a.dmc.sampleAddress = val
@@ -235,9 +284,15 @@ func (a *APU) WriteReg(reg byte, val byte) {
 		a.flags.triangleEnable = val>>2&1 == 1
 		a.flags.pulse2Enable = val>>1&1 == 1
 		a.flags.pulse1Enable = val&1 == 1
+		// Disabling a channel also immediately clears its length counter
+		a.pulse1.length.SetEnabled(a.flags.pulse1Enable)
+		a.pulse2.length.SetEnabled(a.flags.pulse2Enable)
+		a.triangle.length.SetEnabled(a.flags.triangleEnable)
+		a.noise.length.SetEnabled(a.flags.noiseEnable)
+		a.dmc.setEnabled(a.flags.dmcEnable)
 
 	case regFrameCounter:
-		// TODO: What is this I don't even
+		a.frameCounter.write(val, a.cycles%2 == 1)
 
 	default:
 		// panic(fmt.Sprintf("write to unknown APU register %#x", reg))
@@ -245,12 +300,44 @@ func (a *APU) WriteReg(reg byte, val byte) {
 	}
 }
 
-func (a *APU) ReadReg(reg byte) byte {
+// ReadReg reads an APU register. bus is the value last on the CPU data bus, which is returned for
+// bits that aren't driven - usually the high byte of the address.
+func (a *APU) ReadReg(reg byte, bus byte) byte {
+	val := bus
 	switch reg {
 	case regControl:
-		// TODO: Do this shit
-		return 0
-
+		// Bit 5 is not driven, so comes from the open bus
+		val &= 0x20
+		if a.pulse1.length.Active() {
+			val |= 1
+		}
+		if a.pulse2.length.Active() {
+			val |= 1 << 1
+		}
+		if a.triangle.length.Active() {
+			val |= 1 << 2
+		}
+		if a.noise.length.Active() {
+			val |= 1 << 3
+		}
+		if a.dmc.active() {
+			val |= 1 << 4
+		}
+		if a.frameCounter.irq {
+			val |= 1 << 6
+		}
+		if a.dmc.irq {
+			val |= 1 << 7
+		}
+		// Acknowledges the frame interrupt as a side effect - the DMC interrupt is only
+		// acknowledged by writes
+		a.frameCounter.irq = false
+
+	// Remaining registers are write-only, so just return the current bus value
+	default:
+		if a.config.trace {
+			logrus.Debugf("APU: Read from write-only register %#02x", reg)
+		}
 	}
-	panic(fmt.Sprintf("read from unknown APU register %#x", reg))
+	return val
 }
@@ -0,0 +1,136 @@
+package apu
+
+import "testing"
+
+// testCPU records interrupts and stalls requested by the APU.
+type testCPU struct {
+	irqs  int
+	slept uint64
+}
+
+func (t *testCPU) IRQ() {
+	t.irqs++
+}
+
+func (t *testCPU) Sleep(cycles uint64) {
+	t.slept += cycles
+}
+
+// testMemory returns the low byte of the address for every read.
+type testMemory struct{}
+
+func (testMemory) Read(addr uint16) byte {
+	return byte(addr)
+}
+
+func newTestAPU() (*APU, *testCPU) {
+	cpu := &testCPU{}
+	apu := NewAPU(cpu, testMemory{})
+	apu.Reset()
+	return apu, cpu
+}
+
+func TestStatusLengthCounters(t *testing.T) {
+	apu, _ := newTestAPU()
+	apu.WriteReg(regFrameCounter, 0x40)
+
+	// Length counters can only be loaded while the channel is enabled
+	apu.WriteReg(regPulse1_4, 0x08)
+	if status := apu.ReadReg(regControl, 0x40); status&0x1F != 0 {
+		t.Fatalf("expected no active channels, got status %#x", status)
+	}
+
+	apu.WriteReg(regControl, 0x0F)
+	apu.WriteReg(regPulse1_4, 0x08)
+	apu.WriteReg(regTriangle_3, 0x08)
+	apu.WriteReg(regNoise_3, 0x08)
+	if status := apu.ReadReg(regControl, 0x40); status&0x1F != 0x0D {
+		t.Fatalf("expected status %#x, got %#x", 0x0D, status&0x1F)
+	}
+
+	// Disabling a channel clears its length counter
+	apu.WriteReg(regControl, 0x01)
+	if status := apu.ReadReg(regControl, 0x40); status&0x1F != 0x01 {
+		t.Fatalf("expected status %#x, got %#x", 0x01, status&0x1F)
+	}
+
+	// Length index 1 loads 254, and there are two half frames per 4-step sequence
+	for i := 0; i < frameFourLength*(254/2+1); i++ {
+		apu.Step()
+	}
+	if status := apu.ReadReg(regControl, 0x40); status&0x1F != 0 {
+		t.Fatalf("expected length counter to expire, got status %#x", status)
+	}
+}
+
+func TestStatusFrameIRQ(t *testing.T) {
+	apu, cpu := newTestAPU()
+	apu.WriteReg(regFrameCounter, 0)
+
+	for i := 0; i < frameFourLength+4; i++ {
+		apu.Step()
+	}
+	if cpu.irqs == 0 {
+		t.Fatal("expected frame IRQ to be raised")
+	}
+	if status := apu.ReadReg(regControl, 0x40); status&0x40 == 0 {
+		t.Fatalf("expected frame IRQ flag in status %#x", status)
+	}
+	if status := apu.ReadReg(regControl, 0x40); status&0x40 != 0 {
+		t.Fatalf("expected frame IRQ flag to be cleared by read, got status %#x", status)
+	}
+
+	// Inhibit prevents the flag being set at all
+	apu.WriteReg(regFrameCounter, 0x40)
+	cpu.irqs = 0
+	for i := 0; i < 2*frameFourLength; i++ {
+		apu.Step()
+	}
+	if cpu.irqs != 0 {
+		t.Fatalf("expected no IRQs with inhibit set, got %d", cpu.irqs)
+	}
+}
+
+func TestStatusDMC(t *testing.T) {
+	apu, cpu := newTestAPU()
+	apu.WriteReg(regFrameCounter, 0x40)
+
+	// Fastest rate with IRQ enabled, single byte sample
+	apu.WriteReg(regDMC_1, 0x8F)
+	apu.WriteReg(regDMC_4, 0)
+	apu.WriteReg(regControl, 0x10)
+	if cpu.slept != dmcFetchCycles {
+		t.Fatalf("expected CPU to be stalled for %d cycles, got %d", dmcFetchCycles, cpu.slept)
+	}
+
+	status := apu.ReadReg(regControl, 0x40)
+	if status&0x80 == 0 {
+		t.Fatalf("expected DMC IRQ flag after the last byte was fetched, got status %#x", status)
+	}
+	if status&0x10 != 0 {
+		t.Fatalf("expected DMC to be inactive, got status %#x", status)
+	}
+	// Unlike the frame IRQ, reading does not acknowledge
+	if status := apu.ReadReg(regControl, 0x40); status&0x80 == 0 {
+		t.Fatalf("expected DMC IRQ flag to remain set, got status %#x", status)
+	}
+	apu.WriteReg(regControl, 0)
+	if status := apu.ReadReg(regControl, 0x40); status&0x80 != 0 {
+		t.Fatalf("expected DMC IRQ flag to be cleared by write, got status %#x", status)
+	}
+}
+
+func TestReadOpenBus(t *testing.T) {
+	apu, _ := newTestAPU()
+	// Writes don't affect the open bus value
+	apu.WriteReg(regPulse1_1, 0xBF)
+	if val := apu.ReadReg(regPulse1_1, 0x40); val != 0x40 {
+		t.Errorf("expected open bus value %#x, got %#x", 0x40, val)
+	}
+	if status := apu.ReadReg(regControl, 0x40); status&0x20 != 0 {
+		t.Errorf("expected bit 5 of status to come from open bus, got %#x", status)
+	}
+	if status := apu.ReadReg(regControl, 0x20); status&0x20 == 0 {
+		t.Errorf("expected bit 5 of status to come from open bus, got %#x", status)
+	}
+}
@@ -2,7 +2,12 @@ package apu
 
 import "sync"
 
-func newBuffer(capacity int) *buffer {
+// bytesPerSample is the size of each sample in the buffer - samples are signed 16-bit little
+// endian PCM.
+const bytesPerSample = 2
+
+func newBuffer(samples int) *buffer {
+	capacity := samples * bytesPerSample
 	return &buffer{
 		bytes:    make([]byte, capacity),
 		capacity: capacity,
@@ -10,15 +15,38 @@ func newBuffer(capacity int) *buffer {
 }
 
 type buffer struct {
-	mu                   sync.RWMutex
+	mu                   sync.Mutex
 	bytes                []byte
 	start, end, capacity int
+
+	// Glitch counters - an underrun occurs when a read can't be completely satisfied, and an
+	// overrun when samples are dropped because the buffer is full
+	underruns,
+	overruns uint64
+}
+
+// Underruns returns the number of reads so far that found the buffer short of data.
+func (b *buffer) Underruns() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.underruns
+}
+
+// Overruns returns the number of times so far that samples were dropped due to a full buffer.
+func (b *buffer) Overruns() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.overruns
 }
 
 func (b *buffer) size() int {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used()
+}
 
+// used returns the number of bytes waiting to be read. Must be called with the lock held.
+func (b *buffer) used() int {
 	result := b.end - b.start
 	if result < 0 {
 		result += b.capacity
@@ -30,8 +58,13 @@ func (b *buffer) push(vals []byte) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Check if the current push is expected to overflow the capacity
-	overflow := b.size()+len(vals) >= b.capacity
+	// start == end indicates an empty buffer, so we can hold one sample less than capacity
+	if overflow := b.used() + len(vals) - (b.capacity - bytesPerSample); overflow > 0 {
+		// Buffer was overrun - drop the oldest samples to make room
+		b.overruns++
+		b.start += overflow
+		b.start %= b.capacity
+	}
 
 	n1 := copy(b.bytes[b.end:], vals)
 	b.end += n1
@@ -42,18 +75,17 @@ func (b *buffer) push(vals []byte) {
 		b.end += n2
 		b.end %= b.capacity
 	}
-
-	if overflow {
-		// Buffer was overrun - make start right after end so that we use the full buffer
-		b.start = (b.end + 1) % b.capacity
-	}
 }
 
 func (b *buffer) Read(out []byte) (int, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.used() < len(out) {
+		b.underruns++
+	}
 
-	if b.end > b.start {
+	if b.end >= b.start {
 		n := copy(out, b.bytes[b.start:b.end])
 		b.start += n
 		b.start %= b.capacity
@@ -0,0 +1,77 @@
+package apu
+
+import (
+	"fmt"
+	"io"
+)
+
+// Channel identifies one of the APU's sound channels.
+type Channel int
+
+const (
+	ChannelPulse1 Channel = iota
+	ChannelPulse2
+	ChannelTriangle
+	ChannelNoise
+	ChannelDMC
+	numChannels
+)
+
+var channelNames = [numChannels]string{
+	ChannelPulse1:   "pulse1",
+	ChannelPulse2:   "pulse2",
+	ChannelTriangle: "triangle",
+	ChannelNoise:    "noise",
+	ChannelDMC:      "dmc",
+}
+
+func (c Channel) String() string {
+	if c < 0 || c >= numChannels {
+		return fmt.Sprintf("Channel(%d)", int(c))
+	}
+	return channelNames[c]
+}
+
+// ParseChannel returns the channel with the given name, as returned by Channel.String.
+func ParseChannel(name string) (Channel, error) {
+	for i, channelName := range channelNames {
+		if name == channelName {
+			return Channel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown APU channel %q", name)
+}
+
+// newCapture creates a capture which fills a buffer if buffered is set, and writes to the recorder
+// if it isn't nil.
+func newCapture(sampleRate uint32, buffered bool, recorder io.Writer) *capture {
+	c := &capture{
+		mixer:    newMixer(float64(sampleRate)),
+		recorder: recorder,
+	}
+	if buffered {
+		c.buf = newBuffer(int(sampleRate) / 2)
+	}
+	return c
+}
+
+// capture holds the resampled output of a single channel, as though it was played alone.
+type capture struct {
+	mixer    *mixer
+	buf      *buffer
+	recorder io.Writer
+	pcm      [bytesPerSample]byte
+}
+
+func (c *capture) step(level float64) {
+	if sample, ok := c.mixer.step(level); ok {
+		c.pcm[0] = byte(sample)
+		c.pcm[1] = byte(uint16(sample) >> 8)
+		if c.buf != nil {
+			c.buf.push(c.pcm[:])
+		}
+		if c.recorder != nil {
+			c.recorder.Write(c.pcm[:])
+		}
+	}
+}
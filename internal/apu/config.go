@@ -1,8 +1,16 @@
 package apu
 
+import "io"
+
 type config struct {
 	trace      bool
 	sampleRate uint32
+	recorder   io.Writer
+	expansion  ExpansionAudio
+	muted,
+	soloed,
+	captured [numChannels]bool
+	channelRecorders [numChannels]io.Writer
 }
 
 const DefaultSampleRate = 44100
@@ -27,3 +35,59 @@ func WithSampleRate(sampleRate uint32) Option {
 		config.sampleRate = sampleRate
 	}
 }
+
+// WithRecorder writes a copy of all mixed output samples to the given writer, as they are
+// produced. Samples are signed 16-bit little endian mono PCM.
+func WithRecorder(recorder io.Writer) Option {
+	return func(config *config) {
+		config.recorder = recorder
+	}
+}
+
+// WithMute silences the given channels in the mixed output.
+func WithMute(channels ...Channel) Option {
+	return func(config *config) {
+		for _, channel := range channels {
+			config.muted[channel] = true
+		}
+	}
+}
+
+// WithSolo silences all channels in the mixed output, except for the given channels. Solo takes
+// precedence over mute.
+func WithSolo(channels ...Channel) Option {
+	return func(config *config) {
+		for _, channel := range channels {
+			config.soloed[channel] = true
+		}
+	}
+}
+
+// WithCapture captures the output of each of the given channels into its own buffer, which is
+// available via APU.CaptureBuffer. Captures are taken before muting and soloing are applied. Like
+// the main buffer, each capture buffer holds half a second of audio, and drops the oldest samples
+// if it isn't read in time.
+func WithCapture(channels ...Channel) Option {
+	return func(config *config) {
+		for _, channel := range channels {
+			config.captured[channel] = true
+		}
+	}
+}
+
+// WithChannelRecorder writes a copy of the given channel's output samples to the given writer, as
+// they are produced, in the same format as WithRecorder. The output is taken before muting and
+// soloing are applied, as with WithCapture.
+func WithChannelRecorder(channel Channel, recorder io.Writer) Option {
+	return func(config *config) {
+		config.channelRecorders[channel] = recorder
+	}
+}
+
+// WithExpansionAudio mixes the output of an expansion audio source, such as a cartridge's sound
+// chip, with the APU channels.
+func WithExpansionAudio(expansion ExpansionAudio) Option {
+	return func(config *config) {
+		config.expansion = expansion
+	}
+}
@@ -0,0 +1,148 @@
+package apu
+
+// http://wiki.nesdev.com/w/index.php/APU_DMC
+
+// dmcRateTable holds the NTSC timer periods, in CPU cycles.
+var dmcRateTable = [16]uint16{
+	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54,
+}
+
+// dmcFetchCycles is the number of CPU cycles stolen by each sample fetch. This varies between 1
+// and 4 on hardware depending on the instruction being executed.
+const dmcFetchCycles = 4
+
+func newDMCChannel(cpu CPU, mem Memory) *dmcChannel {
+	return &dmcChannel{
+		cpu:         cpu,
+		mem:         mem,
+		bufferEmpty: true,
+		bitsLeft:    8,
+		silence:     true,
+	}
+}
+
+type dmcChannel struct {
+	cpu CPU
+	mem Memory
+
+	// Registers
+	irqEnable     bool
+	loopSample    bool
+	freqIndex     byte
+	sampleAddress byte
+	sampleLength  byte
+
+	// Output unit
+	timer    uint16
+	level    byte
+	shift    byte
+	bitsLeft byte
+	silence  bool
+
+	// Memory reader
+	buffer         byte
+	bufferEmpty    bool
+	currentAddress uint16
+	bytesRemaining uint16
+
+	irq bool
+}
+
+func (d *dmcChannel) writeControl(val byte) {
+	d.irqEnable = val>>7&1 == 1
+	d.loopSample = val>>6&1 == 1
+	d.freqIndex = val & 0xF
+	if !d.irqEnable {
+		d.irq = false
+	}
+}
+
+func (d *dmcChannel) writeDirectLoad(val byte) {
+	d.level = val & 0x7F
+}
+
+func (d *dmcChannel) setEnabled(enabled bool) {
+	d.irq = false
+	if !enabled {
+		d.bytesRemaining = 0
+	} else if d.bytesRemaining == 0 {
+		d.restart()
+		// Reader immediately fills the buffer if it is empty
+		d.fetch()
+	}
+}
+
+func (d *dmcChannel) restart() {
+	d.currentAddress = 0xC000 | uint16(d.sampleAddress)<<6
+	d.bytesRemaining = uint16(d.sampleLength)<<4 | 1
+}
+
+// active reports whether there are still sample bytes left to be read.
+func (d *dmcChannel) active() bool {
+	return d.bytesRemaining > 0
+}
+
+// clockTimer is called every CPU cycle.
+func (d *dmcChannel) clockTimer() {
+	if d.timer > 0 {
+		d.timer--
+		return
+	}
+	d.timer = dmcRateTable[d.freqIndex] - 1
+
+	if !d.silence {
+		if d.shift&1 == 1 {
+			if d.level <= 125 {
+				d.level += 2
+			}
+		} else if d.level >= 2 {
+			d.level -= 2
+		}
+	}
+	d.shift >>= 1
+
+	d.bitsLeft--
+	if d.bitsLeft == 0 {
+		d.bitsLeft = 8
+		if d.bufferEmpty {
+			d.silence = true
+		} else {
+			d.silence = false
+			d.shift = d.buffer
+			d.bufferEmpty = true
+			d.fetch()
+		}
+	}
+}
+
+// fetch refills the sample buffer from memory via DMA, if it is empty and the sample has not
+// finished.
+func (d *dmcChannel) fetch() {
+	if !d.bufferEmpty || d.bytesRemaining == 0 {
+		return
+	}
+
+	d.cpu.Sleep(dmcFetchCycles)
+	d.buffer = d.mem.Read(d.currentAddress)
+	d.bufferEmpty = false
+
+	if d.currentAddress == 0xFFFF {
+		d.currentAddress = 0x8000
+	} else {
+		d.currentAddress++
+	}
+
+	d.bytesRemaining--
+	if d.bytesRemaining == 0 {
+		if d.loopSample {
+			d.restart()
+		} else if d.irqEnable {
+			d.irq = true
+		}
+	}
+}
+
+// output returns the current channel level, from 0-127.
+func (d *dmcChannel) output() byte {
+	return d.level
+}
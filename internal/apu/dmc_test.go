@@ -0,0 +1,74 @@
+package apu
+
+import "testing"
+
+func TestDMCAddressWrap(t *testing.T) {
+	d := newDMCChannel(&testCPU{}, testMemory{})
+	d.currentAddress = 0xFFFE
+	d.bytesRemaining = 3
+
+	// Reads continue from $8000 after $FFFF
+	for _, expected := range []uint16{0xFFFE, 0xFFFF, 0x8000} {
+		if d.currentAddress != expected {
+			t.Fatalf("expected fetch from %#x, got %#x", expected, d.currentAddress)
+		}
+		d.fetch()
+		if d.buffer != byte(expected) {
+			t.Fatalf("expected buffer %#x, got %#x", byte(expected), d.buffer)
+		}
+		d.bufferEmpty = true
+	}
+	if d.currentAddress != 0x8001 {
+		t.Errorf("expected address %#x after the sample, got %#x", 0x8001, d.currentAddress)
+	}
+}
+
+func TestDMCSampleEnd(t *testing.T) {
+	tests := []struct {
+		name      string
+		control   byte
+		irq       bool
+		remaining uint16
+	}{
+		{"IRQ", 0x80, true, 0},
+		{"no IRQ", 0x00, false, 0},
+		// Looping restarts the sample instead of raising the IRQ
+		{"loop", 0xC0, false, 0x11},
+	}
+	for _, test := range tests {
+		cpu := &testCPU{}
+		d := newDMCChannel(cpu, testMemory{})
+		d.writeControl(test.control)
+		// Sample at $C040, 17 bytes long
+		d.sampleAddress = 1
+		d.sampleLength = 1
+		d.setEnabled(true)
+		for i := 1; i < 17; i++ {
+			if d.irq {
+				t.Fatalf("%s: unexpected IRQ after %d bytes", test.name, i)
+			}
+			d.bufferEmpty = true
+			d.fetch()
+		}
+		if d.irq != test.irq {
+			t.Errorf("%s: expected IRQ %v, got %v", test.name, test.irq, d.irq)
+		}
+		if d.bytesRemaining != test.remaining {
+			t.Errorf("%s: expected %d bytes remaining, got %d", test.name, test.remaining, d.bytesRemaining)
+		}
+		if test.remaining > 0 && d.currentAddress != 0xC040 {
+			t.Errorf("%s: expected restart from $C040, got %#x", test.name, d.currentAddress)
+		}
+		if cpu.slept != 17*dmcFetchCycles {
+			t.Errorf("%s: expected %d stalled cycles, got %d", test.name, 17*dmcFetchCycles, cpu.slept)
+		}
+	}
+
+	// Clearing the IRQ enable acknowledges the IRQ
+	d := newDMCChannel(&testCPU{}, testMemory{})
+	d.irq = true
+	d.writeControl(0x00)
+	if d.irq {
+		t.Error("expected clearing IRQ enable to clear the IRQ")
+	}
+}
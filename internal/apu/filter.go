@@ -0,0 +1,46 @@
+package apu
+
+import "math"
+
+// First-order filters, used to approximate the NES analog output stage.
+// http://wiki.nesdev.com/w/index.php/APU_Mixer
+
+func newHighPassFilter(sampleRate, cutoff float64) *highPassFilter {
+	rc := 1 / (2 * math.Pi * cutoff)
+	dt := 1 / sampleRate
+	return &highPassFilter{
+		alpha: rc / (rc + dt),
+	}
+}
+
+type highPassFilter struct {
+	alpha,
+	prevIn,
+	prevOut float64
+}
+
+func (f *highPassFilter) step(in float64) float64 {
+	out := f.alpha * (f.prevOut + in - f.prevIn)
+	f.prevIn = in
+	f.prevOut = out
+	return out
+}
+
+func newLowPassFilter(sampleRate, cutoff float64) *lowPassFilter {
+	rc := 1 / (2 * math.Pi * cutoff)
+	dt := 1 / sampleRate
+	return &lowPassFilter{
+		alpha: dt / (rc + dt),
+	}
+}
+
+type lowPassFilter struct {
+	alpha,
+	prevOut float64
+}
+
+func (f *lowPassFilter) step(in float64) float64 {
+	out := f.prevOut + f.alpha*(in-f.prevOut)
+	f.prevOut = out
+	return out
+}
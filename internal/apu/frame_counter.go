@@ -0,0 +1,94 @@
+package apu
+
+// http://wiki.nesdev.com/w/index.php/APU_Frame_Counter
+
+// Sequencer step timings for NTSC, in CPU cycles since the start of the sequence.
+const (
+	frameStep1      = 7457
+	frameStep2      = 14913
+	frameStep3      = 22371
+	frameStep4      = 29829
+	frameStep5      = 37281
+	frameFourLength = 29830
+	frameFiveLength = 37282
+)
+
+type frameCounter struct {
+	// Registers
+	fiveStep,
+	irqInhibit bool
+
+	// State
+	cycle uint32
+	// resetDelay counts down the CPU cycles until a write to $4017 takes effect
+	resetDelay byte
+	irq        bool
+}
+
+func (f *frameCounter) write(val byte, oddCycle bool) {
+	f.fiveStep = val>>7&1 == 1
+	f.irqInhibit = val>>6&1 == 1
+	if f.irqInhibit {
+		f.irq = false
+	}
+
+	// Sequencer is reset 3 or 4 CPU cycles after the write, depending on alignment with the APU
+	// clock
+	if oddCycle {
+		f.resetDelay = 4
+	} else {
+		f.resetDelay = 3
+	}
+}
+
+// step advances the frame counter by a CPU cycle, and reports whether the quarter and half frame
+// units should be clocked as a result.
+func (f *frameCounter) step() (quarter, half bool) {
+	if f.resetDelay > 0 {
+		f.resetDelay--
+		if f.resetDelay == 0 {
+			f.cycle = 0
+			// Writing with the 5-step mode bit set clocks all units immediately
+			return f.fiveStep, f.fiveStep
+		}
+	}
+
+	f.cycle++
+	switch f.cycle {
+	case frameStep1, frameStep3:
+		quarter = true
+
+	case frameStep2:
+		quarter, half = true, true
+
+	case frameStep4 - 1:
+		if !f.fiveStep {
+			f.setIRQ()
+		}
+
+	case frameStep4:
+		if !f.fiveStep {
+			quarter, half = true, true
+			f.setIRQ()
+		}
+
+	case frameFourLength:
+		if !f.fiveStep {
+			f.setIRQ()
+			f.cycle = 0
+		}
+
+	case frameStep5:
+		quarter, half = true, true
+
+	case frameFiveLength:
+		f.cycle = 0
+	}
+	return quarter, half
+}
+
+func (f *frameCounter) setIRQ() {
+	if !f.irqInhibit {
+		f.irq = true
+	}
+}
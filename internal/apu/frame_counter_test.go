@@ -0,0 +1,99 @@
+package apu
+
+import "testing"
+
+// testFrameSequence runs the frame counter for a full sequence after a write, and returns the
+// cycles of each quarter and half frame clock, along with the cycles the IRQ flag was raised.
+func testFrameSequence(val byte, cycles int) (quarters, halves, irqs []int) {
+	var f frameCounter
+	f.write(val, false)
+	for cycle := 1; cycle <= cycles; cycle++ {
+		quarter, half := f.step()
+		if quarter {
+			quarters = append(quarters, cycle)
+		}
+		if half {
+			halves = append(halves, cycle)
+		}
+		if f.irq {
+			irqs = append(irqs, cycle)
+			f.irq = false
+		}
+	}
+	return quarters, halves, irqs
+}
+
+func equalCycles(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFrameCounterSequences(t *testing.T) {
+	// The sequencer is reset 3 cycles after an even cycle write
+	const reset = 3
+	tests := []struct {
+		name     string
+		val      byte
+		quarters []int
+		halves   []int
+		irqs     []int
+	}{
+		{
+			"4-step",
+			0x00,
+			[]int{reset + frameStep1, reset + frameStep2, reset + frameStep3, reset + frameStep4},
+			[]int{reset + frameStep2, reset + frameStep4},
+			[]int{reset + frameStep4 - 1, reset + frameStep4, reset + frameFourLength},
+		},
+		{
+			"4-step inhibited",
+			0x40,
+			[]int{reset + frameStep1, reset + frameStep2, reset + frameStep3, reset + frameStep4},
+			[]int{reset + frameStep2, reset + frameStep4},
+			nil,
+		},
+		{
+			// Writing with the 5-step bit set clocks both units immediately
+			"5-step",
+			0x80,
+			[]int{reset, reset + frameStep1, reset + frameStep2, reset + frameStep3, reset + frameStep5},
+			[]int{reset, reset + frameStep2, reset + frameStep5},
+			nil,
+		},
+	}
+	for _, test := range tests {
+		quarters, halves, irqs := testFrameSequence(test.val, reset+frameFiveLength-1)
+		if !equalCycles(quarters, test.quarters) {
+			t.Errorf("%s: expected quarter frames at %v, got %v", test.name, test.quarters, quarters)
+		}
+		if !equalCycles(halves, test.halves) {
+			t.Errorf("%s: expected half frames at %v, got %v", test.name, test.halves, halves)
+		}
+		if !equalCycles(irqs, test.irqs) {
+			t.Errorf("%s: expected IRQs at %v, got %v", test.name, test.irqs, irqs)
+		}
+	}
+}
+
+func TestFrameCounterResetDelay(t *testing.T) {
+	for _, test := range []struct {
+		oddCycle bool
+		delay    int
+	}{{false, 3}, {true, 4}} {
+		var f frameCounter
+		f.write(0x80, test.oddCycle)
+		for cycle := 1; cycle <= test.delay; cycle++ {
+			quarter, _ := f.step()
+			if quarter != (cycle == test.delay) {
+				t.Errorf("odd cycle %v: unexpected quarter frame %v at cycle %d", test.oddCycle, quarter, cycle)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package apu
+
+import "math"
+
+// http://wiki.nesdev.com/w/index.php/APU_Mixer
+
+// Lookup tables approximating the non-linear DAC outputs, indexed by the sum of channel levels.
+var (
+	pulseTable = func() (table [31]float64) {
+		for i := 1; i < len(table); i++ {
+			table[i] = 95.52 / (8128.0/float64(i) + 100)
+		}
+		return table
+	}()
+	tndTable = func() (table [203]float64) {
+		for i := 1; i < len(table); i++ {
+			table[i] = 163.67 / (24329.0/float64(i) + 100)
+		}
+		return table
+	}()
+)
+
+func newMixer(sampleRate float64) *mixer {
+	return &mixer{
+		highPass90:  newHighPassFilter(cpuClockRate, 90),
+		highPass440: newHighPassFilter(cpuClockRate, 440),
+		lowPass14k:  newLowPassFilter(cpuClockRate, 14000),
+		resampler:   newResampler(sampleRate),
+	}
+}
+
+// mixer combines the channel levels into a single output, filtered to match the console's
+// output stage and resampled to the output rate.
+type mixer struct {
+	highPass90,
+	highPass440 *highPassFilter
+	lowPass14k *lowPassFilter
+	resampler  *resampler
+}
+
+// mix combines the given channel levels into an output level, from 0.0-1.0.
+func mix(levels [numChannels]byte) float64 {
+	pulse := levels[ChannelPulse1] + levels[ChannelPulse2]
+	tnd := 3*int(levels[ChannelTriangle]) + 2*int(levels[ChannelNoise]) + int(levels[ChannelDMC])
+	return pulseTable[pulse] + tndTable[tnd]
+}
+
+// step runs a single CPU cycle's output through the mixer, and returns an output sample when one
+// is ready.
+func (m *mixer) step(level float64) (int16, bool) {
+	level = m.highPass90.step(level)
+	level = m.highPass440.step(level)
+	level = m.lowPass14k.step(level)
+
+	out, ok := m.resampler.push(level)
+	if !ok {
+		return 0, false
+	}
+	return toPCM(out), true
+}
+
+// toPCM converts a level from -1.0-1.0 into a signed 16-bit sample.
+func toPCM(level float64) int16 {
+	return int16(math.Max(-1, math.Min(1, level)) * math.MaxInt16)
+}
@@ -0,0 +1,199 @@
+package apu
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestResamplerRate(t *testing.T) {
+	r := newResampler(DefaultSampleRate)
+	var samples int
+	for i := 0; i < cpuClockRate; i++ {
+		if _, ok := r.push(0); ok {
+			samples++
+		}
+	}
+	if math.Abs(float64(samples-DefaultSampleRate)) > 1 {
+		t.Errorf("expected %d samples for one second of input, got %d", DefaultSampleRate, samples)
+	}
+}
+
+func TestResamplerBandLimited(t *testing.T) {
+	amplitude := func(freq float64) float64 {
+		r := newResampler(DefaultSampleRate)
+		var peak float64
+		for i := 0; i < int(cpuClockRate)/10; i++ {
+			out, ok := r.push(math.Sin(2 * math.Pi * freq * float64(i) / cpuClockRate))
+			// Skip the filter warm-up
+			if ok && i > int(cpuClockRate)/100 {
+				peak = math.Max(peak, math.Abs(out))
+			}
+		}
+		return peak
+	}
+
+	if peak := amplitude(1000); peak < 0.95 {
+		t.Errorf("expected 1kHz to pass through, got peak %f", peak)
+	}
+	if peak := amplitude(30000); peak > 0.05 {
+		t.Errorf("expected 30kHz to be filtered out, got peak %f", peak)
+	}
+}
+
+func TestAPUOutput(t *testing.T) {
+	apu, _ := newTestAPU()
+	apu.WriteReg(regFrameCounter, 0x40)
+	apu.WriteReg(regControl, 0x01)
+	// 50% duty, constant volume 15, ~440Hz
+	apu.WriteReg(regPulse1_1, 0xBF)
+	apu.WriteReg(regPulse1_3, 0xFD)
+	apu.WriteReg(regPulse1_4, 0x00)
+
+	for i := 0; i < int(cpuClockRate)/10; i++ {
+		apu.Step()
+	}
+
+	out := make([]byte, apu.Buffer().size())
+	n, _ := apu.Buffer().Read(out)
+	if expected := DefaultSampleRate / 10 * bytesPerSample; math.Abs(float64(n-expected)) > bytesPerSample {
+		t.Fatalf("expected %d bytes of output, got %d", expected, n)
+	}
+	var peak int16
+	for i := 0; i+1 < n; i += bytesPerSample {
+		sample := int16(uint16(out[i]) | uint16(out[i+1])<<8)
+		if sample > peak {
+			peak = sample
+		}
+	}
+	if peak < 1000 {
+		t.Errorf("expected audible pulse output, got peak %d", peak)
+	}
+}
+
+func TestAPUSoloAndCapture(t *testing.T) {
+	cpu := &testCPU{}
+	apu := NewAPU(cpu, testMemory{}, WithSolo(ChannelNoise), WithCapture(ChannelPulse1, ChannelDMC))
+	apu.Reset()
+	apu.WriteReg(regFrameCounter, 0x40)
+	apu.WriteReg(regControl, 0x01)
+	apu.WriteReg(regPulse1_1, 0xBF)
+	apu.WriteReg(regPulse1_3, 0xFD)
+	apu.WriteReg(regPulse1_4, 0x00)
+
+	for i := 0; i < int(cpuClockRate)/10; i++ {
+		apu.Step()
+	}
+
+	peak := func(buf *buffer) int16 {
+		out := make([]byte, buf.size())
+		n, _ := buf.Read(out)
+		var peak int16
+		for i := 0; i+1 < n; i += bytesPerSample {
+			if sample := int16(uint16(out[i]) | uint16(out[i+1])<<8); sample > peak {
+				peak = sample
+			}
+		}
+		return peak
+	}
+
+	if p := peak(apu.Buffer()); p != 0 {
+		t.Errorf("expected silent mix with only noise soloed, got peak %d", p)
+	}
+	if p := peak(apu.CaptureBuffer(ChannelPulse1)); p < 1000 {
+		t.Errorf("expected pulse 1 capture to be audible, got peak %d", p)
+	}
+	if p := peak(apu.CaptureBuffer(ChannelDMC)); p != 0 {
+		t.Errorf("expected silent DMC capture, got peak %d", p)
+	}
+	if apu.CaptureBuffer(ChannelTriangle) != nil {
+		t.Error("expected no capture buffer for triangle")
+	}
+}
+
+func TestChannelRecorder(t *testing.T) {
+	cpu := &testCPU{}
+	var recorder bytes.Buffer
+	apu := NewAPU(cpu, testMemory{}, WithMute(ChannelPulse1), WithChannelRecorder(ChannelPulse1, &recorder))
+	apu.Reset()
+	apu.WriteReg(regFrameCounter, 0x40)
+	apu.WriteReg(regControl, 0x01)
+	apu.WriteReg(regPulse1_1, 0xBF)
+	apu.WriteReg(regPulse1_3, 0xFD)
+	apu.WriteReg(regPulse1_4, 0x00)
+
+	// Longer than a buffer holds, to check that nothing is dropped
+	for i := 0; i < cpuClockRate; i++ {
+		apu.Step()
+	}
+
+	out := recorder.Bytes()
+	if expected := DefaultSampleRate * bytesPerSample; math.Abs(float64(len(out)-expected)) > bytesPerSample {
+		t.Fatalf("expected %d bytes of recorded output, got %d", expected, len(out))
+	}
+	var peak int16
+	for i := 0; i+1 < len(out); i += bytesPerSample {
+		if sample := int16(uint16(out[i]) | uint16(out[i+1])<<8); sample > peak {
+			peak = sample
+		}
+	}
+	if peak < 1000 {
+		t.Errorf("expected muted pulse 1 to be recorded, got peak %d", peak)
+	}
+	if apu.CaptureBuffer(ChannelPulse1) != nil {
+		t.Error("expected no capture buffer when only recording")
+	}
+}
+
+func TestBufferOverrun(t *testing.T) {
+	b := newBuffer(4)
+	b.push([]byte{1, 1, 2, 2, 3, 3})
+	b.push([]byte{4, 4})
+
+	out := make([]byte, 8)
+	n, _ := b.Read(out)
+	// Capacity is one sample less than allocated, and the oldest samples are dropped
+	expected := []byte{2, 2, 3, 3, 4, 4}
+	if n != len(expected) {
+		t.Fatalf("expected %d bytes, got %d", len(expected), n)
+	}
+	for i := range expected {
+		if out[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, out[:n])
+		}
+	}
+}
+
+// testExpansion outputs a square wave, toggling every 2000 CPU cycles.
+type testExpansion struct {
+	cycles int
+}
+
+func (e *testExpansion) AudioOutput() float64 {
+	e.cycles++
+	if (e.cycles/2000)%2 == 0 {
+		return 0.2
+	}
+	return 0
+}
+
+func TestExpansionAudio(t *testing.T) {
+	apu := NewAPU(&testCPU{}, testMemory{}, WithExpansionAudio(&testExpansion{}))
+	apu.Reset()
+	for i := 0; i < int(cpuClockRate)/10; i++ {
+		apu.Step()
+	}
+
+	out := make([]byte, apu.Buffer().size())
+	n, _ := apu.Buffer().Read(out)
+	var peak int16
+	for i := 0; i+1 < n; i += bytesPerSample {
+		sample := int16(uint16(out[i]) | uint16(out[i+1])<<8)
+		if sample > peak {
+			peak = sample
+		}
+	}
+	if peak < 1000 {
+		t.Errorf("expected audible expansion output, got peak %d", peak)
+	}
+}
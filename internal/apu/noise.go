@@ -0,0 +1,77 @@
+package apu
+
+// http://wiki.nesdev.com/w/index.php/APU_Noise
+
+// noisePeriodTable holds the NTSC timer periods, in CPU cycles.
+var noisePeriodTable = [16]uint16{
+	4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068,
+}
+
+func newNoiseChannel() *noiseChannel {
+	return &noiseChannel{
+		// Shift register is loaded with 1 on power-up
+		shift: 1,
+	}
+}
+
+type noiseChannel struct {
+	// Registers
+	loopNoise bool
+	period    byte
+
+	// State
+	envelope Envelope
+	length   LengthCounter
+	timer    uint16
+	shift    uint16
+}
+
+func (n *noiseChannel) writeControl(val byte) {
+	n.length.Halt = val>>5&1 == 1
+	n.envelope.Write(val)
+}
+
+func (n *noiseChannel) writePeriod(val byte) {
+	n.loopNoise = val>>7&1 == 1
+	n.period = val & 0xF
+}
+
+func (n *noiseChannel) writeLength(val byte) {
+	n.length.Load(val >> 3)
+	n.envelope.Restart()
+}
+
+// clockTimer is called every CPU cycle.
+func (n *noiseChannel) clockTimer() {
+	if n.timer > 0 {
+		n.timer--
+		return
+	}
+	n.timer = noisePeriodTable[n.period] - 1
+
+	// Feedback comes from bit 6 in loop ("short") mode, and bit 1 otherwise
+	var feedback uint16
+	if n.loopNoise {
+		feedback = (n.shift ^ n.shift>>6) & 1
+	} else {
+		feedback = (n.shift ^ n.shift>>1) & 1
+	}
+	n.shift >>= 1
+	n.shift |= feedback << 14
+}
+
+func (n *noiseChannel) clockQuarterFrame() {
+	n.envelope.Clock()
+}
+
+func (n *noiseChannel) clockHalfFrame() {
+	n.length.Clock()
+}
+
+// output returns the current channel level, from 0-15.
+func (n *noiseChannel) output() byte {
+	if !n.length.Active() || n.shift&1 == 1 {
+		return 0
+	}
+	return n.envelope.Volume()
+}
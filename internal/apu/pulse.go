@@ -0,0 +1,127 @@
+package apu
+
+// http://wiki.nesdev.com/w/index.php/APU_Pulse
+
+var dutyTable = [4][8]byte{
+	{0, 1, 0, 0, 0, 0, 0, 0},
+	{0, 1, 1, 0, 0, 0, 0, 0},
+	{0, 1, 1, 1, 1, 0, 0, 0},
+	{1, 0, 0, 1, 1, 1, 1, 1},
+}
+
+// PulseDuty returns whether a pulse wave with the given duty cycle (0-3) is high at a step (0-7) of
+// its sequence.
+func PulseDuty(duty, step byte) bool {
+	return dutyTable[duty&3][step&7] == 1
+}
+
+func newPulseChannel(onesComplement bool) *pulseChannel {
+	return &pulseChannel{
+		onesComplement: onesComplement,
+	}
+}
+
+type pulseChannel struct {
+	// Pulse 1 negates the sweep change with ones' complement, pulse 2 uses two's complement
+	onesComplement bool
+
+	// Registers
+	duty            byte
+	sweepEnabled    bool
+	sweepPeriod     byte
+	sweepNegative   bool
+	sweepShiftCount byte
+	timerLoad       uint16
+
+	// State
+	envelope     Envelope
+	length       LengthCounter
+	timer        uint16
+	dutyPos      byte
+	sweepReload  bool
+	sweepDivider byte
+}
+
+func (p *pulseChannel) writeControl(val byte) {
+	p.duty = val >> 6
+	p.length.Halt = val>>5&1 == 1
+	p.envelope.Write(val)
+}
+
+func (p *pulseChannel) writeSweep(val byte) {
+	p.sweepEnabled = val>>7&1 == 1
+	p.sweepPeriod = val >> 4 & 0x7
+	p.sweepNegative = val>>3&1 == 1
+	p.sweepShiftCount = val & 0x7
+	p.sweepReload = true
+}
+
+func (p *pulseChannel) writeTimerLow(val byte) {
+	p.timerLoad &= 0xFF00
+	p.timerLoad |= uint16(val)
+}
+
+func (p *pulseChannel) writeTimerHigh(val byte) {
+	p.timerLoad &= 0x00FF
+	p.timerLoad |= uint16(val&0x7) << 8
+	p.length.Load(val >> 3)
+	// Sequencer is restarted, but the timer divider is not
+	p.dutyPos = 0
+	p.envelope.Restart()
+}
+
+// clockTimer is called every APU cycle (every second CPU cycle).
+func (p *pulseChannel) clockTimer() {
+	if p.timer > 0 {
+		p.timer--
+		return
+	}
+	p.timer = p.timerLoad
+	p.dutyPos = (p.dutyPos + 1) & 7
+}
+
+func (p *pulseChannel) clockQuarterFrame() {
+	p.envelope.Clock()
+}
+
+func (p *pulseChannel) clockHalfFrame() {
+	p.length.Clock()
+
+	if p.sweepDivider == 0 && p.sweepEnabled && p.sweepShiftCount > 0 && !p.sweepMuted() {
+		p.timerLoad = p.sweepTarget()
+	}
+	if p.sweepDivider == 0 || p.sweepReload {
+		p.sweepDivider = p.sweepPeriod
+		p.sweepReload = false
+	} else {
+		p.sweepDivider--
+	}
+}
+
+// sweepTarget calculates the period that the sweep unit is currently moving towards. This is
+// evaluated continuously, regardless of whether the sweep is enabled.
+func (p *pulseChannel) sweepTarget() uint16 {
+	change := p.timerLoad >> p.sweepShiftCount
+	if !p.sweepNegative {
+		return p.timerLoad + change
+	}
+	if p.onesComplement {
+		change++
+	}
+	if change > p.timerLoad {
+		return 0
+	}
+	return p.timerLoad - change
+}
+
+func (p *pulseChannel) sweepMuted() bool {
+	return p.timerLoad < 8 || p.sweepTarget() > 0x7FF
+}
+
+// output returns the current channel level, from 0-15.
+func (p *pulseChannel) output() byte {
+	if !p.length.Active() || p.sweepMuted() || !PulseDuty(p.duty, p.dutyPos) {
+		return 0
+	}
+	return p.envelope.Volume()
+}
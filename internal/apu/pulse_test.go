@@ -0,0 +1,60 @@
+package apu
+
+import "testing"
+
+func TestPulseSweep(t *testing.T) {
+	tests := []struct {
+		name           string
+		onesComplement bool
+		timer          uint16
+		sweep          byte
+		target         uint16
+		muted          bool
+	}{
+		{"add", false, 0x100, 0x81, 0x180, false},
+		{"add overflow mutes", false, 0x600, 0x81, 0x900, true},
+		{"pulse 2 negate", false, 0x100, 0x89, 0x080, false},
+		{"pulse 1 negate", true, 0x100, 0x89, 0x07F, false},
+		{"pulse 1 negate to zero", true, 0x008, 0x88, 0x000, false},
+		// The target is calculated even with the sweep disabled, and can still mute
+		{"disabled overflow mutes", false, 0x7FF, 0x01, 0xBFE, true},
+		{"low period mutes", false, 0x007, 0x00, 0x00E, true},
+	}
+	for _, test := range tests {
+		p := newPulseChannel(test.onesComplement)
+		p.writeTimerLow(byte(test.timer))
+		p.writeTimerHigh(byte(test.timer >> 8))
+		p.writeSweep(test.sweep)
+		if target := p.sweepTarget(); target != test.target {
+			t.Errorf("%s: expected sweep target %#x, got %#x", test.name, test.target, target)
+		}
+		if muted := p.sweepMuted(); muted != test.muted {
+			t.Errorf("%s: expected muted %v, got %v", test.name, test.muted, muted)
+		}
+	}
+}
+
+func TestPulseSweepUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		timer    uint16
+		sweep    byte
+		expected uint16
+	}{
+		{"enabled", 0x100, 0x81, 0x180},
+		{"disabled", 0x100, 0x01, 0x100},
+		{"zero shift", 0x100, 0x80, 0x100},
+		{"muted", 0x600, 0x81, 0x600},
+	}
+	for _, test := range tests {
+		p := newPulseChannel(false)
+		p.writeTimerLow(byte(test.timer))
+		p.writeTimerHigh(byte(test.timer >> 8))
+		p.writeSweep(test.sweep)
+		// The divider starts at 0, so the first half frame updates the period
+		p.clockHalfFrame()
+		if p.timerLoad != test.expected {
+			t.Errorf("%s: expected period %#x, got %#x", test.name, test.expected, p.timerLoad)
+		}
+	}
+}
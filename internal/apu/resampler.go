@@ -0,0 +1,104 @@
+package apu
+
+import "math"
+
+// cpuClockRate is the NTSC CPU clock rate in Hz, which is the rate the APU produces samples at.
+const cpuClockRate = 1789773.0
+
+const (
+	// oversample is the ratio between the intermediate rate and the output sample rate
+	oversample = 4
+	// firTaps is the length of the anti-aliasing filter used for the final decimation
+	firTaps = 48
+)
+
+// resampler converts from the CPU clock rate down to the output sample rate. Input is first
+// integrated down to an intermediate rate at a multiple of the output rate - this box filter
+// handles the arbitrary (non-integer) ratio. The intermediate samples are then band-limited to
+// below the output Nyquist frequency with a windowed sinc filter, and decimated.
+func newResampler(sampleRate float64) *resampler {
+	r := &resampler{
+		sampleRate: sampleRate,
+	}
+	r.setRatio(1)
+	r.kernel = firKernel(0.45 / oversample)
+	return r
+}
+
+type resampler struct {
+	sampleRate float64
+
+	// Box filter state, in CPU cycles
+	cyclesPerSample,
+	sum,
+	weight float64
+
+	// Decimation filter state
+	kernel  [firTaps]float64
+	history [firTaps]float64
+	pos,
+	phase int
+}
+
+// setRatio adjusts the rate at which samples are produced, relative to the configured sample
+// rate. A ratio above 1 produces more samples for the same amount of input.
+func (r *resampler) setRatio(ratio float64) {
+	r.cyclesPerSample = cpuClockRate / (r.sampleRate * oversample * ratio)
+}
+
+// push adds a sample at the CPU clock rate, and returns an output sample when one is ready.
+func (r *resampler) push(sample float64) (float64, bool) {
+	remaining := r.cyclesPerSample - r.weight
+	if remaining < 0 {
+		// Ratio was increased mid-sample
+		remaining = 0
+	}
+	if remaining > 1 {
+		r.sum += sample
+		r.weight++
+		return 0, false
+	}
+
+	// Intermediate sample boundary falls within this cycle - split the input between the two
+	intermediate := (r.sum + sample*remaining) / (r.weight + remaining)
+	r.sum = sample * (1 - remaining)
+	r.weight = 1 - remaining
+
+	r.history[r.pos] = intermediate
+	r.pos = (r.pos + 1) % firTaps
+	r.phase++
+	if r.phase < oversample {
+		return 0, false
+	}
+	r.phase = 0
+
+	var out float64
+	for i, coeff := range r.kernel {
+		out += coeff * r.history[(r.pos+i)%firTaps]
+	}
+	return out, true
+}
+
+// firKernel builds a Blackman-windowed sinc low-pass filter with the given cutoff, as a fraction
+// of the sample rate. The kernel is normalized to unity gain.
+func firKernel(cutoff float64) [firTaps]float64 {
+	var kernel [firTaps]float64
+	var sum float64
+	center := float64(firTaps-1) / 2
+	for i := range kernel {
+		x := float64(i) - center
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		window := 0.42 -
+			0.5*math.Cos(2*math.Pi*float64(i)/float64(firTaps-1)) +
+			0.08*math.Cos(4*math.Pi*float64(i)/float64(firTaps-1))
+		kernel[i] = sinc * window
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
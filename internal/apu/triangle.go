@@ -0,0 +1,79 @@
+package apu
+
+// http://wiki.nesdev.com/w/index.php/APU_Triangle
+
+var triangleTable = [32]byte{
+	15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0,
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+func newTriangleChannel() *triangleChannel {
+	return &triangleChannel{}
+}
+
+type triangleChannel struct {
+	// Registers
+	// counterDisable doubles as the length counter halt flag
+	counterDisable   bool
+	counterReloadVal byte
+	timerLoad        uint16
+
+	// State
+	length        LengthCounter
+	linearCounter byte
+	linearReload  bool
+	timer         uint16
+	seqPos        byte
+}
+
+func (t *triangleChannel) writeControl(val byte) {
+	t.counterDisable = val>>7&1 == 1
+	t.length.Halt = t.counterDisable
+	t.counterReloadVal = val & 0x7F
+}
+
+func (t *triangleChannel) writeTimerLow(val byte) {
+	t.timerLoad &= 0xFF00
+	t.timerLoad |= uint16(val)
+}
+
+func (t *triangleChannel) writeTimerHigh(val byte) {
+	t.timerLoad &= 0x00FF
+	t.timerLoad |= uint16(val&0x7) << 8
+	t.length.Load(val >> 3)
+	t.linearReload = true
+}
+
+// clockTimer is called every CPU cycle.
+func (t *triangleChannel) clockTimer() {
+	if t.timer > 0 {
+		t.timer--
+		return
+	}
+	t.timer = t.timerLoad
+	// Sequencer only advances while both counters are non-zero - otherwise it holds the
+	// current level rather than silencing
+	if t.length.Active() && t.linearCounter > 0 {
+		t.seqPos = (t.seqPos + 1) & 0x1F
+	}
+}
+
+func (t *triangleChannel) clockQuarterFrame() {
+	if t.linearReload {
+		t.linearCounter = t.counterReloadVal
+	} else if t.linearCounter > 0 {
+		t.linearCounter--
+	}
+	if !t.counterDisable {
+		t.linearReload = false
+	}
+}
+
+func (t *triangleChannel) clockHalfFrame() {
+	t.length.Clock()
+}
+
+// output returns the current channel level, from 0-15.
+func (t *triangleChannel) output() byte {
+	return triangleTable[t.seqPos]
+}
@@ -0,0 +1,103 @@
+package apu
+
+// Shared building blocks used by multiple channels. These are exported for cartridge expansion
+// audio, which copies some of the APU's channels.
+
+var lengthTable = [32]byte{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+// LengthCounter silences a channel after a given number of half frames.
+// http://wiki.nesdev.com/w/index.php/APU_Length_Counter
+type LengthCounter struct {
+	// Halt stops the counter from being clocked
+	Halt bool
+
+	enabled bool
+	value   byte
+}
+
+// SetEnabled enables loading the counter, or clears it if disabled.
+func (l *LengthCounter) SetEnabled(enabled bool) {
+	l.enabled = enabled
+	if !enabled {
+		l.value = 0
+	}
+}
+
+// Load reloads the counter from the length table, if enabled.
+func (l *LengthCounter) Load(index byte) {
+	if l.enabled {
+		l.value = lengthTable[index&0x1F]
+	}
+}
+
+// Clock counts down once, unless halted.
+func (l *LengthCounter) Clock() {
+	if !l.Halt && l.value > 0 {
+		l.value--
+	}
+}
+
+// Active returns whether the counter hasn't reached zero.
+func (l *LengthCounter) Active() bool {
+	return l.value > 0
+}
+
+// Envelope generates either a constant volume or a decaying sawtooth, used by the pulse and
+// noise channels.
+// http://wiki.nesdev.com/w/index.php/APU_Envelope
+type Envelope struct {
+	// Registers
+	loop,
+	constantVol bool
+	period byte
+
+	// State
+	start bool
+	divider,
+	decay byte
+}
+
+// Write sets the loop flag, constant volume flag and volume/period from a channel's control
+// register.
+func (e *Envelope) Write(val byte) {
+	e.loop = val>>5&1 == 1
+	e.constantVol = val>>4&1 == 1
+	e.period = val & 0xF
+}
+
+// Restart starts the decay from full volume on the next clock.
+func (e *Envelope) Restart() {
+	e.start = true
+}
+
+// Clock is called every quarter frame.
+func (e *Envelope) Clock() {
+	if e.start {
+		e.start = false
+		e.decay = 15
+		e.divider = e.period
+		return
+	}
+
+	if e.divider > 0 {
+		e.divider--
+		return
+	}
+	e.divider = e.period
+	if e.decay > 0 {
+		e.decay--
+	} else if e.loop {
+		e.decay = 15
+	}
+}
+
+// Volume returns the current volume, from 0-15.
+func (e *Envelope) Volume() byte {
+	if e.constantVol {
+		return e.period
+	}
+	return e.decay
+}
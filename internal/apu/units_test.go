@@ -0,0 +1,48 @@
+package apu
+
+import "testing"
+
+func TestLengthCounter(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		halt    bool
+		index   byte
+		clocks  int
+		value   byte
+	}{
+		{"load", true, false, 1, 0, 254},
+		{"load odd index", true, false, 3, 0, 2},
+		{"disabled ignores load", false, false, 1, 0, 0},
+		{"clock", true, false, 0, 4, 6},
+		{"clock to zero", true, false, 3, 5, 0},
+		{"halt", true, true, 0, 4, 10},
+	}
+	for _, test := range tests {
+		l := LengthCounter{Halt: test.halt}
+		l.SetEnabled(test.enabled)
+		l.Load(test.index)
+		for i := 0; i < test.clocks; i++ {
+			l.Clock()
+		}
+		if l.value != test.value {
+			t.Errorf("%s: expected length %d, got %d", test.name, test.value, l.value)
+		}
+		if l.Active() != (test.value > 0) {
+			t.Errorf("%s: expected active %v", test.name, test.value > 0)
+		}
+	}
+
+	// Reloading while halted still sets the counter, and disabling clears it
+	l := LengthCounter{Halt: true}
+	l.SetEnabled(true)
+	l.Load(0)
+	l.Load(2)
+	if l.value != 20 {
+		t.Errorf("expected reload to set length 20, got %d", l.value)
+	}
+	l.SetEnabled(false)
+	if l.Active() {
+		t.Errorf("expected disabling to clear length, got %d", l.value)
+	}
+}
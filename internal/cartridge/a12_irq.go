@@ -0,0 +1,54 @@
+package cartridge
+
+// a12IRQ implements the MMC3's scanline counter, which is clocked by rising edges of PPU address
+// line A12. The Taito TC0690 has a compatible counter.
+// http://wiki.nesdev.com/w/index.php/MMC3#IRQ_Specifics
+type a12IRQ struct {
+	latch,
+	counter byte
+	reload,
+	enabled,
+	pending bool
+
+	// A12 edge detection
+	cycles  uint64
+	a12High bool
+	lowFrom uint64
+}
+
+const (
+	// mmc3A12Filter is the number of CPU cycles that A12 must be low before a rising edge clocks
+	// the IRQ counter, which filters out the toggling between nametable and pattern fetches
+	mmc3A12Filter = 3
+)
+
+// observe watches PPU address line A12, and clocks the IRQ counter on rising edges after it has
+// been low for long enough. With the usual setup of background tiles at $0000 and sprites at
+// $1000, this happens once per scanline at the first sprite pattern fetch.
+func (i *a12IRQ) observe(addr uint16) {
+	high := addr&0x1000 != 0
+	if high && !i.a12High && i.cycles-i.lowFrom >= mmc3A12Filter {
+		i.clock()
+	}
+	if !high && i.a12High {
+		i.lowFrom = i.cycles
+	}
+	i.a12High = high
+}
+
+func (i *a12IRQ) clock() {
+	if i.counter == 0 || i.reload {
+		i.counter = i.latch
+		i.reload = false
+	} else {
+		i.counter--
+	}
+	if i.counter == 0 && i.enabled {
+		i.pending = true
+	}
+}
+
+// step advances the edge detection by a CPU cycle.
+func (i *a12IRQ) step() {
+	i.cycles++
+}
@@ -0,0 +1,212 @@
+package cartridge
+
+import (
+	"math"
+	"testing"
+)
+
+func TestN163Multiplexing(t *testing.T) {
+	n := newN163Audio(0)
+	// Write a square wave into the first 4 bytes of RAM (8 samples), with auto-increment
+	n.writeAddr(0x80)
+	for _, val := range []byte{0xFF, 0xFF, 0x00, 0x00} {
+		n.writeData(val)
+	}
+	// Channel 7: length 8, wave at 0, volume 15, and the fastest frequency that advances the
+	// phase by a full sample each update
+	n.writeAddr(0x80 | 0x78)
+	for _, val := range []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x0F} {
+		n.writeData(val)
+	}
+	// Wrap back to $78 to check the auto-increment and reads
+	n.writeAddr(0x80 | 0x78)
+	n.readData()
+	if n.addr != 0x79 {
+		t.Errorf("expected address to auto-increment to $79, got %#x", n.addr)
+	}
+	n.writeAddr(0x7C)
+	n.writeData(0xF8 | 0x01)
+
+	levels := map[int]bool{}
+	for i := 0; i < 8*n163UpdateCycles; i++ {
+		n.step()
+		levels[n.current] = true
+	}
+	if !levels[(15-8)*15] || !levels[(0-8)*15] {
+		t.Errorf("expected channel to output both high and low samples, got %v", levels)
+	}
+
+	// With two channels enabled, channel 6 (silent) is output every other update
+	n.ram[0x7F] |= 1 << 4
+	silent := 0
+	for i := 0; i < 8*n163UpdateCycles; i++ {
+		n.step()
+		if n.cycles == 0 && n.current == 0 {
+			silent++
+		}
+	}
+	if silent != 4 {
+		t.Errorf("expected channel 6 to be output for 4 of 8 updates, got %d", silent)
+	}
+}
+
+func TestN163Level(t *testing.T) {
+	// Gain in dB of a full volume channel over a full volume APU pulse
+	tests := []struct {
+		submapper byte
+		gain      float64
+	}{
+		{0, 12},
+		{2, math.Inf(-1)},
+		{3, 12},
+		{4, 16.5},
+		{5, 18.75},
+	}
+	for _, test := range tests {
+		full := n163Level(test.submapper) * 15 * 15
+		if gain := 20 * math.Log10(full/(apuPulseStep*15)); gain != test.gain && math.Abs(gain-test.gain) > 1e-9 {
+			t.Errorf("submapper %d: expected gain %.2fdB, got %.2fdB", test.submapper, test.gain, gain)
+		}
+	}
+}
+
+func TestSunsoft5BTone(t *testing.T) {
+	s := newSunsoft5BAudio()
+	// Channel A tone only, period 10, volume 15
+	s.writeReg(0)
+	s.writeData(10)
+	s.writeReg(7)
+	s.writeData(0x3E)
+	s.writeReg(8)
+	s.writeData(0x0F)
+
+	var toggles int
+	last := s.output()
+	// Tone period is 32 CPU cycles per unit
+	for i := 0; i < 32*10*4; i++ {
+		s.step()
+		if out := s.output(); out != last {
+			toggles++
+			last = out
+		}
+	}
+	if toggles != 8 {
+		t.Errorf("expected 4 tone periods, got %d toggles", toggles)
+	}
+	if last != 0 && last != sunsoft5BLevels[31]*sunsoft5BLevel {
+		t.Errorf("expected full volume output, got %f", last)
+	}
+}
+
+func TestSunsoft5BEnvelope(t *testing.T) {
+	s := newSunsoft5BAudio()
+	s.writeReg(11)
+	s.writeData(1)
+	// Attack then hold
+	s.writeReg(13)
+	s.writeData(0xD)
+	for i := 0; i < 8*40; i++ {
+		s.step()
+	}
+	if level := s.envelope.level(); level != 31 {
+		t.Errorf("expected envelope to hold at 31, got %d", level)
+	}
+
+	// Decay without continue drops to 0
+	s.writeData(0x0)
+	if level := s.envelope.level(); level != 31 {
+		t.Errorf("expected envelope to restart at 31, got %d", level)
+	}
+	for i := 0; i < 8*40; i++ {
+		s.step()
+	}
+	if level := s.envelope.level(); level != 0 {
+		t.Errorf("expected envelope to hold at 0, got %d", level)
+	}
+}
+
+func TestMMC5Audio(t *testing.T) {
+	m := &mmc5Audio{}
+	m.write(0x5015, 0x01)
+	// 50% duty, constant volume 15, length counter running
+	m.write(0x5000, 0x9F)
+	m.write(0x5002, 0x10)
+	m.write(0x5003, 0x08)
+	if status, _ := m.read(0x5015); status != 0x01 {
+		t.Errorf("expected pulse 1 length counter to be active, got %#x", status)
+	}
+
+	var high bool
+	for i := 0; i < 0x100; i++ {
+		m.step()
+		if m.output() > 0 {
+			high = true
+		}
+	}
+	if !high {
+		t.Error("expected pulse 1 output")
+	}
+
+	// Length counter expires after 254 frame clocks, at 240Hz
+	for i := 0; i < 255*mmc5FramePeriod; i++ {
+		m.step()
+	}
+	if status, _ := m.read(0x5015); status != 0 {
+		t.Errorf("expected length counter to expire, got %#x", status)
+	}
+
+	// PCM write mode ignores zeroes
+	m.write(0x5011, 0x40)
+	m.write(0x5011, 0x00)
+	if m.pcm != 0x40 {
+		t.Errorf("expected PCM level $40, got %#x", m.pcm)
+	}
+
+	// Read mode takes samples from PRG reads, and raises an IRQ on zero
+	m.write(0x5010, 0x81)
+	m.observeRead(0x8000, 0x20)
+	if m.pcm != 0x20 {
+		t.Errorf("expected PCM level $20, got %#x", m.pcm)
+	}
+	m.observeRead(0x8001, 0x00)
+	if !m.irq() {
+		t.Error("expected PCM IRQ")
+	}
+	if status, _ := m.read(0x5010); status != 0x80 || m.irq() {
+		t.Errorf("expected reading $5010 to report and acknowledge the IRQ, got %#x", status)
+	}
+}
+
+func TestVRC7Tone(t *testing.T) {
+	v := newVRC7Audio()
+	// Custom patch with a silent modulator, and a sustained carrier with an instant attack
+	for reg, val := range []byte{0x01, 0x21, 0x3F, 0x00, 0x00, 0xF0, 0x00, 0x00} {
+		v.writeAddr(byte(reg))
+		v.writeData(val)
+	}
+	// Channel 0: F-number 288 in octave 4 is A4, at full volume
+	for _, write := range [][2]byte{{0x10, 0x20}, {0x20, 0x19}, {0x30, 0x00}} {
+		v.writeAddr(write[0])
+		v.writeData(write[1])
+	}
+
+	// Count rising zero crossings for a second of CPU cycles
+	var crossings int
+	last := v.output()
+	for i := 0; i < 1789773; i++ {
+		v.step()
+		out := v.output()
+		if last < 0 && out >= 0 {
+			crossings++
+		}
+		last = out
+	}
+	if crossings < 435 || crossings > 439 {
+		t.Errorf("expected a 437Hz tone, got %dHz", crossings)
+	}
+
+	v.silenced = true
+	if out := v.output(); out != 0 {
+		t.Errorf("expected no output when silenced, got %f", out)
+	}
+}
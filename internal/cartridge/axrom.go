@@ -0,0 +1,88 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/AxROM
+
+func newAxROM(info *RomInfo, prg, chr []byte) (*axrom, error) {
+	if len(prg) < 0x8000 || len(prg)%0x8000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 32KB, got %d B", len(prg))
+	}
+	return &axrom{
+		prg: prg,
+		chr: chr,
+		ram: newPRGRAM(info),
+		// ANROM, as used by most games, doesn't have bus conflicts, so they need to be requested
+		// with the submapper
+		busConflicts: info.Submapper == 2,
+		mirroring:    MirrorSingleScreenA,
+	}, nil
+}
+
+type axrom struct {
+	prg,
+	chr,
+	ram []byte
+	busConflicts bool
+
+	prgBank   byte
+	mirroring Mirroring
+}
+
+func (a *axrom) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return a.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return a.prg[(int(a.prgBank)*0x8000+int(addr&0x7FFF))%len(a.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped AxROM address %#X", addr)
+	return 0
+}
+
+func (a *axrom) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		a.ram[addr-0x6000] = val
+
+	} else if addr >= 0x8000 {
+		if a.busConflicts {
+			val &= a.CPURead(addr)
+		}
+		a.prgBank = val & 0xF
+		// Bit 4 selects the nametable for single screen mirroring
+		if val&0x10 != 0 {
+			a.mirroring = MirrorSingleScreenB
+		} else {
+			a.mirroring = MirrorSingleScreenA
+		}
+
+	} else {
+		logrus.Debugf("Write to unmapped AxROM address %#X", addr)
+	}
+}
+
+func (a *axrom) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return a.chr[addr]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[a.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled AxROM PPU memory read from address %#x", addr))
+}
+
+func (a *axrom) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Boards use CHR RAM
+		a.chr[addr] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[a.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled AxROM PPU memory write to address %#x", addr))
+	}
+}
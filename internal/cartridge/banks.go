@@ -0,0 +1,96 @@
+package cartridge
+
+// Banks maps a window of the CPU or PPU address space onto equally sized banks of ROM or RAM, which
+// are selected into the window's slots by the mapper.
+type Banks struct {
+	data     []byte
+	base     uint16
+	bankSize int
+	// slots holds the bank selected into each bank sized slot of the window
+	slots []int
+}
+
+// NewBanks creates a window of size bytes starting at base, which maps data in banks of bankSize
+// bytes. Every slot initially selects the first bank.
+func NewBanks(data []byte, base uint16, size, bankSize int) *Banks {
+	if bankSize <= 0 || size < bankSize || size%bankSize != 0 {
+		panic("cartridge: window size must be a multiple of the bank size")
+	}
+	return &Banks{
+		data:     data,
+		base:     base,
+		bankSize: bankSize,
+		slots:    make([]int, size/bankSize),
+	}
+}
+
+// NewPRGBanks creates a window over the CPU's $8000-$FFFF, which maps PRG ROM in banks of bankSize
+// bytes.
+func NewPRGBanks(prg []byte, bankSize int) *Banks {
+	return NewBanks(prg, 0x8000, 0x8000, bankSize)
+}
+
+// NewCHRBanks creates a window over the PPU's $0000-$1FFF, which maps CHR ROM or RAM in banks of
+// bankSize bytes.
+func NewCHRBanks(chr []byte, bankSize int) *Banks {
+	return NewBanks(chr, 0, 0x2000, bankSize)
+}
+
+// BankCount returns the number of banks in the data, counting a partial last bank.
+func (b *Banks) BankCount() int {
+	return (len(b.data) + b.bankSize - 1) / b.bankSize
+}
+
+// Slots returns the number of banks the window holds at once.
+func (b *Banks) Slots() int {
+	return len(b.slots)
+}
+
+// Select maps bank into the given slot of the window. Negative banks count back from the last
+// bank, so -1 selects the last. Banks past the end of the data wrap around, as they do on boards
+// with unconnected upper bank lines.
+func (b *Banks) Select(slot, bank int) {
+	count := b.BankCount()
+	if count == 0 {
+		return
+	}
+	bank %= count
+	if bank < 0 {
+		bank += count
+	}
+	b.slots[slot] = bank
+}
+
+// Bank returns the bank selected into the given slot.
+func (b *Banks) Bank(slot int) int {
+	return b.slots[slot]
+}
+
+// Contains returns whether addr is within the window.
+func (b *Banks) Contains(addr uint16) bool {
+	offset := int(addr) - int(b.base)
+	return offset >= 0 && offset < len(b.slots)*b.bankSize
+}
+
+// Offset returns the offset into the data that addr maps to, which must be within the window.
+func (b *Banks) Offset(addr uint16) int {
+	offset := int(addr - b.base)
+	bank := b.slots[offset/b.bankSize]
+	return (bank*b.bankSize + offset%b.bankSize) % len(b.data)
+}
+
+// Read returns the byte that addr maps to, or 0 if there is no data.
+func (b *Banks) Read(addr uint16) byte {
+	if len(b.data) == 0 {
+		return 0
+	}
+	return b.data[b.Offset(addr)]
+}
+
+// Write sets the byte that addr maps to. Writes are ignored if there is no data.
+func (b *Banks) Write(addr uint16, val byte) {
+	if len(b.data) == 0 {
+		return
+	}
+	b.data[b.Offset(addr)] = val
+}
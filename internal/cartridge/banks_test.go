@@ -0,0 +1,41 @@
+package cartridge
+
+import "testing"
+
+func TestBanks(t *testing.T) {
+	prg := NewPRGBanks(testPRG(8), 0x2000)
+	if count := prg.BankCount(); count != 8 {
+		t.Errorf("expected 8 PRG banks, got %d", count)
+	}
+	prg.Select(0, 3)
+	prg.Select(1, 9)
+	prg.Select(2, -2)
+	prg.Select(3, -1)
+	for slot, expected := range []byte{3, 1, 6, 7} {
+		addr := 0x8000 + uint16(slot)*0x2000
+		if actual := prg.Read(addr); actual != expected {
+			t.Errorf("expected $%X to read PRG bank %d, got %d", addr, expected, actual)
+		}
+	}
+	if prg.Contains(0x7FFF) || !prg.Contains(0x8000) || !prg.Contains(0xFFFF) {
+		t.Errorf("expected PRG window to cover $8000-$FFFF")
+	}
+
+	chr := NewCHRBanks(testCHR(0x2000, 0x400), 0x400)
+	chr.Select(0, 2)
+	chr.Select(7, 2)
+	chr.Write(0x1C05, 0xAB)
+	if actual := chr.Read(0x0005); actual != 0xAB {
+		t.Errorf("expected CHR write through slot 7 to be visible through slot 0, got %#x", actual)
+	}
+	if actual := chr.Offset(0x1C05); actual != 0x805 {
+		t.Errorf("expected $1C05 to map to offset $805, got $%X", actual)
+	}
+
+	empty := NewCHRBanks(nil, 0x2000)
+	empty.Select(0, 1)
+	empty.Write(0, 1)
+	if actual := empty.Read(0); actual != 0 {
+		t.Errorf("expected empty window to read 0, got %d", actual)
+	}
+}
@@ -0,0 +1,103 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_034
+
+func newBNROM(info *RomInfo, prg, chr []byte) (*bnrom, error) {
+	if len(prg) < 0x8000 || len(prg)%0x8000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 32KB, got %d B", len(prg))
+	}
+	// Submapper 1 is the NINA-001, and 2 is BNROM. Without a submapper, only the NINA-001 has
+	// more than 8KB of CHR.
+	nina := info.Submapper == 1 || (info.Submapper == 0 && len(chr) > 0x2000)
+	return &bnrom{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+		nina:      nina,
+	}, nil
+}
+
+type bnrom struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+	// nina is set for the NINA-001, which has registers in PRG RAM and switches CHR ROM, rather
+	// than BNROM's register at $8000-$FFFF with bus conflicts
+	nina bool
+
+	prgBank  byte
+	chrBanks [2]byte
+}
+
+func (b *bnrom) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return b.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return b.prg[(int(b.prgBank)*0x8000+int(addr&0x7FFF))%len(b.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped BNROM address %#X", addr)
+	return 0
+}
+
+func (b *bnrom) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		// NINA-001 registers are written through to RAM
+		b.ram[addr-0x6000] = val
+		if !b.nina {
+			return
+		}
+		switch addr {
+		case 0x7FFD:
+			b.prgBank = val & 1
+		case 0x7FFE:
+			b.chrBanks[0] = val & 0xF
+		case 0x7FFF:
+			b.chrBanks[1] = val & 0xF
+		}
+
+	} else if addr >= 0x8000 && !b.nina {
+		b.prgBank = val & b.CPURead(addr)
+
+	} else {
+		logrus.Debugf("Write to unmapped BNROM address %#X", addr)
+	}
+}
+
+func (b *bnrom) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return b.chr[b.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[b.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled BNROM PPU memory read from address %#x", addr))
+}
+
+func (b *bnrom) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// BNROM uses CHR RAM
+		b.chr[b.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[b.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled BNROM PPU memory write to address %#x", addr))
+	}
+}
+
+func (b *bnrom) chrAddr(addr uint16) int {
+	if !b.nina {
+		return int(addr) % len(b.chr)
+	}
+	bank := b.chrBanks[addr>>12]
+	return (int(bank)*0x1000 + int(addr&0xFFF)) % len(b.chr)
+}
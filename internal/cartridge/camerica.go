@@ -0,0 +1,87 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_071
+
+func newCamerica(info *RomInfo, prg, chr []byte) (*camerica, error) {
+	if len(prg) < 0x4000 || len(prg)%0x4000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 16KB, got %d B", len(prg))
+	}
+	return &camerica{
+		prg:       prg,
+		chr:       chr,
+		mirroring: info.Mirroring,
+		// Submapper 1 is Fire Hawk's BF9097, with a mirroring register
+		mirroringControl: info.Submapper == 1,
+	}, nil
+}
+
+type camerica struct {
+	prg,
+	chr []byte
+	mirroring Mirroring
+	// mirroringControl is set for boards which select single screen mirroring at $8000-$9FFF.
+	// Without a submapper, it's enabled by the first write there, since other boards ignore the
+	// writes.
+	mirroringControl bool
+
+	prgBank byte
+}
+
+func (c *camerica) CPURead(addr uint16) byte {
+	if addr >= 0x8000 && addr < 0xC000 {
+		return c.prg[(int(c.prgBank)*0x4000+int(addr&0x3FFF))%len(c.prg)]
+
+	} else if addr >= 0xC000 {
+		// Last bank is fixed
+		return c.prg[len(c.prg)-0x4000+int(addr&0x3FFF)]
+
+	}
+	logrus.Debugf("Read from unmapped Camerica address %#X", addr)
+	return 0
+}
+
+func (c *camerica) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x9000 && addr < 0xA000 {
+		c.mirroringControl = true
+	}
+	if addr >= 0x8000 && addr < 0xA000 && c.mirroringControl {
+		if val&0x10 != 0 {
+			c.mirroring = MirrorSingleScreenB
+		} else {
+			c.mirroring = MirrorSingleScreenA
+		}
+
+	} else if addr >= 0xC000 {
+		c.prgBank = val & 0xF
+
+	} else {
+		logrus.Debugf("Write to unmapped Camerica address %#X", addr)
+	}
+}
+
+func (c *camerica) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return c.chr[addr]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[c.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled Camerica PPU memory read from address %#x", addr))
+}
+
+func (c *camerica) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Boards use CHR RAM
+		c.chr[addr] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[c.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled Camerica PPU memory write to address %#x", addr))
+	}
+}
@@ -4,19 +4,123 @@ import (
 	"fmt"
 )
 
+// Cartridge is a cartridge board, which is mapped into the CPU address space at $4020-$FFFF and the
+// PPU address space at $0000-$3EFF.
 type Cartridge interface {
 	CPURead(addr uint16) byte
 	CPUWrite(addr uint16, val byte)
+	// PPURead and PPUWrite are passed the PPU's 4KB of VRAM, which the cartridge maps nametable
+	// addresses into, usually with Mirroring.NametableAddr.
 	PPURead(addr uint16, vram []byte) byte
 	PPUWrite(addr uint16, val byte, vram []byte)
 }
 
-func NewCartridge(mapper uint16, prg, chr []byte) (Cartridge, error) {
-	switch mapper {
+// NewCartridge creates a cartridge with the mapper described by info. Mappers added with Register
+// take precedence over the built-in ones.
+func NewCartridge(info *RomInfo, prg, chr []byte) (Cartridge, error) {
+	if constructor := registered(info.Mapper, info.Submapper); constructor != nil {
+		return constructor(info, prg, chr)
+	}
+	switch info.Mapper {
 	case 0:
-		return newNROM(prg, chr)
+		return newNROM(info, prg, chr)
 	case 1:
-		return newMMC1(prg, chr)
+		return newMMC1(info, prg, chr)
+	case 2:
+		return newUxROM(info, prg, chr)
+	case 3:
+		return newCNROM(info, prg, chr)
+	case 4:
+		return newMMC3(info, prg, chr)
+	case 5:
+		return newMMC5(info, prg, chr)
+	case 7:
+		return newAxROM(info, prg, chr)
+	case 9:
+		return newMMC2(info, prg, chr, false)
+	case 10:
+		return newMMC2(info, prg, chr, true)
+	case 11:
+		return newColorDreams(info, prg, chr)
+	case 15:
+		return newMulticart15(info, prg, chr)
+	case 16, 153, 159:
+		return newFCG(info, prg, chr)
+	case 18:
+		return newSS88006(info, prg, chr)
+	case 19:
+		return newN163(info, prg, chr)
+	case 21, 22, 23, 25:
+		return newVRC4(info, prg, chr)
+	case 24:
+		return newVRC6(info, prg, chr, false)
+	case 26:
+		return newVRC6(info, prg, chr, true)
+	case 32:
+		return newG101(info, prg, chr)
+	case 33:
+		return newTC0190(info, prg, chr, false)
+	case 34:
+		return newBNROM(info, prg, chr)
+	case 48:
+		return newTC0190(info, prg, chr, true)
+	case 65:
+		return newH3001(info, prg, chr)
+	case 66:
+		return newGxROM(info, prg, chr)
+	case 69:
+		return newFME7(info, prg, chr)
+	case 71:
+		return newCamerica(info, prg, chr)
+	case 85:
+		return newVRC7(info, prg, chr)
+	case 225:
+		return newMulticart225(info, prg, chr)
+	case 226:
+		return newMulticart226(info, prg, chr)
 	}
-	return nil, fmt.Errorf("unknown mapper %d", mapper)
+	return nil, fmt.Errorf("unknown mapper %d", info.Mapper)
+}
+
+// Clocked is implemented by cartridges with hardware that is driven by the CPU clock, such as
+// cycle-based IRQ counters.
+type Clocked interface {
+	// Step is called once per CPU cycle.
+	Step()
+}
+
+// Interrupter is implemented by cartridges with hardware that can interrupt the CPU.
+type Interrupter interface {
+	// IRQ returns whether the cartridge is currently asserting the IRQ line.
+	IRQ() bool
+}
+
+// Audio is implemented by cartridges with expansion audio hardware.
+type Audio interface {
+	// AudioOutput returns the current level of the expansion audio, on the same scale as the APU
+	// mixer output. This is sampled once per CPU cycle, after Step.
+	AudioOutput() float64
+}
+
+// Saver is implemented by cartridges with save storage that isn't mapped into memory, such as
+// serial EEPROMs.
+type Saver interface {
+	// SaveData returns a copy of the save storage contents, which can be restored by passing them
+	// to NewCartridge in RomInfo.SaveData.
+	SaveData() []byte
 }
+
+// RenderObserver is implemented by cartridges that track the PPU's rendering, such as to map
+// separate pattern data for sprites and backgrounds.
+type RenderObserver interface {
+	// PPURegisterWrite is called when the CPU writes to a PPU register, which the cartridge can
+	// see on the CPU bus.
+	PPURegisterWrite(reg, val byte)
+	// SpriteFetch is called with true when the PPU starts fetching sprite data for a scanline,
+	// and false when it returns to fetching background data.
+	SpriteFetch(active bool)
+}
+
+// apuPulseStep approximates the change in APU mixer output for a single volume step of one pulse
+// channel, and is used to scale expansion audio to a comparable level.
+const apuPulseStep = 95.52 / (8128.0/15 + 100) / 15
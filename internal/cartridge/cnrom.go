@@ -0,0 +1,88 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/CNROM
+
+func newCNROM(info *RomInfo, prg, chr []byte) (*cnrom, error) {
+	if len(prg) != 0x4000 && len(prg) != 0x8000 {
+		return nil, fmt.Errorf("expected PRG ROM to be 16KB or 32KB, got %d B", len(prg))
+	}
+	if len(chr) < 0x2000 || len(chr)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected CHR ROM to be a multiple of 8KB, got %d B", len(chr))
+	}
+	return &cnrom{
+		prg:          prg,
+		chr:          chr,
+		ram:          newPRGRAM(info),
+		mirroring:    info.Mirroring,
+		busConflicts: hasBusConflicts(info),
+	}, nil
+}
+
+type cnrom struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring    Mirroring
+	busConflicts bool
+
+	chrBank byte
+}
+
+func (c *cnrom) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return c.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		// 16KB ROMs are mirrored into $C000
+		return c.prg[int(addr-0x8000)%len(c.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped CNROM address %#X", addr)
+	return 0
+}
+
+func (c *cnrom) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		c.ram[addr-0x6000] = val
+
+	} else if addr >= 0x8000 {
+		if c.busConflicts {
+			val &= c.CPURead(addr)
+		}
+		c.chrBank = val
+
+	} else {
+		logrus.Debugf("Write to unmapped CNROM address %#X", addr)
+	}
+}
+
+func (c *cnrom) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return c.chr[c.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[c.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled CNROM PPU memory read from address %#x", addr))
+}
+
+func (c *cnrom) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		c.chr[c.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[c.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled CNROM PPU memory write to address %#x", addr))
+	}
+}
+
+func (c *cnrom) chrAddr(addr uint16) int {
+	return (int(c.chrBank)*0x2000 + int(addr)) % len(c.chr)
+}
@@ -0,0 +1,315 @@
+package cartridge
+
+import "testing"
+
+func TestUxROM(t *testing.T) {
+	for _, test := range []struct {
+		submapper    byte
+		busConflicts bool
+	}{
+		{0, true},
+		{1, false},
+		{2, true},
+	} {
+		prg := testPRG(16)
+		// Bus conflict friendly byte, in the fixed bank
+		prg[len(prg)-0x4000+0x10] = 0xFF
+		u, err := newUxROM(&RomInfo{Mapper: 2, Submapper: test.submapper}, prg, make([]byte, 0x2000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.CPUWrite(0xC010, 3)
+		if bank := u.CPURead(0x8000); bank != 6 {
+			t.Errorf("submapper %d: expected 16KB bank 3 to map 8KB bank 6 at $8000, got %d", test.submapper, bank)
+		}
+		if bank := u.CPURead(0xC000); bank != 14 {
+			t.Errorf("submapper %d: expected last bank fixed at $C000, got %d", test.submapper, bank)
+		}
+
+		// Writing over a zero byte conflicts with the ROM
+		u.CPUWrite(0xC011, 2)
+		expected := byte(4)
+		if test.busConflicts {
+			expected = 0
+		}
+		if bank := u.CPURead(0x8000); bank != expected {
+			t.Errorf("submapper %d: expected bank %d at $8000, got %d", test.submapper, expected, bank)
+		}
+	}
+}
+
+func TestCNROM(t *testing.T) {
+	prg := make([]byte, 0x8000)
+	prg[0x10] = 0xFF
+	chr := make([]byte, 4*0x2000)
+	for i := 0; i < 4; i++ {
+		chr[i*0x2000] = byte(i)
+	}
+	c, err := newCNROM(&RomInfo{Mapper: 3, Mirroring: MirrorVertical}, prg, chr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	c.CPUWrite(0x8010, 2)
+	if bank := c.PPURead(0, vram); bank != 2 {
+		t.Errorf("expected CHR bank 2, got %d", bank)
+	}
+	c.CPUWrite(0x8011, 3)
+	if bank := c.PPURead(0, vram); bank != 0 {
+		t.Errorf("expected bus conflict to select CHR bank 0, got %d", bank)
+	}
+
+	c.PPUWrite(0x2400, 0x42, vram)
+	if val := c.PPURead(0x2C00, vram); val != 0x42 {
+		t.Errorf("expected vertical mirroring from the header, got %#x", val)
+	}
+}
+
+func TestAxROM(t *testing.T) {
+	a, err := newAxROM(&RomInfo{Mapper: 7, Mirroring: MirrorVertical}, testPRG(8), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	a.PPUWrite(0x2000, 1, vram)
+	if val := a.PPURead(0x2C00, vram); val != 1 {
+		t.Errorf("expected single screen mirroring on power up, got %#x", val)
+	}
+
+	a.CPUWrite(0x8000, 0x11)
+	if bank := a.CPURead(0x8000); bank != 4 {
+		t.Errorf("expected 32KB bank 1 to map 8KB bank 4 at $8000, got %d", bank)
+	}
+	a.PPUWrite(0x2000, 2, vram)
+	if val := a.PPURead(0x2400, vram); val != 2 {
+		t.Errorf("expected second nametable to be selected, got %#x", val)
+	}
+	a.CPUWrite(0x8000, 0)
+	if val := a.PPURead(0x2800, vram); val != 1 {
+		t.Errorf("expected first nametable to be selected, got %#x", val)
+	}
+}
+
+func TestGxROM(t *testing.T) {
+	prg := testPRG(16)
+	prg[0x10] = 0xFF
+	g, err := newGxROM(&RomInfo{Mapper: 66}, prg, testCHR(0x8000, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.CPUWrite(0x8010, 0x21)
+	if bank := g.CPURead(0x8000); bank != 8 {
+		t.Errorf("expected 32KB bank 2 to map 8KB bank 8 at $8000, got %d", bank)
+	}
+	if bank := g.PPURead(0, nil); bank != 1 {
+		t.Errorf("expected CHR bank 1, got %d", bank)
+	}
+	// Bus conflicts with the ROM byte, which holds bank number 8
+	g.CPUWrite(0x8000, 0x33)
+	if bank := g.CPURead(0x8000); bank != 0 {
+		t.Errorf("expected bus conflict to select bank 0 at $8000, got %d", bank)
+	}
+}
+
+func TestColorDreams(t *testing.T) {
+	prg := testPRG(16)
+	prg[0x10] = 0xFF
+	c, err := newColorDreams(&RomInfo{Mapper: 11}, prg, testCHR(0x20000, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CPUWrite(0x8010, 0xA3)
+	if bank := c.CPURead(0x8000); bank != 12 {
+		t.Errorf("expected 32KB bank 3 to map 8KB bank 12 at $8000, got %d", bank)
+	}
+	if bank := c.PPURead(0, nil); bank != 10 {
+		t.Errorf("expected CHR bank 10, got %d", bank)
+	}
+}
+
+func TestBNROM(t *testing.T) {
+	prg := testPRG(16)
+	prg[0x10] = 0xFF
+	b, err := newBNROM(&RomInfo{Mapper: 34}, prg, make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.nina {
+		t.Fatal("expected 8KB of CHR to select BNROM")
+	}
+	b.CPUWrite(0x8010, 3)
+	if bank := b.CPURead(0x8000); bank != 12 {
+		t.Errorf("expected 32KB bank 3 to map 8KB bank 12 at $8000, got %d", bank)
+	}
+	// Registers at $7FFD-$7FFF are just RAM
+	b.CPUWrite(0x7FFD, 1)
+	if bank := b.CPURead(0x8000); bank != 12 {
+		t.Errorf("expected BNROM to ignore $7FFD, got bank %d", bank)
+	}
+}
+
+func TestNINA001(t *testing.T) {
+	for _, info := range []*RomInfo{
+		{Mapper: 34, Submapper: 1},
+		{Mapper: 34},
+	} {
+		n, err := newBNROM(info, testPRG(8), testCHR(0x10000, 0x1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !n.nina {
+			t.Fatalf("submapper %d: expected NINA-001", info.Submapper)
+		}
+		n.CPUWrite(0x7FFD, 1)
+		n.CPUWrite(0x7FFE, 5)
+		n.CPUWrite(0x7FFF, 9)
+		if bank := n.CPURead(0x8000); bank != 4 {
+			t.Errorf("submapper %d: expected 32KB bank 1 to map 8KB bank 4 at $8000, got %d", info.Submapper, bank)
+		}
+		if bank := n.PPURead(0x0000, nil); bank != 5 {
+			t.Errorf("submapper %d: expected CHR bank 5 at $0000, got %d", info.Submapper, bank)
+		}
+		if bank := n.PPURead(0x1000, nil); bank != 9 {
+			t.Errorf("submapper %d: expected CHR bank 9 at $1000, got %d", info.Submapper, bank)
+		}
+		if val := n.CPURead(0x7FFF); val != 9 {
+			t.Errorf("submapper %d: expected register writes to reach RAM, got %d", info.Submapper, val)
+		}
+		// Writes to ROM are ignored
+		n.CPUWrite(0x8000, 0)
+		if bank := n.CPURead(0x8000); bank != 4 {
+			t.Errorf("submapper %d: expected NINA-001 to ignore $8000, got bank %d", info.Submapper, bank)
+		}
+	}
+}
+
+func TestCamerica(t *testing.T) {
+	c, err := newCamerica(&RomInfo{Mapper: 71, Mirroring: MirrorVertical}, testPRG(16), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CPUWrite(0xC000, 3)
+	if bank := c.CPURead(0x8000); bank != 6 {
+		t.Errorf("expected 16KB bank 3 to map 8KB bank 6 at $8000, got %d", bank)
+	}
+	if bank := c.CPURead(0xC000); bank != 14 {
+		t.Errorf("expected last bank fixed at $C000, got %d", bank)
+	}
+
+	// Mirroring control is enabled by Fire Hawk's first write to $9000
+	c.CPUWrite(0x8000, 0x10)
+	if c.mirroring != MirrorVertical {
+		t.Errorf("expected $8000 writes to be ignored, got mirroring %d", c.mirroring)
+	}
+	c.CPUWrite(0x9000, 0x10)
+	if c.mirroring != MirrorSingleScreenB {
+		t.Errorf("expected single screen mirroring, got %d", c.mirroring)
+	}
+	c.CPUWrite(0x8000, 0x00)
+	if c.mirroring != MirrorSingleScreenA {
+		t.Errorf("expected single screen mirroring, got %d", c.mirroring)
+	}
+}
+
+func TestMulticart15(t *testing.T) {
+	m, err := newMulticart15(&RomInfo{Mapper: 15}, testPRG(64), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		addr     uint16
+		val      byte
+		expected [4]byte
+	}{
+		{"NROM-256", 0x8000, 4, [4]byte{8, 9, 10, 11}},
+		{"UNROM", 0x8001, 4, [4]byte{8, 9, 14, 15}},
+		{"NROM-64", 0x8002, 0x85, [4]byte{11, 11, 11, 11}},
+		{"NROM-128", 0x8003, 5, [4]byte{10, 11, 10, 11}},
+	} {
+		m.CPUWrite(test.addr, test.val)
+		for i, bank := range test.expected {
+			addr := 0x8000 + uint16(i)*0x2000
+			if actual := m.CPURead(addr); actual != bank {
+				t.Errorf("%s: expected bank %d at %#x, got %d", test.name, bank, addr, actual)
+			}
+		}
+	}
+
+	// CHR RAM is only writable in the UNROM and NROM-64 modes
+	m.PPUWrite(0, 0x42, nil)
+	if val := m.PPURead(0, nil); val != 0 {
+		t.Errorf("expected CHR RAM to be write protected, got %#x", val)
+	}
+	m.CPUWrite(0x8001, 0)
+	m.PPUWrite(0, 0x42, nil)
+	if val := m.PPURead(0, nil); val != 0x42 {
+		t.Errorf("expected CHR RAM to be writable, got %#x", val)
+	}
+}
+
+func TestMulticart225(t *testing.T) {
+	m, err := newMulticart225(&RomInfo{Mapper: 225}, testPRG(256), testCHR(0x100000, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// High bank, 16KB mode, horizontal mirroring, PRG bank 3 and CHR bank 5
+	m.CPUWrite(0x8000|0x4000|0x2000|0x1000|3<<6|5, 0)
+	if bank := m.CPURead(0x8000); bank != 134 {
+		t.Errorf("expected 16KB bank 67 to map 8KB bank 134 at $8000, got %d", bank)
+	}
+	if bank := m.CPURead(0xC000); bank != 134 {
+		t.Errorf("expected 16KB bank 67 to be mirrored at $C000, got %d", bank)
+	}
+	if bank := m.PPURead(0, nil); bank != 69 {
+		t.Errorf("expected CHR bank 69, got %d", bank)
+	}
+	if m.mirroring != MirrorHorizontal {
+		t.Errorf("expected horizontal mirroring, got %d", m.mirroring)
+	}
+
+	// 32KB mode ignores the low bit of the bank
+	m.CPUWrite(0x8000|3<<6, 0)
+	if bank := m.CPURead(0x8000); bank != 4 {
+		t.Errorf("expected 16KB bank 2 to map 8KB bank 4 at $8000, got %d", bank)
+	}
+	if bank := m.CPURead(0xC000); bank != 6 {
+		t.Errorf("expected 16KB bank 3 to map 8KB bank 6 at $C000, got %d", bank)
+	}
+
+	m.CPUWrite(0x5802, 0xFF)
+	if val := m.CPURead(0x5802); val != 0xF {
+		t.Errorf("expected 4-bit RAM, got %#x", val)
+	}
+}
+
+func TestMulticart226(t *testing.T) {
+	m, err := newMulticart226(&RomInfo{Mapper: 226}, testPRG(256), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 16KB mode with bank bits 0-4, 5 and 6 set
+	m.CPUWrite(0x8000, 0x80|0x20|0x03)
+	m.CPUWrite(0x8001, 1)
+	if bank := m.CPURead(0x8000); bank != 198 {
+		t.Errorf("expected 16KB bank 99 to map 8KB bank 198 at $8000, got %d", bank)
+	}
+	if bank := m.CPURead(0xC000); bank != 198 {
+		t.Errorf("expected 16KB bank 99 to be mirrored at $C000, got %d", bank)
+	}
+	if m.mirroring != MirrorHorizontal {
+		t.Errorf("expected horizontal mirroring, got %d", m.mirroring)
+	}
+
+	m.CPUWrite(0x8000, 0x40|0x03)
+	m.CPUWrite(0x8001, 0)
+	if bank := m.CPURead(0x8000); bank != 4 {
+		t.Errorf("expected 16KB bank 2 to map 8KB bank 4 at $8000, got %d", bank)
+	}
+	if bank := m.CPURead(0xC000); bank != 6 {
+		t.Errorf("expected 16KB bank 3 to map 8KB bank 6 at $C000, got %d", bank)
+	}
+	if m.mirroring != MirrorVertical {
+		t.Errorf("expected vertical mirroring, got %d", m.mirroring)
+	}
+}
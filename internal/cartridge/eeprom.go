@@ -0,0 +1,164 @@
+package cartridge
+
+// eeprom implements the serial I2C EEPROMs used for saves on some Bandai boards: the 256 byte
+// 24C02, and the 128 byte X24C01 which has no device address and transfers bits LSB first.
+// http://wiki.nesdev.com/w/index.php/Bandai_FCG_board#Serial_EEPROM
+type eeprom struct {
+	data []byte
+	// x24c01 is set for the 128 byte X24C01
+	x24c01 bool
+
+	scl,
+	sda bool
+	state eepromState
+	// bits counts the bits transferred in the current byte, with the ninth being the acknowledge
+	bits int
+	shift,
+	addr byte
+	// clocked is set after a rising edge of the clock, until it falls
+	clocked bool
+	// ack is set when the console acknowledges a byte it read
+	ack bool
+}
+
+type eepromState byte
+
+const (
+	eepromIdle eepromState = iota
+	eepromDevice
+	eepromAddress
+	eepromWrite
+	eepromRead
+)
+
+// newEEPROM creates an EEPROM, loading any previously saved contents.
+func newEEPROM(x24c01 bool, saved []byte) *eeprom {
+	size := 256
+	if x24c01 {
+		size = 128
+	}
+	e := &eeprom{
+		data:   make([]byte, size),
+		x24c01: x24c01,
+	}
+	copy(e.data, saved)
+	return e
+}
+
+// write updates the clock and data lines driven by the console.
+func (e *eeprom) write(scl, sda bool) {
+	switch {
+	case e.scl && scl && e.sda && !sda:
+		// Start condition - data falls while the clock is high
+		e.state = eepromDevice
+		if e.x24c01 {
+			e.state = eepromAddress
+		}
+		e.bits = 0
+		e.shift = 0
+		e.clocked = false
+	case e.scl && scl && !e.sda && sda:
+		// Stop condition - data rises while the clock is high
+		e.state = eepromIdle
+	case !e.scl && scl:
+		e.rise(sda)
+	case e.scl && !scl:
+		e.fall()
+	}
+	e.scl = scl
+	e.sda = sda
+}
+
+// rise handles a rising edge of the clock line, where the data line is sampled.
+func (e *eeprom) rise(sda bool) {
+	if e.state == eepromIdle {
+		return
+	}
+	e.clocked = true
+	if e.bits == 8 {
+		// The console acknowledges bytes it reads by holding the data line low
+		e.ack = !sda
+		return
+	}
+	if e.state == eepromRead {
+		return
+	}
+	var bit byte
+	if sda {
+		bit = 1
+	}
+	if e.x24c01 {
+		e.shift |= bit << uint(e.bits)
+	} else {
+		e.shift = e.shift<<1 | bit
+	}
+}
+
+// fall handles a falling edge of the clock line, which moves on to the next bit.
+func (e *eeprom) fall() {
+	if e.state == eepromIdle || !e.clocked {
+		return
+	}
+	e.clocked = false
+	e.bits++
+	if e.bits < 9 {
+		return
+	}
+
+	// The byte and its acknowledge are complete
+	e.bits = 0
+	val := e.shift
+	e.shift = 0
+	switch e.state {
+	case eepromDevice:
+		switch {
+		case val&0xF0 != 0xA0:
+			e.state = eepromIdle
+		case val&1 == 1:
+			e.state = eepromRead
+		default:
+			e.state = eepromAddress
+		}
+	case eepromAddress:
+		if e.x24c01 {
+			e.addr = val & 0x7F
+			if val&0x80 != 0 {
+				e.state = eepromRead
+			} else {
+				e.state = eepromWrite
+			}
+		} else {
+			e.addr = val
+			e.state = eepromWrite
+		}
+	case eepromWrite:
+		e.data[int(e.addr)%len(e.data)] = val
+		e.addr++
+	case eepromRead:
+		// Reading continues until the console doesn't acknowledge
+		if e.ack {
+			e.addr++
+		} else {
+			e.state = eepromIdle
+		}
+	}
+}
+
+// output returns the data line driven by the EEPROM.
+func (e *eeprom) output() bool {
+	switch e.state {
+	case eepromDevice, eepromAddress, eepromWrite:
+		// Acknowledge each byte received
+		return e.bits != 8
+	case eepromRead:
+		if e.bits == 8 {
+			return true
+		}
+		val := e.data[int(e.addr)%len(e.data)]
+		if e.x24c01 {
+			return (val>>uint(e.bits))&1 == 1
+		}
+		return (val<<uint(e.bits))&0x80 != 0
+	}
+	return true
+}
@@ -0,0 +1,218 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/Bandai_FCG_board
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_153
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_159
+
+func newFCG(info *RomInfo, prg, chr []byte) (*fcg, error) {
+	if len(prg) < 0x8000 || len(prg)%0x4000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 16KB, got %d B", len(prg))
+	}
+	f := &fcg{
+		prg:       prg,
+		chr:       chr,
+		mirroring: info.Mirroring,
+	}
+	switch info.Mapper {
+	case 16:
+		switch info.Submapper {
+		case 4:
+			// FCG-1/2, with registers at $6000 and no EEPROM
+			f.lowRegisters = true
+			f.directCounter = true
+		case 5:
+			// LZ93D50 with a 24C02
+			f.highRegisters = true
+			f.eeprom = newEEPROM(false, info.SaveData)
+		default:
+			// Either of the above, so the registers respond at both addresses, and IRQ counter
+			// writes work for both
+			f.lowRegisters = true
+			f.highRegisters = true
+			f.directCounter = true
+			f.eeprom = newEEPROM(false, info.SaveData)
+		}
+	case 153:
+		// LZ93D50 with 8KB of PRG RAM and 512KB of PRG ROM
+		f.highRegisters = true
+		f.ram = newPRGRAM(info)
+	case 159:
+		// LZ93D50 with an X24C01
+		f.highRegisters = true
+		f.eeprom = newEEPROM(true, info.SaveData)
+	default:
+		return nil, fmt.Errorf("mapper %d is not a Bandai FCG board", info.Mapper)
+	}
+	return f, nil
+}
+
+type fcg struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+
+	// The FCG-1/2 has registers at $6000-$7FFF, and the LZ93D50 has them at $8000-$FFFF
+	lowRegisters,
+	highRegisters bool
+
+	chrBanks [8]byte
+	prgBank  byte
+	// outerBank selects the 256KB PRG ROM bank on mapper 153
+	outerBank byte
+
+	irqLatch,
+	irqCounter uint16
+	irqEnabled,
+	irqPending bool
+	// directCounter is set for the FCG-1/2, which writes the IRQ counter directly rather than
+	// through the LZ93D50's latch
+	directCounter bool
+
+	eeprom *eeprom
+	eepromReadEnabled,
+	ramEnabled bool
+}
+
+func (f *fcg) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if f.ram != nil {
+			if !f.ramEnabled {
+				return 0
+			}
+			return f.ram[addr-0x6000]
+		}
+		// The EEPROM's data line is read from bit 4
+		if f.eeprom != nil && f.eepromReadEnabled && f.eeprom.output() {
+			return 0x10
+		}
+		return 0
+
+	} else if addr >= 0xC000 {
+		// Last bank is fixed
+		return f.prg[f.prgAddr(0xF, addr)]
+
+	} else if addr >= 0x8000 {
+		return f.prg[f.prgAddr(f.prgBank, addr)]
+
+	}
+	logrus.Debugf("Read from unmapped FCG address %#X", addr)
+	return 0
+}
+
+func (f *fcg) prgAddr(bank byte, addr uint16) int {
+	bank = f.outerBank<<4 | bank&0xF
+	return (int(bank)*0x4000 + int(addr&0x3FFF)) % len(f.prg)
+}
+
+func (f *fcg) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if f.lowRegisters {
+			f.writeRegister(addr, val)
+		} else if f.ram != nil && f.ramEnabled {
+			f.ram[addr-0x6000] = val
+		}
+		return
+	}
+	if addr >= 0x8000 && f.highRegisters {
+		f.writeRegister(addr, val)
+		return
+	}
+	logrus.Debugf("Write to unmapped FCG address %#X", addr)
+}
+
+func (f *fcg) writeRegister(addr uint16, val byte) {
+	switch addr & 0xF {
+	case 0, 1, 2, 3, 4, 5, 6, 7:
+		f.chrBanks[addr&0xF] = val
+		if f.ram != nil {
+			f.outerBank = val & 1
+		}
+	case 8:
+		f.prgBank = val & 0xF
+	case 9:
+		f.mirroring = vrc6Mirroring[val&3]
+	case 0xA:
+		f.irqEnabled = val&1 == 1
+		f.irqPending = false
+		// The LZ93D50 reloads the counter from the latch
+		if f.highRegisters {
+			f.irqCounter = f.irqLatch
+		}
+	case 0xB:
+		f.irqLatch = f.irqLatch&0xFF00 | uint16(val)
+		if f.directCounter {
+			f.irqCounter = f.irqLatch
+		}
+	case 0xC:
+		f.irqLatch = f.irqLatch&0xFF | uint16(val)<<8
+		if f.directCounter {
+			f.irqCounter = f.irqLatch
+		}
+	case 0xD:
+		if f.ram != nil {
+			f.ramEnabled = val&0x20 != 0
+		} else if f.eeprom != nil {
+			f.eepromReadEnabled = val&0x80 != 0
+			f.eeprom.write(val&0x20 != 0, val&0x40 != 0)
+		}
+	}
+}
+
+func (f *fcg) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return f.chr[f.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[f.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled FCG PPU memory read from address %#x", addr))
+}
+
+func (f *fcg) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Mapper 153 uses CHR RAM
+		f.chr[f.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[f.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled FCG PPU memory write to address %#x", addr))
+	}
+}
+
+func (f *fcg) chrAddr(addr uint16) int {
+	if f.ram != nil {
+		// The CHR bank registers select the PRG outer bank instead
+		return int(addr) % len(f.chr)
+	}
+	bank := f.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(f.chr)
+}
+
+func (f *fcg) Step() {
+	if !f.irqEnabled {
+		return
+	}
+	f.irqCounter--
+	if f.irqCounter == 0 {
+		f.irqPending = true
+	}
+}
+
+func (f *fcg) IRQ() bool {
+	return f.irqPending
+}
+
+// SaveData returns the EEPROM contents, or nil for boards without one.
+func (f *fcg) SaveData() []byte {
+	if f.eeprom == nil {
+		return nil
+	}
+	return append([]byte(nil), f.eeprom.data...)
+}
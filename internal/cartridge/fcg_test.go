@@ -0,0 +1,262 @@
+package cartridge
+
+import "testing"
+
+func TestFCGBanking(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		submapper byte
+		reg       uint16
+	}{
+		{"FCG-1/2", 4, 0x6000},
+		{"LZ93D50", 5, 0x8000},
+		{"unknown", 0, 0x6000},
+		{"unknown", 0, 0x8000},
+	} {
+		f, err := newFCG(&RomInfo{Mapper: 16, Submapper: test.submapper}, testPRG(16), testCHR(0x40000, 0x400))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.CPUWrite(test.reg|8, 2)
+		f.CPUWrite(test.reg|3, 0x33)
+		f.CPUWrite(test.reg|9, 1)
+		if bank := f.CPURead(0x8000); bank != 4 {
+			t.Errorf("%s: expected 16KB bank 2 at $8000, got 8KB bank %d", test.name, bank)
+		}
+		if bank := f.CPURead(0xC000); bank != 14 {
+			t.Errorf("%s: expected last bank at $C000, got 8KB bank %d", test.name, bank)
+		}
+		if bank := f.PPURead(0x0C00, nil); bank != 0x33 {
+			t.Errorf("%s: expected CHR bank $33 at $0C00, got %#x", test.name, bank)
+		}
+		if f.mirroring != MirrorHorizontal {
+			t.Errorf("%s: expected horizontal mirroring, got %d", test.name, f.mirroring)
+		}
+	}
+}
+
+func TestFCG153(t *testing.T) {
+	f, err := newFCG(&RomInfo{Mapper: 153}, testPRG(64), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bit 0 of the CHR registers selects the outer 256KB bank
+	f.CPUWrite(0x8000, 1)
+	f.CPUWrite(0x8008, 2)
+	if bank := f.CPURead(0x8000); bank != 36 {
+		t.Errorf("expected 16KB bank $12 at $8000, got 8KB bank %d", bank)
+	}
+	if bank := f.CPURead(0xC000); bank != 62 {
+		t.Errorf("expected last bank of the outer bank at $C000, got 8KB bank %d", bank)
+	}
+
+	f.CPUWrite(0x6000, 0x42)
+	if val := f.CPURead(0x6000); val != 0 {
+		t.Errorf("expected PRG RAM to be disabled, got %#x", val)
+	}
+	f.CPUWrite(0x800D, 0x20)
+	f.CPUWrite(0x6000, 0x42)
+	if val := f.CPURead(0x6000); val != 0x42 {
+		t.Errorf("expected PRG RAM to be enabled, got %#x", val)
+	}
+}
+
+func TestFCGIRQ(t *testing.T) {
+	// The FCG-1/2 writes the counter directly
+	f, _ := newFCG(&RomInfo{Mapper: 16, Submapper: 4}, testPRG(4), make([]byte, 0x2000))
+	f.CPUWrite(0x600B, 2)
+	f.CPUWrite(0x600C, 0)
+	f.CPUWrite(0x600A, 1)
+	f.Step()
+	if f.IRQ() {
+		t.Fatal("unexpected IRQ before the counter reached 0")
+	}
+	f.Step()
+	if !f.IRQ() {
+		t.Fatal("expected IRQ when the counter reached 0")
+	}
+	f.CPUWrite(0x600A, 0)
+	if f.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+
+	// The LZ93D50 writes a latch, which is copied to the counter when enabling
+	f, _ = newFCG(&RomInfo{Mapper: 16, Submapper: 5}, testPRG(4), make([]byte, 0x2000))
+	f.CPUWrite(0x800B, 2)
+	f.CPUWrite(0x800C, 0)
+	if f.irqCounter != 0 {
+		t.Errorf("expected latch writes to leave the counter, got %#x", f.irqCounter)
+	}
+	f.CPUWrite(0x800A, 1)
+	f.Step()
+	f.Step()
+	if !f.IRQ() {
+		t.Error("expected IRQ when the counter reached 0")
+	}
+}
+
+// testEEPROMBus drives a Bandai board's EEPROM through its control register.
+type testEEPROMBus struct {
+	f      *fcg
+	x24c01 bool
+}
+
+func (b *testEEPROMBus) set(scl, sda bool) {
+	val := byte(0x80)
+	if scl {
+		val |= 0x20
+	}
+	if sda {
+		val |= 0x40
+	}
+	b.f.CPUWrite(0x800D, val)
+}
+
+func (b *testEEPROMBus) start() {
+	b.set(false, true)
+	b.set(true, true)
+	b.set(true, false)
+	b.set(false, false)
+}
+
+func (b *testEEPROMBus) stop() {
+	b.set(false, false)
+	b.set(true, false)
+	b.set(true, true)
+}
+
+// bit clocks a bit out on the data line, and returns the data line from the EEPROM.
+func (b *testEEPROMBus) bit(sda bool) bool {
+	b.set(false, sda)
+	b.set(true, sda)
+	out := b.f.CPURead(0x6000)&0x10 != 0
+	b.set(false, sda)
+	return out
+}
+
+// write sends a byte, and returns whether the EEPROM acknowledged it.
+func (b *testEEPROMBus) write(val byte) bool {
+	for i := uint(0); i < 8; i++ {
+		shift := 7 - i
+		if b.x24c01 {
+			shift = i
+		}
+		b.bit(val>>shift&1 == 1)
+	}
+	return !b.bit(true)
+}
+
+func (b *testEEPROMBus) read(ack bool) byte {
+	var val byte
+	for i := uint(0); i < 8; i++ {
+		if !b.bit(true) {
+			continue
+		}
+		if b.x24c01 {
+			val |= 1 << i
+		} else {
+			val |= 0x80 >> i
+		}
+	}
+	b.bit(!ack)
+	return val
+}
+
+func TestFCG24C02(t *testing.T) {
+	f, _ := newFCG(&RomInfo{Mapper: 16, Submapper: 5}, testPRG(4), make([]byte, 0x2000))
+	b := &testEEPROMBus{f: f}
+
+	// Write two bytes from $10
+	b.start()
+	for _, val := range []byte{0xA0, 0x10, 0x12, 0x34} {
+		if !b.write(val) {
+			t.Fatalf("expected EEPROM to acknowledge %#x", val)
+		}
+	}
+	b.stop()
+	if f.eeprom.data[0x10] != 0x12 || f.eeprom.data[0x11] != 0x34 {
+		t.Fatalf("expected bytes to be written, got % x", f.eeprom.data[0x10:0x12])
+	}
+
+	// Set the address with a dummy write, then read them back after a repeated start
+	b.start()
+	b.write(0xA0)
+	b.write(0x10)
+	b.start()
+	b.write(0xA1)
+	if val := b.read(true); val != 0x12 {
+		t.Errorf("expected to read $12, got %#x", val)
+	}
+	if val := b.read(false); val != 0x34 {
+		t.Errorf("expected to read $34, got %#x", val)
+	}
+	b.stop()
+	if f.eeprom.state != eepromIdle {
+		t.Errorf("expected EEPROM to be idle after stopping, got state %d", f.eeprom.state)
+	}
+}
+
+func TestFCGX24C01(t *testing.T) {
+	f, _ := newFCG(&RomInfo{Mapper: 159}, testPRG(4), make([]byte, 0x2000))
+	b := &testEEPROMBus{f: f, x24c01: true}
+
+	// The X24C01 has no device address, and the read bit is above the 7 bit address
+	b.start()
+	b.write(0x05)
+	b.write(0x56)
+	b.stop()
+	if f.eeprom.data[0x05] != 0x56 {
+		t.Fatalf("expected byte to be written, got %#x", f.eeprom.data[0x05])
+	}
+
+	b.start()
+	b.write(0x80 | 0x05)
+	if val := b.read(false); val != 0x56 {
+		t.Errorf("expected to read $56, got %#x", val)
+	}
+	b.stop()
+}
+
+func TestFCGSaveData(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		mapper uint16
+		size   int
+	}{
+		{"24C02", 16, 256},
+		{"X24C01", 159, 128},
+	} {
+		saved := make([]byte, test.size)
+		saved[0x05] = 0x56
+		f, err := newFCG(&RomInfo{Mapper: test.mapper, SaveData: saved}, testPRG(4), make([]byte, 0x2000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &testEEPROMBus{f: f, x24c01: test.mapper == 159}
+
+		// Read back the saved byte from $05
+		b.start()
+		if test.mapper == 159 {
+			b.write(0x80 | 0x05)
+		} else {
+			b.write(0xA0)
+			b.write(0x05)
+			b.start()
+			b.write(0xA1)
+		}
+		if val := b.read(false); val != 0x56 {
+			t.Errorf("%s: expected to read saved byte $56, got %#x", test.name, val)
+		}
+		b.stop()
+
+		data := f.SaveData()
+		if len(data) != test.size || data[0x05] != 0x56 {
+			t.Errorf("%s: expected %d B of save data with $56 at $05, got % x", test.name, test.size, data)
+		}
+	}
+
+	f, _ := newFCG(&RomInfo{Mapper: 153}, testPRG(64), make([]byte, 0x2000))
+	if data := f.SaveData(); data != nil {
+		t.Errorf("expected no save data without an EEPROM, got % x", data)
+	}
+}
@@ -0,0 +1,421 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FDS implements the Famicom Disk System RAM adapter, which maps the BIOS, 32KB of program RAM and
+// 8KB of CHR RAM, and drives the disk with a byte-level model of the head moving across it.
+// http://wiki.nesdev.com/w/index.php/Family_Computer_Disk_System
+type FDS struct {
+	bios,
+	ram,
+	chr []byte
+
+	// sides holds each disk side, encoded with the gaps and block markers seen by the drive
+	sides [][]byte
+	// side is the currently inserted side, or -1 if no disk is inserted
+	side int
+
+	// Registers
+	diskEnabled,
+	soundEnabled,
+	motorOn,
+	transferReset,
+	readMode,
+	crcControl,
+	transferStart,
+	diskIRQEnabled bool
+	writeData byte
+	mirroring Mirroring
+
+	// Drive state
+	position,
+	delay int
+	scanning,
+	gapEnded,
+	endOfHead,
+	transferred bool
+	readData byte
+
+	// IRQ state
+	timerReload,
+	timerCounter uint16
+	timerEnabled,
+	timerRepeat,
+	timerIRQ,
+	diskIRQ bool
+
+	audio *fdsAudio
+}
+
+const (
+	// FDSBIOSSize is the size of the BIOS ROM image.
+	FDSBIOSSize = 0x2000
+	// FDSSideSize is the size of a single disk side in an .fds image.
+	FDSSideSize = 65500
+
+	// fdsByteCycles is the number of CPU cycles taken to transfer a byte to or from the disk
+	fdsByteCycles = 149
+	// fdsRewindCycles is the delay before the head starts scanning from the start of the disk
+	fdsRewindCycles = 50000
+	// Gap lengths in bytes, before the first block and between each block
+	fdsLeadingGap = 28300 / 8
+	fdsBlockGap   = 976 / 8
+
+	// Block codes in the disk data
+	fdsBlockInfo       = 1
+	fdsBlockFileAmount = 2
+	fdsBlockFileHeader = 3
+	fdsBlockFileData   = 4
+)
+
+// NewFDS creates a disk system adapter with the given BIOS, and disk sides in .fds format. No disk
+// is inserted initially.
+func NewFDS(bios []byte, sides [][]byte) (*FDS, error) {
+	if len(bios) != FDSBIOSSize {
+		return nil, fmt.Errorf("expected FDS BIOS to be %d B, got %d B", FDSBIOSSize, len(bios))
+	}
+	if len(sides) == 0 {
+		return nil, errors.New("FDS image contains no disk sides")
+	}
+
+	f := &FDS{
+		bios:  bios,
+		ram:   make([]byte, 0x8000),
+		chr:   make([]byte, 0x2000),
+		side:  -1,
+		audio: newFDSAudio(),
+	}
+	for i, side := range sides {
+		encoded, err := encodeFDSSide(side)
+		if err != nil {
+			return nil, fmt.Errorf("disk side %d: %s", i, err)
+		}
+		f.sides = append(f.sides, encoded)
+	}
+	return f, nil
+}
+
+// encodeFDSSide converts the blocks of an .fds disk side into the data read by the drive, by
+// adding the gaps, start marks and CRCs that the .fds format omits.
+func encodeFDSSide(side []byte) ([]byte, error) {
+	disk := make([]byte, fdsLeadingGap, FDSSideSize*2)
+	addBlock := func(length int) error {
+		if length > len(side) {
+			return fmt.Errorf("truncated block with code %d", side[0])
+		}
+		disk = append(disk, 0x80)
+		disk = append(disk, side[:length]...)
+		// The CRC isn't verified, so any value will do
+		disk = append(disk, 0x4D, 0x62)
+		disk = append(disk, make([]byte, fdsBlockGap)...)
+		side = side[length:]
+		return nil
+	}
+
+	if len(side) == 0 || side[0] != fdsBlockInfo {
+		return nil, errors.New("missing disk info block")
+	}
+	if err := addBlock(56); err != nil {
+		return nil, err
+	}
+	if len(side) == 0 || side[0] != fdsBlockFileAmount {
+		return nil, errors.New("missing file amount block")
+	}
+	if err := addBlock(2); err != nil {
+		return nil, err
+	}
+	// Some disks contain more files than the file amount block specifies, so read until the
+	// blocks run out
+	for len(side) >= 16 && side[0] == fdsBlockFileHeader {
+		size := int(side[13]) | int(side[14])<<8
+		if err := addBlock(16); err != nil {
+			return nil, err
+		}
+		if len(side) == 0 || side[0] != fdsBlockFileData {
+			return nil, errors.New("missing file data block")
+		}
+		if err := addBlock(1 + size); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pad the rest of the disk with gap
+	if len(disk) < FDSSideSize {
+		disk = append(disk, make([]byte, FDSSideSize-len(disk))...)
+	}
+	return disk, nil
+}
+
+// Sides returns the number of disk sides available.
+func (f *FDS) Sides() int {
+	return len(f.sides)
+}
+
+// InsertDisk inserts the given (zero-based) disk side into the drive.
+func (f *FDS) InsertDisk(side int) error {
+	if side < 0 || side >= len(f.sides) {
+		return fmt.Errorf("disk side %d out of range, image has %d sides", side, len(f.sides))
+	}
+	f.side = side
+	f.position = 0
+	f.endOfHead = true
+	return nil
+}
+
+// EjectDisk removes the disk from the drive.
+func (f *FDS) EjectDisk() {
+	f.side = -1
+	f.scanning = false
+}
+
+func (f *FDS) CPURead(addr uint16) byte {
+	if addr >= 0xE000 {
+		return f.bios[addr-0xE000]
+
+	} else if addr >= 0x6000 {
+		return f.ram[addr-0x6000]
+
+	} else if addr >= 0x4040 && addr < 0x4098 {
+		if !f.soundEnabled {
+			return 0
+		}
+		return f.audio.read(addr)
+
+	} else if !f.diskEnabled {
+		return 0
+	}
+
+	switch addr {
+	case 0x4030:
+		// Disk status - reading acknowledges IRQs
+		var val byte
+		if f.timerIRQ {
+			val |= 0x01
+		}
+		if f.transferred {
+			val |= 0x02
+		}
+		if f.endOfHead {
+			val |= 0x40
+		}
+		f.timerIRQ = false
+		f.diskIRQ = false
+		f.transferred = false
+		return val
+
+	case 0x4031:
+		f.transferred = false
+		f.diskIRQ = false
+		return f.readData
+
+	case 0x4032:
+		// Drive status
+		var val byte
+		if f.side < 0 {
+			val |= 0x01
+		}
+		if f.side < 0 || !f.scanning {
+			val |= 0x02
+		}
+		if f.side < 0 {
+			// Write protected
+			val |= 0x04
+		}
+		return val
+
+	case 0x4033:
+		// External connector - battery is good
+		return 0x80
+	}
+
+	logrus.Debugf("Read from unmapped FDS address %#X", addr)
+	return 0
+}
+
+func (f *FDS) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0xE000 {
+		f.ram[addr-0x6000] = val
+		return
+
+	} else if addr >= 0x4040 && addr < 0x4098 {
+		if f.soundEnabled {
+			f.audio.write(addr, val)
+		}
+		return
+	}
+
+	switch addr {
+	case 0x4020:
+		f.timerReload = f.timerReload&0xFF00 | uint16(val)
+
+	case 0x4021:
+		f.timerReload = f.timerReload&0xFF | uint16(val)<<8
+
+	case 0x4022:
+		if !f.diskEnabled {
+			return
+		}
+		f.timerRepeat = val&1 == 1
+		f.timerEnabled = val&2 != 0
+		if f.timerEnabled {
+			f.timerCounter = f.timerReload
+		} else {
+			f.timerIRQ = false
+		}
+
+	case 0x4023:
+		f.diskEnabled = val&1 == 1
+		f.soundEnabled = val&2 != 0
+		if !f.diskEnabled {
+			f.timerEnabled = false
+			f.timerIRQ = false
+			f.diskIRQ = false
+		}
+
+	case 0x4024:
+		if !f.diskEnabled {
+			return
+		}
+		f.writeData = val
+		f.transferred = false
+		f.diskIRQ = false
+
+	case 0x4025:
+		if !f.diskEnabled {
+			return
+		}
+		f.diskIRQ = false
+		f.motorOn = val&1 == 1
+		f.transferReset = val&2 != 0
+		f.readMode = val&4 != 0
+		if val&8 != 0 {
+			f.mirroring = MirrorHorizontal
+		} else {
+			f.mirroring = MirrorVertical
+		}
+		f.crcControl = val&0x10 != 0
+		f.transferStart = val&0x40 != 0
+		f.diskIRQEnabled = val&0x80 != 0
+
+	case 0x4026:
+		// External connector output - nothing is connected
+
+	default:
+		logrus.Debugf("Write to unmapped FDS address %#X", addr)
+	}
+}
+
+func (f *FDS) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return f.chr[addr]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[f.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled FDS PPU memory read from address %#x", addr))
+}
+
+func (f *FDS) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		f.chr[addr] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[f.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled FDS PPU memory write to address %#x", addr))
+	}
+}
+
+// Step advances the IRQ timer, disk drive and audio by a CPU cycle.
+func (f *FDS) Step() {
+	if f.timerEnabled {
+		if f.timerCounter == 0 {
+			f.timerIRQ = true
+			f.timerCounter = f.timerReload
+			if !f.timerRepeat {
+				f.timerEnabled = false
+			}
+		} else {
+			f.timerCounter--
+		}
+	}
+
+	f.stepDrive()
+	f.audio.step()
+}
+
+// stepDrive moves the disk under the head, transferring a byte whenever one has passed.
+func (f *FDS) stepDrive() {
+	if f.side < 0 || !f.motorOn {
+		f.endOfHead = true
+		f.scanning = false
+		return
+	}
+	if f.transferReset && !f.scanning {
+		return
+	}
+	if f.endOfHead {
+		// Rewind to the start of the disk
+		f.delay = fdsRewindCycles
+		f.endOfHead = false
+		f.position = 0
+		f.gapEnded = false
+		return
+	}
+	if f.delay > 0 {
+		f.delay--
+		return
+	}
+
+	f.scanning = true
+	disk := f.sides[f.side]
+	if f.readMode {
+		val := disk[f.position]
+		if !f.transferStart {
+			f.gapEnded = false
+		} else if val != 0 && !f.gapEnded {
+			// Start mark indicates the beginning of a block, and isn't itself transferred
+			f.gapEnded = true
+		} else if f.gapEnded {
+			f.readData = val
+			f.transferred = true
+			if f.diskIRQEnabled {
+				f.diskIRQ = true
+			}
+		}
+	} else {
+		// Write mode - the BIOS writes its own gap and start marks
+		var val byte
+		if f.transferStart {
+			val = f.writeData
+		}
+		if !f.crcControl {
+			f.transferred = true
+			if f.diskIRQEnabled {
+				f.diskIRQ = true
+			}
+		}
+		disk[f.position] = val
+		f.gapEnded = false
+	}
+
+	f.position++
+	if f.position >= len(disk) {
+		f.motorOn = false
+		f.endOfHead = true
+	} else {
+		f.delay = fdsByteCycles
+	}
+}
+
+func (f *FDS) IRQ() bool {
+	return f.timerIRQ || f.diskIRQ
+}
+
+func (f *FDS) AudioOutput() float64 {
+	return f.audio.output()
+}
@@ -0,0 +1,234 @@
+package cartridge
+
+import "math"
+
+// fdsAudio implements the FDS expansion sound: a single 64-step wavetable channel, with a
+// frequency modulation unit and volume and modulation envelopes.
+// http://wiki.nesdev.com/w/index.php/FDS_audio
+type fdsAudio struct {
+	wave [64]byte
+	// waveWrite enables writes to the wavetable, and holds the output
+	waveWrite   bool
+	masterLevel byte
+
+	// Wave channel
+	freq        uint16
+	waveHalt    bool
+	wavePos     byte
+	waveAcc     uint32
+	lastLevel   float64
+	filterLevel float64
+
+	// Modulation unit
+	modTable   [64]byte
+	modPos     byte
+	modFreq    uint16
+	modHalt    bool
+	modAcc     uint32
+	modCounter int8
+
+	// Envelopes
+	envelopesDisabled bool
+	envelopeSpeed     byte
+	volume,
+	sweep fdsEnvelope
+}
+
+// fdsEnvelope adjusts a gain up or down over time, or holds a gain set directly.
+type fdsEnvelope struct {
+	direct,
+	increase bool
+	speed,
+	gain byte
+	timer int
+}
+
+const (
+	// fdsLevel scales the FDS output relative to the APU, roughly matching a full volume wave to
+	// a pair of full volume APU pulse channels
+	fdsLevel = apuPulseStep * 30 / (63 * 32)
+	// fdsFilterCoefficient implements the output's ~2kHz low pass filter at the CPU clock rate
+	fdsFilterCoefficient = 2 * math.Pi * 2000 / nsfCPUClock
+)
+
+// fdsMasterVolumes holds the scale applied by each master volume setting.
+var fdsMasterVolumes = [4]float64{1, 2.0 / 3, 2.0 / 4, 2.0 / 5}
+
+// fdsModSteps holds the change to the modulation counter for each modulation table value, except
+// for 4 which resets the counter.
+var fdsModSteps = [8]int8{0, 1, 2, 4, 0, -4, -2, -1}
+
+func newFDSAudio() *fdsAudio {
+	return &fdsAudio{
+		envelopeSpeed: 0xE8,
+	}
+}
+
+// read handles a read from $4040-$4097.
+func (f *fdsAudio) read(addr uint16) byte {
+	switch {
+	case addr < 0x4080:
+		return f.wave[addr-0x4040]
+	case addr == 0x4090:
+		return f.volume.gain
+	case addr == 0x4092:
+		return f.sweep.gain
+	}
+	return 0
+}
+
+// write handles a write to $4040-$4097.
+func (f *fdsAudio) write(addr uint16, val byte) {
+	if addr < 0x4080 {
+		if f.waveWrite {
+			f.wave[addr-0x4040] = val & 0x3F
+		}
+		return
+	}
+
+	switch addr {
+	case 0x4080:
+		f.volume.write(val)
+	case 0x4082:
+		f.freq = f.freq&0xF00 | uint16(val)
+	case 0x4083:
+		f.freq = f.freq&0xFF | uint16(val&0xF)<<8
+		f.waveHalt = val>>7 == 1
+		f.envelopesDisabled = val&0x40 != 0
+		if f.waveHalt {
+			f.wavePos = 0
+			f.waveAcc = 0
+		}
+	case 0x4084:
+		f.sweep.write(val)
+	case 0x4085:
+		f.modCounter = int8(val<<1) >> 1
+	case 0x4086:
+		f.modFreq = f.modFreq&0xF00 | uint16(val)
+	case 0x4087:
+		f.modFreq = f.modFreq&0xFF | uint16(val&0xF)<<8
+		f.modHalt = val>>7 == 1
+		if f.modHalt {
+			f.modAcc = 0
+		}
+	case 0x4088:
+		// The table can only be written while halted, and each write fills two entries
+		if f.modHalt {
+			f.modTable[f.modPos] = val & 7
+			f.modTable[(f.modPos+1)&0x3F] = val & 7
+			f.modPos = (f.modPos + 2) & 0x3F
+		}
+	case 0x4089:
+		f.waveWrite = val>>7 == 1
+		f.masterLevel = val & 3
+	case 0x408A:
+		f.envelopeSpeed = val
+	}
+}
+
+func (e *fdsEnvelope) write(val byte) {
+	e.direct = val>>7 == 1
+	e.increase = val&0x40 != 0
+	e.speed = val & 0x3F
+	if e.direct {
+		e.gain = e.speed
+	}
+	e.timer = 0
+}
+
+// clock advances the envelope by a CPU cycle, given the master envelope speed.
+func (e *fdsEnvelope) clock(masterSpeed byte) {
+	if e.direct {
+		return
+	}
+	e.timer++
+	if e.timer < 8*int(masterSpeed)*(int(e.speed)+1) {
+		return
+	}
+	e.timer = 0
+	if e.increase && e.gain < 32 {
+		e.gain++
+	} else if !e.increase && e.gain > 0 {
+		e.gain--
+	}
+}
+
+// step advances the channel by a CPU cycle.
+func (f *fdsAudio) step() {
+	if !f.envelopesDisabled && !f.waveHalt && f.envelopeSpeed != 0 {
+		f.volume.clock(f.envelopeSpeed)
+		f.sweep.clock(f.envelopeSpeed)
+	}
+
+	if !f.modHalt && f.modFreq != 0 {
+		f.modAcc += uint32(f.modFreq)
+		if f.modAcc >= 0x10000 {
+			f.modAcc -= 0x10000
+			f.stepModulator()
+		}
+	}
+
+	if !f.waveHalt && !f.waveWrite {
+		f.waveAcc += f.modulatedFreq()
+		if f.waveAcc >= 0x10000 {
+			f.waveAcc &= 0xFFFF
+			f.wavePos = (f.wavePos + 1) & 0x3F
+		}
+		gain := f.volume.gain
+		if gain > 32 {
+			gain = 32
+		}
+		f.lastLevel = float64(f.wave[f.wavePos]) * float64(gain) * fdsMasterVolumes[f.masterLevel]
+	}
+	f.filterLevel += (f.lastLevel - f.filterLevel) * fdsFilterCoefficient
+}
+
+func (f *fdsAudio) stepModulator() {
+	step := f.modTable[f.modPos]
+	if step == 4 {
+		f.modCounter = 0
+	} else {
+		// Counter is a 7-bit signed value, which wraps around
+		f.modCounter = int8(byte(f.modCounter+fdsModSteps[step])<<1) >> 1
+	}
+	f.modPos = (f.modPos + 1) & 0x3F
+}
+
+// modulatedFreq applies the modulation unit to the wave frequency.
+func (f *fdsAudio) modulatedFreq() uint32 {
+	pitch := int(f.freq)
+	if f.modHalt || f.modFreq == 0 {
+		return uint32(pitch)
+	}
+
+	temp := int(f.modCounter) * int(f.sweep.gain)
+	remainder := temp & 0xF
+	temp >>= 4
+	if remainder > 0 && temp&0x80 == 0 {
+		if f.modCounter < 0 {
+			temp--
+		} else {
+			temp += 2
+		}
+	}
+	if temp >= 192 {
+		temp -= 256
+	} else if temp < -64 {
+		temp += 256
+	}
+
+	temp *= pitch
+	remainder = temp & 0x3F
+	temp >>= 6
+	if remainder >= 32 {
+		temp++
+	}
+	if pitch+temp < 0 {
+		return 0
+	}
+	return uint32(pitch + temp)
+}
+
+func (f *fdsAudio) output() float64 {
+	return f.filterLevel * fdsLevel
+}
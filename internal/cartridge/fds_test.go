@@ -0,0 +1,135 @@
+package cartridge
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testFDSSide builds a disk side containing a single 4 byte file.
+func testFDSSide() []byte {
+	side := make([]byte, FDSSideSize)
+	copy(side, "\x01*NINTENDO-HVC*")
+	side[56] = fdsBlockFileAmount
+	side[57] = 1
+	side[58] = fdsBlockFileHeader
+	side[58+13] = 4
+	side[74] = fdsBlockFileData
+	copy(side[75:], []byte{1, 2, 3, 4})
+	return side
+}
+
+func newTestFDS(t *testing.T) *FDS {
+	f, err := NewFDS(make([]byte, FDSBIOSSize), [][]byte{testFDSSide(), testFDSSide()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFDSReadDisk(t *testing.T) {
+	f := newTestFDS(t)
+	f.CPUWrite(0x4023, 0x01)
+	if status := f.CPURead(0x4032); status&1 == 0 {
+		t.Fatalf("expected no disk to be inserted, got status %#x", status)
+	}
+	if err := f.InsertDisk(2); err == nil {
+		t.Error("expected error inserting out of range side")
+	}
+	if err := f.InsertDisk(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Motor on, read mode, and start reading after the gap
+	f.CPUWrite(0x4025, 0x65)
+	expected := []byte("\x01*NINTENDO-HVC*")
+	read := make([]byte, len(expected))
+	for i := range read {
+		for cycles := 0; f.CPURead(0x4030)&0x02 == 0; cycles++ {
+			// The head has to travel across the leading gap before the first byte
+			if cycles > fdsRewindCycles+(fdsLeadingGap+3)*(fdsByteCycles+1) {
+				t.Fatalf("timed out waiting for byte %d", i)
+			}
+			f.Step()
+		}
+		read[i] = f.CPURead(0x4031)
+	}
+	if !bytes.Equal(read, expected) {
+		t.Errorf("expected to read %q, got %q", expected, read)
+	}
+	if status := f.CPURead(0x4032); status&0x03 != 0 {
+		t.Errorf("expected disk to be inserted and ready, got status %#x", status)
+	}
+
+	f.EjectDisk()
+	f.Step()
+	if status := f.CPURead(0x4032); status&0x03 != 0x03 {
+		t.Errorf("expected disk to be ejected, got status %#x", status)
+	}
+}
+
+func TestFDSTimerIRQ(t *testing.T) {
+	f := newTestFDS(t)
+	f.CPUWrite(0x4023, 0x01)
+	f.CPUWrite(0x4020, 10)
+	f.CPUWrite(0x4021, 0)
+	f.CPUWrite(0x4022, 0x03)
+	for i := 0; i <= 10; i++ {
+		if f.IRQ() {
+			t.Fatalf("unexpected IRQ after %d cycles", i)
+		}
+		f.Step()
+	}
+	if !f.IRQ() {
+		t.Fatal("expected timer IRQ")
+	}
+	if status := f.CPURead(0x4030); status&1 == 0 || f.IRQ() {
+		t.Errorf("expected reading status to report and acknowledge IRQ, got %#x", status)
+	}
+
+	// Repeat mode reloads the counter
+	for i := 0; i <= 10; i++ {
+		f.Step()
+	}
+	if !f.IRQ() {
+		t.Error("expected repeated timer IRQ")
+	}
+
+	// Disabling disk registers also disables the timer
+	f.CPUWrite(0x4023, 0x00)
+	if f.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+}
+
+func TestFDSAudio(t *testing.T) {
+	f := newTestFDS(t)
+	f.CPUWrite(0x4023, 0x02)
+	// Write a square wave into the wavetable
+	f.CPUWrite(0x4089, 0x80)
+	for i := uint16(0); i < 64; i++ {
+		var val byte
+		if i < 32 {
+			val = 63
+		}
+		f.CPUWrite(0x4040+i, val)
+	}
+	if val := f.CPURead(0x4040); val != 63 {
+		t.Errorf("expected to read back wavetable, got %d", val)
+	}
+	f.CPUWrite(0x4089, 0x00)
+	// Direct volume, and a frequency that steps through the table every 64 cycles
+	f.CPUWrite(0x4080, 0x80|32)
+	f.CPUWrite(0x4082, 0x00)
+	f.CPUWrite(0x4083, 0x04)
+
+	var peak float64
+	for i := 0; i < 64*64*4; i++ {
+		f.Step()
+		if out := f.AudioOutput(); out > peak {
+			peak = out
+		}
+	}
+	if peak < 63*32*fdsLevel/2 {
+		t.Errorf("expected audible output, got peak %f", peak)
+	}
+}
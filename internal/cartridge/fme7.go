@@ -0,0 +1,158 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/Sunsoft_FME-7
+
+func newFME7(info *RomInfo, prg, chr []byte) (*fme7, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &fme7{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+		audio:     newSunsoft5BAudio(),
+	}, nil
+}
+
+type fme7 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+
+	command  byte
+	chrBanks [8]byte
+	// prgBanks holds the banks for $6000, $8000, $A000 and $C000
+	prgBanks [4]byte
+	ramSelected,
+	ramEnabled bool
+
+	irqCounter uint16
+	irqEnabled,
+	irqCounterEnabled,
+	irqPending bool
+
+	// The Sunsoft 5B is an FME-7 with expansion audio, which is harmless to include for other
+	// boards since the audio registers are otherwise unused
+	audio *sunsoft5BAudio
+}
+
+func (f *fme7) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if !f.ramSelected {
+			return f.prg[f.prgAddr(f.prgBanks[0], addr)]
+		}
+		if !f.ramEnabled {
+			return 0
+		}
+		return f.ram[(int(f.prgBanks[0])*0x2000+int(addr&0x1FFF))%len(f.ram)]
+
+	} else if addr >= 0xE000 {
+		// Last bank is fixed
+		return f.prg[len(f.prg)-0x2000+int(addr&0x1FFF)]
+
+	} else if addr >= 0x8000 {
+		return f.prg[f.prgAddr(f.prgBanks[(addr-0x6000)/0x2000], addr)]
+
+	}
+	logrus.Debugf("Read from unmapped FME-7 address %#X", addr)
+	return 0
+}
+
+func (f *fme7) prgAddr(bank byte, addr uint16) int {
+	return (int(bank)*0x2000 + int(addr&0x1FFF)) % len(f.prg)
+}
+
+func (f *fme7) CPUWrite(addr uint16, val byte) {
+	switch {
+	case addr >= 0x6000 && addr < 0x8000:
+		if f.ramSelected && f.ramEnabled {
+			f.ram[(int(f.prgBanks[0])*0x2000+int(addr&0x1FFF))%len(f.ram)] = val
+		}
+	case addr >= 0x8000 && addr < 0xA000:
+		f.command = val & 0xF
+	case addr >= 0xA000 && addr < 0xC000:
+		f.writeParameter(val)
+	case addr >= 0xC000 && addr < 0xE000:
+		f.audio.writeReg(val)
+	case addr >= 0xE000:
+		f.audio.writeData(val)
+	default:
+		logrus.Debugf("Write to unmapped FME-7 address %#X", addr)
+	}
+}
+
+func (f *fme7) writeParameter(val byte) {
+	switch f.command {
+	case 0, 1, 2, 3, 4, 5, 6, 7:
+		f.chrBanks[f.command] = val
+	case 8:
+		f.prgBanks[0] = val & 0x3F
+		f.ramSelected = val&0x40 != 0
+		f.ramEnabled = val&0x80 != 0
+	case 9, 0xA, 0xB:
+		f.prgBanks[f.command-8] = val & 0x3F
+	case 0xC:
+		f.mirroring = vrc6Mirroring[val&3]
+	case 0xD:
+		f.irqEnabled = val&1 == 1
+		f.irqCounterEnabled = val&0x80 != 0
+		f.irqPending = false
+	case 0xE:
+		f.irqCounter = f.irqCounter&0xFF00 | uint16(val)
+	case 0xF:
+		f.irqCounter = f.irqCounter&0xFF | uint16(val)<<8
+	}
+}
+
+func (f *fme7) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return f.chr[f.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[f.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled FME-7 PPU memory read from address %#x", addr))
+}
+
+func (f *fme7) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Some boards use CHR RAM
+		f.chr[f.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[f.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled FME-7 PPU memory write to address %#x", addr))
+	}
+}
+
+func (f *fme7) chrAddr(addr uint16) int {
+	bank := f.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(f.chr)
+}
+
+func (f *fme7) Step() {
+	if f.irqCounterEnabled {
+		// The IRQ fires when the counter wraps from $0000 to $FFFF
+		f.irqCounter--
+		if f.irqCounter == 0xFFFF && f.irqEnabled {
+			f.irqPending = true
+		}
+	}
+	f.audio.step()
+}
+
+func (f *fme7) IRQ() bool {
+	return f.irqPending
+}
+
+func (f *fme7) AudioOutput() float64 {
+	return f.audio.output()
+}
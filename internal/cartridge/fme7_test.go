@@ -0,0 +1,87 @@
+package cartridge
+
+import "testing"
+
+func TestFME7Banking(t *testing.T) {
+	f, err := newFME7(&RomInfo{}, testPRG(32), testCHR(0x40000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for command, val := range []byte{0: 0x10, 7: 0x17, 9: 3, 0xA: 4, 0xB: 5} {
+		f.CPUWrite(0x8000, byte(command))
+		f.CPUWrite(0xA000, val)
+	}
+	for addr, expected := range map[uint16]byte{0x8000: 3, 0xA000: 4, 0xC000: 5, 0xE000: 31} {
+		if bank := f.CPURead(addr); bank != expected {
+			t.Errorf("expected bank %d at %#x, got %d", expected, addr, bank)
+		}
+	}
+	if bank := f.PPURead(0x0000, nil); bank != 0x10 {
+		t.Errorf("expected CHR bank $10 at $0000, got %#x", bank)
+	}
+	if bank := f.PPURead(0x1C00, nil); bank != 0x17 {
+		t.Errorf("expected CHR bank $17 at $1C00, got %#x", bank)
+	}
+
+	// $6000 maps PRG ROM, or RAM when selected and enabled
+	f.CPUWrite(0x8000, 8)
+	f.CPUWrite(0xA000, 6)
+	if bank := f.CPURead(0x6000); bank != 6 {
+		t.Errorf("expected PRG ROM bank 6 at $6000, got %d", bank)
+	}
+	f.CPUWrite(0xA000, 0x40)
+	f.CPUWrite(0x6000, 0x42)
+	if val := f.CPURead(0x6000); val != 0 {
+		t.Errorf("expected PRG RAM to be disabled, got %#x", val)
+	}
+	f.CPUWrite(0xA000, 0xC0)
+	f.CPUWrite(0x6000, 0x42)
+	if val := f.CPURead(0x6000); val != 0x42 {
+		t.Errorf("expected PRG RAM to be enabled, got %#x", val)
+	}
+
+	f.CPUWrite(0x8000, 0xC)
+	f.CPUWrite(0xA000, 3)
+	if f.mirroring != MirrorSingleScreenB {
+		t.Errorf("expected single screen mirroring, got %d", f.mirroring)
+	}
+}
+
+func TestFME7IRQ(t *testing.T) {
+	f, _ := newFME7(&RomInfo{}, testPRG(4), make([]byte, 0x2000))
+	f.CPUWrite(0x8000, 0xE)
+	f.CPUWrite(0xA000, 2)
+	f.CPUWrite(0x8000, 0xF)
+	f.CPUWrite(0xA000, 0)
+	// Counting without the IRQ enabled
+	f.CPUWrite(0x8000, 0xD)
+	f.CPUWrite(0xA000, 0x80)
+	for i := 0; i < 3; i++ {
+		f.Step()
+	}
+	if f.IRQ() {
+		t.Fatal("expected no IRQ while disabled")
+	}
+	if f.irqCounter != 0xFFFF {
+		t.Fatalf("expected counter to wrap to $FFFF, got %#x", f.irqCounter)
+	}
+
+	f.CPUWrite(0x8000, 0xE)
+	f.CPUWrite(0xA000, 1)
+	f.CPUWrite(0x8000, 0xF)
+	f.CPUWrite(0xA000, 0)
+	f.CPUWrite(0x8000, 0xD)
+	f.CPUWrite(0xA000, 0x81)
+	f.Step()
+	if f.IRQ() {
+		t.Fatal("unexpected IRQ before the counter wrapped")
+	}
+	f.Step()
+	if !f.IRQ() {
+		t.Fatal("expected IRQ when the counter wrapped")
+	}
+	f.CPUWrite(0xA000, 0x81)
+	if f.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+}
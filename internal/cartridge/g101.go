@@ -0,0 +1,127 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_032
+
+func newG101(info *RomInfo, prg, chr []byte) (*g101, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	g := &g101{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+	}
+	if info.Submapper == 1 {
+		// Major League hardwires single screen mirroring
+		g.fixedMirroring = true
+		g.mirroring = MirrorSingleScreenA
+	}
+	return g, nil
+}
+
+type g101 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring      Mirroring
+	fixedMirroring bool
+
+	prgBanks [2]byte
+	// prgMode swaps the switchable $8000 bank with the fixed $C000 bank
+	prgMode  bool
+	chrBanks [8]byte
+}
+
+func (g *g101) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return g.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return g.prg[g.prgAddr(addr)]
+
+	}
+	logrus.Debugf("Read from unmapped G-101 address %#X", addr)
+	return 0
+}
+
+func (g *g101) prgAddr(addr uint16) int {
+	secondLast := len(g.prg)/0x2000 - 2
+	var bank int
+	switch addr & 0xE000 {
+	case 0x8000:
+		bank = int(g.prgBanks[0])
+		if g.prgMode {
+			bank = secondLast
+		}
+	case 0xA000:
+		bank = int(g.prgBanks[1])
+	case 0xC000:
+		bank = secondLast
+		if g.prgMode {
+			bank = int(g.prgBanks[0])
+		}
+	case 0xE000:
+		bank = secondLast + 1
+	}
+	return (bank*0x2000 + int(addr&0x1FFF)) % len(g.prg)
+}
+
+func (g *g101) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		g.ram[addr-0x6000] = val
+		return
+	}
+
+	switch addr & 0xF000 {
+	case 0x8000:
+		g.prgBanks[0] = val & 0x1F
+	case 0x9000:
+		if !g.fixedMirroring {
+			g.prgMode = val&2 != 0
+			if val&1 == 1 {
+				g.mirroring = MirrorHorizontal
+			} else {
+				g.mirroring = MirrorVertical
+			}
+		}
+	case 0xA000:
+		g.prgBanks[1] = val & 0x1F
+	case 0xB000:
+		g.chrBanks[addr&7] = val
+	default:
+		logrus.Debugf("Write to unmapped G-101 address %#X", addr)
+	}
+}
+
+func (g *g101) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return g.chr[g.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[g.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled G-101 PPU memory read from address %#x", addr))
+}
+
+func (g *g101) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		g.chr[g.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[g.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled G-101 PPU memory write to address %#x", addr))
+	}
+}
+
+func (g *g101) chrAddr(addr uint16) int {
+	bank := g.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(g.chr)
+}
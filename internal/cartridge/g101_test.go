@@ -0,0 +1,43 @@
+package cartridge
+
+import "testing"
+
+func TestG101Banking(t *testing.T) {
+	g, err := newG101(&RomInfo{Mapper: 32}, testPRG(16), testCHR(0x20000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.CPUWrite(0x8000, 3)
+	g.CPUWrite(0xA000, 4)
+	g.CPUWrite(0xB007, 0x45)
+	for _, prgMode := range []byte{0, 2} {
+		g.CPUWrite(0x9000, prgMode)
+		expected := [4]byte{3, 4, 14, 15}
+		if prgMode != 0 {
+			expected = [4]byte{14, 4, 3, 15}
+		}
+		for i, bank := range expected {
+			addr := 0x8000 + uint16(i)*0x2000
+			if actual := g.CPURead(addr); actual != bank {
+				t.Errorf("PRG mode %d: expected bank %d at %#x, got %d", prgMode, bank, addr, actual)
+			}
+		}
+	}
+	if bank := g.PPURead(0x1C00, nil); bank != 0x45 {
+		t.Errorf("expected CHR bank $45 at $1C00, got %#x", bank)
+	}
+	g.CPUWrite(0x9000, 1)
+	if g.mirroring != MirrorHorizontal {
+		t.Errorf("expected horizontal mirroring, got %d", g.mirroring)
+	}
+
+	// Major League has hardwired mirroring, and ignores the PRG mode
+	g, _ = newG101(&RomInfo{Mapper: 32, Submapper: 1}, testPRG(16), testCHR(0x20000, 0x400))
+	g.CPUWrite(0x9000, 3)
+	if g.mirroring != MirrorSingleScreenA {
+		t.Errorf("expected single screen mirroring, got %d", g.mirroring)
+	}
+	if bank := g.CPURead(0xC000); bank != 14 {
+		t.Errorf("expected second last bank at $C000, got %d", bank)
+	}
+}
@@ -0,0 +1,89 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/GxROM
+
+func newGxROM(info *RomInfo, prg, chr []byte) (*gxrom, error) {
+	if len(prg) < 0x8000 || len(prg)%0x8000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 32KB, got %d B", len(prg))
+	}
+	if len(chr) < 0x2000 || len(chr)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected CHR ROM to be a multiple of 8KB, got %d B", len(chr))
+	}
+	return &gxrom{
+		prg:          prg,
+		chr:          chr,
+		ram:          newPRGRAM(info),
+		mirroring:    info.Mirroring,
+		busConflicts: hasBusConflicts(info),
+	}, nil
+}
+
+type gxrom struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring    Mirroring
+	busConflicts bool
+
+	prgBank,
+	chrBank byte
+}
+
+func (g *gxrom) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return g.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return g.prg[(int(g.prgBank)*0x8000+int(addr&0x7FFF))%len(g.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped GxROM address %#X", addr)
+	return 0
+}
+
+func (g *gxrom) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		g.ram[addr-0x6000] = val
+
+	} else if addr >= 0x8000 {
+		if g.busConflicts {
+			val &= g.CPURead(addr)
+		}
+		g.prgBank = (val >> 4) & 3
+		g.chrBank = val & 3
+
+	} else {
+		logrus.Debugf("Write to unmapped GxROM address %#X", addr)
+	}
+}
+
+func (g *gxrom) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return g.chr[g.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[g.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled GxROM PPU memory read from address %#x", addr))
+}
+
+func (g *gxrom) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		g.chr[g.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[g.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled GxROM PPU memory write to address %#x", addr))
+	}
+}
+
+func (g *gxrom) chrAddr(addr uint16) int {
+	return (int(g.chrBank)*0x2000 + int(addr)) % len(g.chr)
+}
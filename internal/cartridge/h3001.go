@@ -0,0 +1,138 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_065
+
+func newH3001(info *RomInfo, prg, chr []byte) (*h3001, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &h3001{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+		prgBanks:  [3]byte{0, 1, 0xFE},
+	}, nil
+}
+
+type h3001 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+
+	prgBanks [3]byte
+	chrBanks [8]byte
+
+	irqReload,
+	irqCounter uint16
+	irqEnabled,
+	irqPending bool
+}
+
+func (h *h3001) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return h.ram[addr-0x6000]
+
+	} else if addr >= 0xE000 {
+		// Last bank is fixed
+		return h.prg[len(h.prg)-0x2000+int(addr&0x1FFF)]
+
+	} else if addr >= 0x8000 {
+		bank := h.prgBanks[(addr-0x8000)/0x2000]
+		return h.prg[(int(bank)*0x2000+int(addr&0x1FFF))%len(h.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped H-3001 address %#X", addr)
+	return 0
+}
+
+func (h *h3001) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		h.ram[addr-0x6000] = val
+		return
+	}
+
+	switch addr & 0xF000 {
+	case 0x8000:
+		h.prgBanks[0] = val
+	case 0x9000:
+		h.writeControl(addr, val)
+	case 0xA000:
+		h.prgBanks[1] = val
+	case 0xB000:
+		h.chrBanks[addr&7] = val
+	case 0xC000:
+		h.prgBanks[2] = val
+	default:
+		logrus.Debugf("Write to unmapped H-3001 address %#X", addr)
+	}
+}
+
+func (h *h3001) writeControl(addr uint16, val byte) {
+	switch addr & 7 {
+	case 1:
+		if val&0x80 != 0 {
+			h.mirroring = MirrorHorizontal
+		} else {
+			h.mirroring = MirrorVertical
+		}
+	case 3:
+		h.irqEnabled = val&0x80 != 0
+		h.irqPending = false
+	case 4:
+		h.irqCounter = h.irqReload
+		h.irqPending = false
+	case 5:
+		h.irqReload = h.irqReload&0xFF | uint16(val)<<8
+	case 6:
+		h.irqReload = h.irqReload&0xFF00 | uint16(val)
+	}
+}
+
+func (h *h3001) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return h.chr[h.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[h.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled H-3001 PPU memory read from address %#x", addr))
+}
+
+func (h *h3001) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		h.chr[h.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[h.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled H-3001 PPU memory write to address %#x", addr))
+	}
+}
+
+func (h *h3001) chrAddr(addr uint16) int {
+	bank := h.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(h.chr)
+}
+
+func (h *h3001) Step() {
+	// The counter stops at 0, after firing the IRQ
+	if !h.irqEnabled || h.irqCounter == 0 {
+		return
+	}
+	h.irqCounter--
+	if h.irqCounter == 0 {
+		h.irqPending = true
+	}
+}
+
+func (h *h3001) IRQ() bool {
+	return h.irqPending
+}
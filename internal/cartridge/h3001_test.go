@@ -0,0 +1,50 @@
+package cartridge
+
+import "testing"
+
+func TestH3001Banking(t *testing.T) {
+	h, err := newH3001(&RomInfo{Mapper: 65}, testPRG(16), testCHR(0x20000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.CPUWrite(0x8000, 3)
+	h.CPUWrite(0xA000, 4)
+	h.CPUWrite(0xC000, 5)
+	h.CPUWrite(0xB003, 0x45)
+	for addr, expected := range map[uint16]byte{0x8000: 3, 0xA000: 4, 0xC000: 5, 0xE000: 15} {
+		if bank := h.CPURead(addr); bank != expected {
+			t.Errorf("expected bank %d at %#x, got %d", expected, addr, bank)
+		}
+	}
+	if bank := h.PPURead(0x0C00, nil); bank != 0x45 {
+		t.Errorf("expected CHR bank $45 at $0C00, got %#x", bank)
+	}
+	h.CPUWrite(0x9001, 0x80)
+	if h.mirroring != MirrorHorizontal {
+		t.Errorf("expected horizontal mirroring, got %d", h.mirroring)
+	}
+}
+
+func TestH3001IRQ(t *testing.T) {
+	h, _ := newH3001(&RomInfo{Mapper: 65}, testPRG(4), make([]byte, 0x2000))
+	h.CPUWrite(0x9005, 0)
+	h.CPUWrite(0x9006, 2)
+	h.CPUWrite(0x9004, 0)
+	h.CPUWrite(0x9003, 0x80)
+	h.Step()
+	if h.IRQ() {
+		t.Fatal("unexpected IRQ before the counter reached 0")
+	}
+	h.Step()
+	if !h.IRQ() {
+		t.Fatal("expected IRQ when the counter reached 0")
+	}
+	h.CPUWrite(0x9003, 0x80)
+	// The counter stops at 0 rather than wrapping
+	for i := 0; i < 0x10000; i++ {
+		h.Step()
+	}
+	if h.IRQ() {
+		t.Error("expected no further IRQs after the counter stopped")
+	}
+}
@@ -0,0 +1,39 @@
+package cartridge
+
+// Mirroring is the arrangement of the PPU nametables at $2000-$2FFF.
+// http://wiki.nesdev.com/w/index.php/Mirroring#Nametable_Mirroring
+type Mirroring byte
+
+const (
+	// MirrorHorizontal maps $2000 and $2400 to the first nametable, and $2800 and $2C00 to the
+	// second, for vertical scrolling
+	MirrorHorizontal Mirroring = iota
+	// MirrorVertical maps $2000 and $2800 to the first nametable, and $2400 and $2C00 to the
+	// second, for horizontal scrolling
+	MirrorVertical
+	// MirrorSingleScreenA maps all four nametables to the first
+	MirrorSingleScreenA
+	// MirrorSingleScreenB maps all four nametables to the second
+	MirrorSingleScreenB
+	// MirrorFourScreen provides four separate nametables, with extra RAM on the cartridge
+	MirrorFourScreen
+)
+
+// NametableAddr maps a PPU address in $2000-$3EFF to an offset in the PPU's VRAM. The PPU provides
+// 4KB, which is enough to back four screen mirroring as well.
+func (m Mirroring) NametableAddr(addr uint16) uint16 {
+	var table uint16
+	switch m {
+	case MirrorHorizontal:
+		table = (addr >> 11) & 1
+	case MirrorVertical:
+		table = (addr >> 10) & 1
+	case MirrorSingleScreenA:
+		table = 0
+	case MirrorSingleScreenB:
+		table = 1
+	case MirrorFourScreen:
+		table = (addr >> 10) & 3
+	}
+	return table<<10 | addr&0x3FF
+}
@@ -0,0 +1,63 @@
+package cartridge
+
+import "testing"
+
+func TestNametableAddr(t *testing.T) {
+	// Offsets for $2000, $2400, $2800 and $2C00
+	tests := []struct {
+		mirroring Mirroring
+		expected  [4]uint16
+	}{
+		{MirrorHorizontal, [4]uint16{0x000, 0x000, 0x400, 0x400}},
+		{MirrorVertical, [4]uint16{0x000, 0x400, 0x000, 0x400}},
+		{MirrorSingleScreenA, [4]uint16{0x000, 0x000, 0x000, 0x000}},
+		{MirrorSingleScreenB, [4]uint16{0x400, 0x400, 0x400, 0x400}},
+		{MirrorFourScreen, [4]uint16{0x000, 0x400, 0x800, 0xC00}},
+	}
+	for _, test := range tests {
+		for i, expected := range test.expected {
+			addr := 0x2000 + uint16(i)*0x400 + 0x123
+			if actual := test.mirroring.NametableAddr(addr); actual != expected+0x123 {
+				t.Errorf("mirroring %d: expected %#x to map to %#x, got %#x", test.mirroring, addr, expected+0x123, actual)
+			}
+			// $3000-$3EFF mirrors $2000-$2EFF
+			if actual := test.mirroring.NametableAddr(addr + 0x1000); actual != expected+0x123 {
+				t.Errorf("mirroring %d: expected %#x to map to %#x, got %#x", test.mirroring, addr+0x1000, expected+0x123, actual)
+			}
+		}
+	}
+}
+
+func TestMMC1Mirroring(t *testing.T) {
+	cart, err := NewCartridge(&RomInfo{Mapper: 1, Mirroring: MirrorVertical}, make([]byte, 0x8000), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	cart.PPUWrite(0x2400, 1, vram)
+	if cart.PPURead(0x2C00, vram) != 1 {
+		t.Error("expected header mirroring to apply before the control register is written")
+	}
+
+	for _, test := range []struct {
+		control byte
+		addr    uint16
+	}{
+		// Each mirrors $2000 for the given address
+		{0, 0x2C00},
+		{2, 0x2800},
+		{3, 0x2400},
+	} {
+		// Shift the control register in serially, low bit first
+		for i := uint(0); i < 5; i++ {
+			cart.CPUWrite(0x8000, (test.control>>i)&1)
+		}
+		for i := range vram {
+			vram[i] = 0
+		}
+		cart.PPUWrite(0x2000, 0x42, vram)
+		if val := cart.PPURead(test.addr, vram); val != 0x42 {
+			t.Errorf("control %#x: expected %#x to mirror $2000, got %#x", test.control, test.addr, val)
+		}
+	}
+}
@@ -5,24 +5,29 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func newMMC1(prg, chr []byte) (*mmc1, error) {
+func newMMC1(info *RomInfo, prg, chr []byte) (*mmc1, error) {
 	return &mmc1{
 		prg:         prg,
 		chr:         chr,
-		ram:         make([]byte, 0x2000),
+		ram:         newPRGRAM(info),
 		shiftReg:    shiftRegReset,
 		prgBankMode: 3,
+		mirroring:   info.Mirroring,
 	}, nil
 }
 
 const shiftRegReset = byte(0x20)
 
+// mmc1Mirroring maps the control register's mirroring bits.
+var mmc1Mirroring = [4]Mirroring{MirrorSingleScreenA, MirrorSingleScreenB, MirrorVertical, MirrorHorizontal}
+
 type mmc1 struct {
 	prg,
 	chr,
 	ram []byte
 	shiftReg byte
 
+	mirroring Mirroring
 	prgBankMode,
 	chrBankMode byte
 
@@ -110,7 +115,7 @@ func (m *mmc1) PPURead(addr uint16, vram []byte) byte {
 
 	}
 	if addr >= 0x2000 && addr <= 0x3EFF {
-		return vram[addr&0xFFF]
+		return vram[m.mirroring.NametableAddr(addr)]
 
 	}
 	panic(fmt.Sprintf("unhandled NROM PPU memory read from address %#x", addr))
@@ -121,7 +126,7 @@ func (m *mmc1) PPUWrite(addr uint16, val byte, vram []byte) {
 		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
 		m.chr[addr] = val
 	} else if addr >= 0x2000 && addr <= 0x3EFF {
-		vram[addr&0xFFF] = val
+		vram[m.mirroring.NametableAddr(addr)] = val
 	} else {
 		panic(fmt.Sprintf("unhandled NROM PPU memory write to address %#x", addr))
 	}
@@ -131,7 +136,7 @@ func (m *mmc1) writeReg(target, val byte) {
 	switch target {
 	case 0:
 		// Control
-		// TODO: Handle mirroring
+		m.mirroring = mmc1Mirroring[val&3]
 		m.prgBankMode = val >> 2 & 3
 		m.chrBankMode = val >> 4 & 1
 
@@ -0,0 +1,136 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/MMC2
+// http://wiki.nesdev.com/w/index.php/MMC4
+
+func newMMC2(info *RomInfo, prg, chr []byte, mmc4 bool) (*mmc2, error) {
+	if len(prg) < 0x8000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &mmc2{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mmc4:      mmc4,
+		mirroring: info.Mirroring,
+		latches:   [2]byte{0xFE, 0xFE},
+	}, nil
+}
+
+type mmc2 struct {
+	prg,
+	chr,
+	ram []byte
+	// mmc4 is set for mapper 10, which switches 16KB of PRG ROM rather than 8KB
+	mmc4      bool
+	mirroring Mirroring
+
+	prgBank byte
+	// chrBanks holds the 4KB banks for each pattern table, selected by the latch being $FD or $FE
+	chrBanks [2][2]byte
+	latches  [2]byte
+}
+
+func (m *mmc2) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return m.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		if m.mmc4 {
+			if addr < 0xC000 {
+				return m.prg[(int(m.prgBank)*0x4000+int(addr&0x3FFF))%len(m.prg)]
+			}
+			// Last 16KB is fixed
+			return m.prg[len(m.prg)-0x4000+int(addr&0x3FFF)]
+		}
+		if addr < 0xA000 {
+			return m.prg[(int(m.prgBank)*0x2000+int(addr&0x1FFF))%len(m.prg)]
+		}
+		// Last three 8KB banks are fixed
+		return m.prg[len(m.prg)-0x8000+int(addr&0x7FFF)]
+
+	}
+	logrus.Debugf("Read from unmapped MMC2 address %#X", addr)
+	return 0
+}
+
+func (m *mmc2) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		m.ram[addr-0x6000] = val
+		return
+	}
+
+	switch addr & 0xF000 {
+	case 0xA000:
+		m.prgBank = val & 0xF
+	case 0xB000:
+		m.chrBanks[0][0] = val & 0x1F
+	case 0xC000:
+		m.chrBanks[0][1] = val & 0x1F
+	case 0xD000:
+		m.chrBanks[1][0] = val & 0x1F
+	case 0xE000:
+		m.chrBanks[1][1] = val & 0x1F
+	case 0xF000:
+		if val&1 == 1 {
+			m.mirroring = MirrorHorizontal
+		} else {
+			m.mirroring = MirrorVertical
+		}
+	default:
+		logrus.Debugf("Write to unmapped MMC2 address %#X", addr)
+	}
+}
+
+func (m *mmc2) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		val := m.chr[m.chrAddr(addr)]
+		// Latches switch after the fetch that triggers them
+		m.updateLatch(addr)
+		return val
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[m.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled MMC2 PPU memory read from address %#x", addr))
+}
+
+func (m *mmc2) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		m.chr[m.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[m.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled MMC2 PPU memory write to address %#x", addr))
+	}
+}
+
+func (m *mmc2) chrAddr(addr uint16) int {
+	table := addr >> 12
+	bank := m.chrBanks[table][m.latches[table]-0xFD]
+	return (int(bank)*0x1000 + int(addr&0xFFF)) % len(m.chr)
+}
+
+// updateLatch watches for fetches of tiles $FD and $FE, which flip the banks used for the pattern
+// table. The MMC2 only watches a single address for the first pattern table, while the MMC4 and
+// the second pattern table respond to any row of the tile.
+func (m *mmc2) updateLatch(addr uint16) {
+	table := addr >> 12
+	tile := addr & 0xFF8
+	if table == 0 && !m.mmc4 {
+		tile = addr & 0xFFF
+	}
+	switch tile {
+	case 0xFD8:
+		m.latches[table] = 0xFD
+	case 0xFE8:
+		m.latches[table] = 0xFE
+	}
+}
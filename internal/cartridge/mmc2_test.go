@@ -0,0 +1,80 @@
+package cartridge
+
+import "testing"
+
+func TestMMC2Latches(t *testing.T) {
+	for _, mmc4 := range []bool{false, true} {
+		m, err := newMMC2(&RomInfo{}, testPRG(16), testCHR(0x20000, 0x1000), mmc4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vram := make([]byte, 0x1000)
+		m.CPUWrite(0xB000, 1)
+		m.CPUWrite(0xC000, 2)
+		m.CPUWrite(0xD000, 3)
+		m.CPUWrite(0xE000, 4)
+
+		// Latches start as $FE
+		if bank := m.PPURead(0x0000, vram); bank != 2 {
+			t.Errorf("MMC4 %t: expected $FE bank 2 at $0000, got %d", mmc4, bank)
+		}
+		if bank := m.PPURead(0x1000, vram); bank != 4 {
+			t.Errorf("MMC4 %t: expected $FE bank 4 at $1000, got %d", mmc4, bank)
+		}
+
+		// The triggering fetch still uses the old bank
+		if bank := m.PPURead(0x1FD9, vram); bank != 4 {
+			t.Errorf("MMC4 %t: expected trigger fetch from bank 4, got %d", mmc4, bank)
+		}
+		if bank := m.PPURead(0x1000, vram); bank != 3 {
+			t.Errorf("MMC4 %t: expected $FD bank 3 at $1000, got %d", mmc4, bank)
+		}
+		if bank := m.PPURead(0x0000, vram); bank != 2 {
+			t.Errorf("MMC4 %t: expected pattern tables to latch separately, got %d", mmc4, bank)
+		}
+
+		// Only the MMC4 responds to any row of the tile in the first pattern table
+		m.PPURead(0x0FDB, vram)
+		expected := byte(2)
+		if mmc4 {
+			expected = 1
+		}
+		if bank := m.PPURead(0x0000, vram); bank != expected {
+			t.Errorf("MMC4 %t: expected bank %d at $0000, got %d", mmc4, expected, bank)
+		}
+		m.PPURead(0x0FD8, vram)
+		if bank := m.PPURead(0x0000, vram); bank != 1 {
+			t.Errorf("MMC4 %t: expected $FD bank 1 at $0000, got %d", mmc4, bank)
+		}
+		m.PPURead(0x0FE8, vram)
+		if bank := m.PPURead(0x0000, vram); bank != 2 {
+			t.Errorf("MMC4 %t: expected $FE bank 2 at $0000, got %d", mmc4, bank)
+		}
+	}
+}
+
+func TestMMC2PRGBanking(t *testing.T) {
+	m, err := newMMC2(&RomInfo{}, testPRG(16), make([]byte, 0x2000), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CPUWrite(0xA000, 5)
+	for i, bank := range []byte{5, 13, 14, 15} {
+		addr := 0x8000 + uint16(i)*0x2000
+		if actual := m.CPURead(addr); actual != bank {
+			t.Errorf("MMC2: expected bank %d at %#x, got %d", bank, addr, actual)
+		}
+	}
+
+	m, err = newMMC2(&RomInfo{}, testPRG(16), make([]byte, 0x2000), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CPUWrite(0xA000, 5)
+	for i, bank := range []byte{10, 11, 14, 15} {
+		addr := 0x8000 + uint16(i)*0x2000
+		if actual := m.CPURead(addr); actual != bank {
+			t.Errorf("MMC4: expected bank %d at %#x, got %d", bank, addr, actual)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/MMC3
+
+func newMMC3(info *RomInfo, prg, chr []byte) (*mmc3, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &mmc3{
+		prg:        prg,
+		chr:        chr,
+		ram:        newPRGRAM(info),
+		mirroring:  info.Mirroring,
+		fourScreen: info.Mirroring == MirrorFourScreen,
+		ramEnabled: true,
+	}, nil
+}
+
+type mmc3 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+	// fourScreen is set for boards with their own nametable RAM, which ignore the mirroring
+	// register
+	fourScreen bool
+
+	// Bank select
+	bankSelect byte
+	prgMode,
+	chrInversion bool
+	banks [8]byte
+
+	ramEnabled,
+	ramProtected bool
+
+	irq a12IRQ
+}
+
+func (m *mmc3) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if !m.ramEnabled {
+			return 0
+		}
+		return m.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return m.prg[m.prgAddr(addr)]
+
+	}
+	logrus.Debugf("Read from unmapped MMC3 address %#X", addr)
+	return 0
+}
+
+func (m *mmc3) prgAddr(addr uint16) int {
+	lastBank := len(m.prg)/0x2000 - 1
+	var bank int
+	switch (addr - 0x8000) / 0x2000 {
+	case 0:
+		if m.prgMode {
+			bank = lastBank - 1
+		} else {
+			bank = int(m.banks[6])
+		}
+	case 1:
+		bank = int(m.banks[7])
+	case 2:
+		if m.prgMode {
+			bank = int(m.banks[6])
+		} else {
+			bank = lastBank - 1
+		}
+	case 3:
+		bank = lastBank
+	}
+	return (bank*0x2000 + int(addr&0x1FFF)) % len(m.prg)
+}
+
+func (m *mmc3) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if m.ramEnabled && !m.ramProtected {
+			m.ram[addr-0x6000] = val
+		}
+		return
+	}
+	if addr < 0x8000 {
+		logrus.Debugf("Write to unmapped MMC3 address %#X", addr)
+		return
+	}
+
+	// Registers are selected by the address range and A0
+	switch addr & 0xE001 {
+	case 0x8000:
+		m.bankSelect = val & 7
+		m.prgMode = val&0x40 != 0
+		m.chrInversion = val&0x80 != 0
+
+	case 0x8001:
+		m.banks[m.bankSelect] = val
+
+	case 0xA000:
+		if !m.fourScreen {
+			if val&1 == 1 {
+				m.mirroring = MirrorHorizontal
+			} else {
+				m.mirroring = MirrorVertical
+			}
+		}
+
+	case 0xA001:
+		m.ramEnabled = val&0x80 != 0
+		m.ramProtected = val&0x40 != 0
+
+	case 0xC000:
+		m.irq.latch = val
+
+	case 0xC001:
+		m.irq.counter = 0
+		m.irq.reload = true
+
+	case 0xE000:
+		m.irq.enabled = false
+		m.irq.pending = false
+
+	case 0xE001:
+		m.irq.enabled = true
+	}
+}
+
+func (m *mmc3) PPURead(addr uint16, vram []byte) byte {
+	m.irq.observe(addr)
+	if addr < 0x2000 {
+		return m.chr[m.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[m.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled MMC3 PPU memory read from address %#x", addr))
+}
+
+func (m *mmc3) PPUWrite(addr uint16, val byte, vram []byte) {
+	m.irq.observe(addr)
+	if addr < 0x2000 {
+		// Some boards use CHR RAM
+		m.chr[m.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[m.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled MMC3 PPU memory write to address %#x", addr))
+	}
+}
+
+func (m *mmc3) chrAddr(addr uint16) int {
+	if m.chrInversion {
+		addr ^= 0x1000
+	}
+	var bank int
+	if addr < 0x1000 {
+		// Two 2KB banks, which ignore the low bit of the bank number
+		bank = int(m.banks[addr>>11]&0xFE) + int(addr>>10)&1
+	} else {
+		bank = int(m.banks[2+(addr-0x1000)>>10])
+	}
+	return (bank*0x400 + int(addr&0x3FF)) % len(m.chr)
+}
+
+func (m *mmc3) Step() {
+	m.irq.step()
+}
+
+func (m *mmc3) IRQ() bool {
+	return m.irq.pending
+}
@@ -0,0 +1,141 @@
+package cartridge
+
+import "testing"
+
+func TestMMC3Banking(t *testing.T) {
+	chr := make([]byte, 0x10000)
+	for i := 0; i < len(chr)/0x400; i++ {
+		chr[i*0x400] = byte(i)
+	}
+	m, err := newMMC3(&RomInfo{Mapper: 4}, testPRG(16), chr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, bank := range []byte{4, 9, 20, 21, 22, 23, 3, 5} {
+		m.CPUWrite(0x8000, byte(i))
+		m.CPUWrite(0x8001, bank)
+	}
+
+	for _, prgMode := range []byte{0, 0x40} {
+		m.CPUWrite(0x8000, prgMode)
+		expected := [4]byte{3, 5, 14, 15}
+		if prgMode != 0 {
+			expected = [4]byte{14, 5, 3, 15}
+		}
+		for i, bank := range expected {
+			addr := 0x8000 + uint16(i)*0x2000
+			if actual := m.CPURead(addr); actual != bank {
+				t.Errorf("PRG mode %#x: expected bank %d at %#x, got %d", prgMode, bank, addr, actual)
+			}
+		}
+	}
+
+	vram := make([]byte, 0x1000)
+	for _, inversion := range []byte{0, 0x80} {
+		m.CPUWrite(0x8000, inversion)
+		// 2KB banks ignore the low bit, so bank 9 maps 8 and 9
+		expected := [8]byte{4, 5, 8, 9, 20, 21, 22, 23}
+		for i, bank := range expected {
+			addr := uint16(i) * 0x400
+			if inversion != 0 {
+				addr ^= 0x1000
+			}
+			if actual := m.PPURead(addr, vram); actual != bank {
+				t.Errorf("CHR inversion %#x: expected bank %d at %#x, got %d", inversion, bank, addr, actual)
+			}
+		}
+	}
+}
+
+func TestMMC3RAMAndMirroring(t *testing.T) {
+	m, err := newMMC3(&RomInfo{Mapper: 4}, testPRG(4), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CPUWrite(0x6000, 0x42)
+	m.CPUWrite(0xA001, 0xC0)
+	m.CPUWrite(0x6000, 0x24)
+	if val := m.CPURead(0x6000); val != 0x42 {
+		t.Errorf("expected write protected RAM to hold %#x, got %#x", 0x42, val)
+	}
+	m.CPUWrite(0xA001, 0)
+	if val := m.CPURead(0x6000); val != 0 {
+		t.Errorf("expected disabled RAM to read 0, got %#x", val)
+	}
+
+	vram := make([]byte, 0x1000)
+	m.CPUWrite(0xA000, 1)
+	m.PPUWrite(0x2000, 0x42, vram)
+	if val := m.PPURead(0x2400, vram); val != 0x42 {
+		t.Errorf("expected horizontal mirroring, got %#x", val)
+	}
+	m.CPUWrite(0xA000, 0)
+	if val := m.PPURead(0x2800, vram); val != 0x42 {
+		t.Errorf("expected vertical mirroring, got %#x", val)
+	}
+}
+
+// testScanline simulates the PPU fetches for a scanline, with background tiles at $0000 and
+// sprites at $1000, for mappers with an A12 scanline counter.
+func testScanline(m interface {
+	Cartridge
+	Clocked
+}) {
+	vram := make([]byte, 0x1000)
+	for i := 0; i < 32; i++ {
+		m.PPURead(0x2000, vram)
+		m.PPURead(0x0010, vram)
+		if i%3 == 0 {
+			m.Step()
+		}
+	}
+	for i := 0; i < 8; i++ {
+		m.PPURead(0x2000, vram)
+		m.PPURead(0x1FF0, vram)
+		m.Step()
+	}
+	for i := 0; i < 5; i++ {
+		m.Step()
+	}
+}
+
+func TestMMC3IRQ(t *testing.T) {
+	m, err := newMMC3(&RomInfo{Mapper: 4}, testPRG(4), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CPUWrite(0xC000, 3)
+	m.CPUWrite(0xC001, 0)
+	m.CPUWrite(0xE001, 0)
+
+	// First clock reloads the counter, then it counts down to 0
+	for line := 0; line < 3; line++ {
+		testScanline(m)
+		if m.IRQ() {
+			t.Fatalf("unexpected IRQ after %d scanlines", line+1)
+		}
+	}
+	testScanline(m)
+	if !m.IRQ() {
+		t.Fatal("expected IRQ after 4 scanlines")
+	}
+
+	m.CPUWrite(0xE000, 0)
+	if m.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+	// Counter reloads and continues while disabled
+	for line := 0; line < 4; line++ {
+		testScanline(m)
+	}
+	if m.IRQ() {
+		t.Error("expected no IRQ while disabled")
+	}
+	m.CPUWrite(0xE001, 0)
+	for line := 0; line < 4; line++ {
+		testScanline(m)
+	}
+	if !m.IRQ() {
+		t.Error("expected IRQ after re-enabling")
+	}
+}
@@ -0,0 +1,495 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/MMC5
+
+func newMMC5(info *RomInfo, prg, chr []byte) (*mmc5, error) {
+	if len(prg) < 0x2000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	ramInfo := *info
+	if !info.NES2 {
+		// iNES headers rarely give the RAM size, so provide the most the board supports
+		ramInfo.PRGRAMSize = mmc5MaxRAMSize
+	}
+
+	m := &mmc5{
+		prg:     prg,
+		chr:     chr,
+		ram:     newPRGRAM(&ramInfo),
+		prgMode: 3,
+		prgRegs: [4]byte{0, 0, 0, 0xFF},
+	}
+	switch info.Mirroring {
+	case MirrorVertical:
+		m.ntMapping = 0x44
+	case MirrorHorizontal:
+		m.ntMapping = 0x50
+	}
+	return m, nil
+}
+
+const (
+	mmc5MaxRAMSize = 0x10000
+	// mmc5IdleCycles is the number of CPU cycles without a PPU read, after which the MMC5 decides
+	// that the PPU has stopped rendering
+	mmc5IdleCycles = 3
+)
+
+// Nametable sources selected by $5105
+const (
+	mmc5NTPage0 = iota
+	mmc5NTPage1
+	mmc5NTExRAM
+	mmc5NTFill
+)
+
+// Background fetches for each tile, in the order the PPU makes them
+const (
+	mmc5FetchNametable = iota
+	mmc5FetchAttribute
+	mmc5FetchPatternLow
+	mmc5FetchPatternHigh
+)
+
+type mmc5 struct {
+	prg,
+	chr,
+	ram []byte
+	exRAM [0x400]byte
+
+	// PRG banking
+	prgMode byte
+	prgRegs [4]byte
+	ramBank byte
+	ramProtect1,
+	ramProtect2 byte
+
+	// CHR banking - sprite banks are used for sprites in 8x16 mode, and background banks for the
+	// background, otherwise the last written set is used for everything
+	chrMode      byte
+	spriteBanks  [8]uint16
+	bgBanks      [4]uint16
+	chrUpper     byte
+	lastBGBanks  bool
+	tallSprites  bool
+	exRAMMode    byte
+	ntMapping    byte
+	fillTile     byte
+	fillPalette  byte
+	multiplicand byte
+	multiplier   byte
+
+	// Vertical split
+	splitEnabled,
+	splitRight bool
+	splitTile,
+	splitScroll,
+	splitBank byte
+
+	// Scanline detection and IRQ
+	idleCycles int
+	lastNTAddr uint16
+	ntMatches  int
+	inFrame    bool
+	scanline,
+	irqCompare byte
+	irqEnabled,
+	irqPending bool
+
+	// Tracking of the PPU's fetches
+	fetching,
+	spriteFetch bool
+	bgFetches int
+	// fetchLine is the scanline that background tiles are being fetched for
+	fetchLine int
+	inSplit   bool
+	extAttr   byte
+
+	audio mmc5Audio
+}
+
+func (m *mmc5) CPURead(addr uint16) byte {
+	switch {
+	case addr >= 0x8000:
+		bank, rom := m.prgBank(addr)
+		if !rom {
+			return m.ram[m.ramAddr(bank, addr)]
+		}
+		val := m.prg[(bank*0x2000+int(addr&0x1FFF))%len(m.prg)]
+		m.audio.observeRead(addr, val)
+		return val
+
+	case addr >= 0x6000:
+		return m.ram[m.ramAddr(int(m.ramBank), addr)]
+
+	case addr >= 0x5C00:
+		if m.exRAMMode < 2 {
+			// Not readable while used by the PPU
+			return 0
+		}
+		return m.exRAM[addr-0x5C00]
+
+	case addr >= 0x5000 && addr <= 0x5015:
+		if val, ok := m.audio.read(addr); ok {
+			return val
+		}
+
+	case addr == 0x5204:
+		var val byte
+		if m.irqPending {
+			val |= 0x80
+		}
+		if m.inFrame {
+			val |= 0x40
+		}
+		m.irqPending = false
+		return val
+
+	case addr == 0x5205:
+		return byte(uint16(m.multiplicand) * uint16(m.multiplier))
+
+	case addr == 0x5206:
+		return byte(uint16(m.multiplicand) * uint16(m.multiplier) >> 8)
+	}
+
+	logrus.Debugf("Read from unmapped MMC5 address %#X", addr)
+	return 0
+}
+
+func (m *mmc5) CPUWrite(addr uint16, val byte) {
+	switch {
+	case addr >= 0xE000:
+		// Always ROM
+
+	case addr >= 0x8000:
+		if bank, rom := m.prgBank(addr); !rom && m.ramWritable() {
+			m.ram[m.ramAddr(bank, addr)] = val
+		}
+
+	case addr >= 0x6000:
+		if m.ramWritable() {
+			m.ram[m.ramAddr(int(m.ramBank), addr)] = val
+		}
+
+	case addr >= 0x5C00:
+		switch {
+		case m.exRAMMode == 3:
+			// Read-only
+		case m.exRAMMode < 2 && !m.inFrame:
+			// Modes 0 and 1 are only writable while rendering, and store 0 otherwise
+			m.exRAM[addr-0x5C00] = 0
+		default:
+			m.exRAM[addr-0x5C00] = val
+		}
+
+	case addr >= 0x5000 && addr <= 0x5015:
+		m.audio.write(addr, val)
+
+	case addr >= 0x5114 && addr <= 0x5117:
+		m.prgRegs[addr-0x5114] = val
+
+	case addr >= 0x5120 && addr <= 0x5127:
+		m.spriteBanks[addr-0x5120] = uint16(m.chrUpper)<<8 | uint16(val)
+		m.lastBGBanks = false
+
+	case addr >= 0x5128 && addr <= 0x512B:
+		m.bgBanks[addr-0x5128] = uint16(m.chrUpper)<<8 | uint16(val)
+		m.lastBGBanks = true
+
+	default:
+		m.writeReg(addr, val)
+	}
+}
+
+func (m *mmc5) writeReg(addr uint16, val byte) {
+	switch addr {
+	case 0x5100:
+		m.prgMode = val & 3
+	case 0x5101:
+		m.chrMode = val & 3
+	case 0x5102:
+		m.ramProtect1 = val & 3
+	case 0x5103:
+		m.ramProtect2 = val & 3
+	case 0x5104:
+		m.exRAMMode = val & 3
+	case 0x5105:
+		m.ntMapping = val
+	case 0x5106:
+		m.fillTile = val
+	case 0x5107:
+		m.fillPalette = val & 3
+	case 0x5113:
+		m.ramBank = val & 7
+	case 0x5130:
+		m.chrUpper = val & 3
+	case 0x5200:
+		m.splitEnabled = val>>7 == 1
+		m.splitRight = val&0x40 != 0
+		m.splitTile = val & 0x1F
+	case 0x5201:
+		m.splitScroll = val
+	case 0x5202:
+		m.splitBank = val
+	case 0x5203:
+		m.irqCompare = val
+	case 0x5204:
+		m.irqEnabled = val>>7 == 1
+	case 0x5205:
+		m.multiplicand = val
+	case 0x5206:
+		m.multiplier = val
+	default:
+		logrus.Debugf("Write to unmapped MMC5 address %#X", addr)
+	}
+}
+
+// prgBank returns the 8KB bank mapped at a CPU address from $8000, and whether it's in ROM or RAM.
+func (m *mmc5) prgBank(addr uint16) (int, bool) {
+	// Offset of the 8KB bank within a larger bank
+	offset := int(addr>>13) & 3
+	var index, size int
+	switch {
+	case m.prgMode == 0:
+		index, size = 3, 4
+	case m.prgMode == 1 && addr >= 0xC000:
+		index, size = 3, 2
+	case (m.prgMode == 1 || m.prgMode == 2) && addr < 0xC000:
+		index, size = 1, 2
+	default:
+		index, size = offset, 1
+	}
+	reg := m.prgRegs[index]
+	// $5117 always selects ROM, ignoring bit 7
+	rom := reg&0x80 != 0 || index == 3
+	bank := int(reg&0x7F) &^ (size - 1)
+	return bank + offset%size, rom
+}
+
+func (m *mmc5) ramAddr(bank int, addr uint16) int {
+	return (bank*0x2000 + int(addr&0x1FFF)) % len(m.ram)
+}
+
+func (m *mmc5) ramWritable() bool {
+	return m.ramProtect1 == 2 && m.ramProtect2 == 1
+}
+
+func (m *mmc5) PPURead(addr uint16, vram []byte) byte {
+	m.idleCycles = 0
+	m.detectScanline(addr)
+
+	column, fetch, bg := m.bgFetch()
+	if bg && fetch == mmc5FetchNametable {
+		m.inSplit = m.splitEnabled && m.exRAMMode < 2 &&
+			(m.splitRight && column >= int(m.splitTile) || !m.splitRight && column < int(m.splitTile))
+		if m.exRAMMode == 1 {
+			m.extAttr = m.exRAM[addr&0x3FF]
+		}
+	}
+
+	if addr < 0x2000 {
+		switch {
+		case bg && m.inSplit:
+			// Split uses its own 4KB bank, and the split scroll for the row within the tile
+			splitY := m.splitY()
+			return m.chr[(int(m.splitBank)*0x1000+int(addr&0xFF8)|splitY&7)%len(m.chr)]
+		case bg && m.exRAMMode == 1:
+			// Extended attributes select a 4KB bank for each tile
+			bank := int(m.chrUpper)<<6 | int(m.extAttr&0x3F)
+			return m.chr[(bank*0x1000+int(addr&0xFFF))%len(m.chr)]
+		}
+		return m.chr[m.chrAddr(addr)]
+	}
+
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		switch {
+		case bg && m.inSplit && fetch == mmc5FetchNametable:
+			return m.exRAM[m.splitY()/8*32+column&31]
+		case bg && m.inSplit && fetch == mmc5FetchAttribute:
+			splitY := m.splitY()
+			attr := m.exRAM[0x3C0+splitY/32*8+(column&31)/4]
+			shift := uint(splitY&0x10)>>2 | uint(column&2)
+			return (attr >> shift & 3) * 0x55
+		case bg && m.exRAMMode == 1 && fetch == mmc5FetchAttribute:
+			return (m.extAttr >> 6) * 0x55
+		}
+		return m.nametableRead(addr, vram)
+	}
+	panic(fmt.Sprintf("unhandled MMC5 PPU memory read from address %#x", addr))
+}
+
+func (m *mmc5) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Boards generally use CHR ROM, but some have RAM
+		m.chr[m.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		switch m.ntSource(addr) {
+		case mmc5NTPage0, mmc5NTPage1:
+			vram[m.ntSource(addr)<<10|addr&0x3FF] = val
+		case mmc5NTExRAM:
+			if m.exRAMMode < 2 {
+				m.exRAM[addr&0x3FF] = val
+			}
+		}
+	} else {
+		panic(fmt.Sprintf("unhandled MMC5 PPU memory write to address %#x", addr))
+	}
+}
+
+// ntSource returns where the nametable at a PPU address is mapped from.
+func (m *mmc5) ntSource(addr uint16) uint16 {
+	table := (addr >> 10) & 3
+	return uint16(m.ntMapping>>(table*2)) & 3
+}
+
+func (m *mmc5) nametableRead(addr uint16, vram []byte) byte {
+	switch m.ntSource(addr) {
+	case mmc5NTPage0, mmc5NTPage1:
+		return vram[m.ntSource(addr)<<10|addr&0x3FF]
+	case mmc5NTExRAM:
+		if m.exRAMMode < 2 {
+			return m.exRAM[addr&0x3FF]
+		}
+		return 0
+	}
+	// Fill mode
+	if addr&0x3FF < 0x3C0 {
+		return m.fillTile
+	}
+	return m.fillPalette * 0x55
+}
+
+// chrAddr maps a pattern address using the sprite or background banks, depending on what the PPU
+// is fetching.
+func (m *mmc5) chrAddr(addr uint16) int {
+	useBG := m.lastBGBanks
+	if m.tallSprites && m.fetching {
+		useBG = !m.spriteFetch
+	}
+
+	var bank, size int
+	switch m.chrMode {
+	case 0:
+		size = 0x2000
+		bank = int(m.spriteBanks[7])
+		if useBG {
+			bank = int(m.bgBanks[3])
+		}
+	case 1:
+		size = 0x1000
+		bank = int(m.spriteBanks[addr>>12*4+3])
+		if useBG {
+			bank = int(m.bgBanks[3])
+		}
+	case 2:
+		size = 0x800
+		bank = int(m.spriteBanks[addr>>11*2+1])
+		if useBG {
+			bank = int(m.bgBanks[(addr>>11)&1*2+1])
+		}
+	case 3:
+		size = 0x400
+		bank = int(m.spriteBanks[addr>>10])
+		if useBG {
+			bank = int(m.bgBanks[(addr>>10)&3])
+		}
+	}
+	return (bank*size + int(addr)%size) % len(m.chr)
+}
+
+// detectScanline watches for the three consecutive reads of the same nametable address that the
+// PPU makes at the end of each rendered scanline.
+func (m *mmc5) detectScanline(addr uint16) {
+	if addr >= 0x2000 && addr <= 0x2FFF && addr == m.lastNTAddr {
+		m.ntMatches++
+		if m.ntMatches == 2 {
+			m.clockScanline()
+		}
+	} else {
+		m.ntMatches = 0
+	}
+	m.lastNTAddr = addr
+}
+
+func (m *mmc5) clockScanline() {
+	if !m.inFrame {
+		m.inFrame = true
+		m.scanline = 0
+		m.irqPending = false
+		return
+	}
+	m.scanline++
+	if m.scanline == m.irqCompare {
+		m.irqPending = true
+	}
+}
+
+// bgFetch tracks the PPU's background fetches, and returns the tile column and the kind of fetch
+// being made. ok is false for sprite fetches, unused fetches, or when the PPU isn't rendering.
+func (m *mmc5) bgFetch() (column int, fetch int, ok bool) {
+	if !m.fetching || m.spriteFetch {
+		return 0, 0, false
+	}
+	i := m.bgFetches
+	m.bgFetches++
+	switch {
+	case i < 8:
+		// First two tiles are fetched at the end of the previous scanline
+		return i / 4, i % 4, true
+	case i < 10:
+		// Unused nametable fetches
+		return 0, 0, false
+	}
+	i -= 10
+	return i/4 + 2, i % 4, true
+}
+
+// splitY returns the row of the split region being fetched.
+func (m *mmc5) splitY() int {
+	return (int(m.splitScroll) + m.fetchLine) % 240
+}
+
+func (m *mmc5) PPURegisterWrite(reg, val byte) {
+	if reg == 0 {
+		m.tallSprites = val&0x20 != 0
+	}
+}
+
+func (m *mmc5) SpriteFetch(active bool) {
+	m.fetching = true
+	m.spriteFetch = active
+	if !active {
+		// Background fetches for the next scanline are starting
+		m.bgFetches = 0
+		m.fetchLine = 0
+		if m.inFrame {
+			m.fetchLine = int(m.scanline) + 1
+		}
+	}
+}
+
+func (m *mmc5) Step() {
+	m.idleCycles++
+	if m.idleCycles >= mmc5IdleCycles {
+		// PPU has stopped rendering
+		m.inFrame = false
+		m.fetching = false
+		m.ntMatches = 0
+		m.lastNTAddr = 0
+	}
+	m.audio.step()
+}
+
+func (m *mmc5) IRQ() bool {
+	return m.irqPending && m.irqEnabled || m.audio.irq()
+}
+
+func (m *mmc5) AudioOutput() float64 {
+	return m.audio.output()
+}
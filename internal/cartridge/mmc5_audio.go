@@ -0,0 +1,167 @@
+package cartridge
+
+import "github.com/tomnz/gophernes/internal/apu"
+
+// mmc5Audio implements the MMC5 expansion sound: two pulse channels matching the APU's (without
+// sweep units), and an 8-bit PCM channel.
+// http://wiki.nesdev.com/w/index.php/MMC5_audio
+type mmc5Audio struct {
+	pulse1,
+	pulse2 mmc5Pulse
+
+	pcm byte
+	// In read mode, the PCM level is taken from CPU reads of $8000-$BFFF
+	pcmReadMode,
+	pcmIRQEnabled,
+	pcmIRQ bool
+
+	cycles uint64
+}
+
+const (
+	// mmc5FramePeriod is the number of CPU cycles between clocks of the envelopes and length
+	// counters, which run at a fixed ~240Hz rather than from the APU frame counter
+	mmc5FramePeriod = 7457
+	// mmc5PCMLevel scales each step of the 8-bit PCM output relative to the APU. There's no
+	// measured level for the PCM channel, so its range is matched to the DMC's 7-bit range, making
+	// each step half a DMC step. The linear approximation of the APU mixer weights a DMC step at
+	// 0.00335 against 0.00752 for a pulse step.
+	// http://wiki.nesdev.com/w/index.php/APU_Mixer#Linear_Approximation
+	mmc5PCMLevel = apuPulseStep * 0.00335 / 0.00752 / 2
+)
+
+// write handles a write to a sound register, from $5000-$5015.
+func (m *mmc5Audio) write(addr uint16, val byte) {
+	switch addr {
+	case 0x5000, 0x5001, 0x5002, 0x5003:
+		m.pulse1.write(addr&3, val)
+	case 0x5004, 0x5005, 0x5006, 0x5007:
+		m.pulse2.write(addr&3, val)
+	case 0x5010:
+		m.pcmReadMode = val&1 == 1
+		m.pcmIRQEnabled = val>>7 == 1
+	case 0x5011:
+		// Writing 0 has no effect, as it is reserved to signal the end of a sample in read mode
+		if !m.pcmReadMode && val != 0 {
+			m.pcm = val
+		}
+	case 0x5015:
+		m.pulse1.setEnabled(val&1 == 1)
+		m.pulse2.setEnabled(val&2 != 0)
+	}
+}
+
+// read handles a read from a sound register, and returns false if the address isn't readable.
+func (m *mmc5Audio) read(addr uint16) (byte, bool) {
+	switch addr {
+	case 0x5010:
+		var val byte
+		if m.pcmIRQ && m.pcmIRQEnabled {
+			val |= 0x80
+		}
+		m.pcmIRQ = false
+		return val, true
+	case 0x5015:
+		var val byte
+		if m.pulse1.length.Active() {
+			val |= 1
+		}
+		if m.pulse2.length.Active() {
+			val |= 2
+		}
+		return val, true
+	}
+	return 0, false
+}
+
+// observeRead is called with CPU reads from PRG ROM, which supply PCM data in read mode.
+func (m *mmc5Audio) observeRead(addr uint16, val byte) {
+	if !m.pcmReadMode || addr < 0x8000 || addr >= 0xC000 {
+		return
+	}
+	if val == 0 {
+		m.pcmIRQ = true
+	} else {
+		m.pcm = val
+	}
+}
+
+// irq returns whether the PCM channel is asserting an IRQ.
+func (m *mmc5Audio) irq() bool {
+	return m.pcmIRQ && m.pcmIRQEnabled
+}
+
+// step advances the chip by a CPU cycle.
+func (m *mmc5Audio) step() {
+	if m.cycles%2 == 0 {
+		m.pulse1.clockTimer()
+		m.pulse2.clockTimer()
+	}
+	if m.cycles%mmc5FramePeriod == 0 {
+		m.pulse1.clockFrame()
+		m.pulse2.clockFrame()
+	}
+	m.cycles++
+}
+
+func (m *mmc5Audio) output() float64 {
+	pulse := m.pulse1.output() + m.pulse2.output()
+	return float64(pulse)*apuPulseStep + float64(m.pcm)*mmc5PCMLevel
+}
+
+// mmc5Pulse is an APU pulse channel without the sweep unit, which is built from the APU's
+// envelope and length counter.
+type mmc5Pulse struct {
+	duty      byte
+	timerLoad uint16
+
+	timer    uint16
+	dutyPos  byte
+	envelope apu.Envelope
+	length   apu.LengthCounter
+}
+
+func (p *mmc5Pulse) write(reg uint16, val byte) {
+	switch reg {
+	case 0:
+		p.duty = val >> 6
+		// The length counter halt flag also loops the envelope
+		p.length.Halt = val>>5&1 == 1
+		p.envelope.Write(val)
+	case 2:
+		p.timerLoad = p.timerLoad&0x700 | uint16(val)
+	case 3:
+		p.timerLoad = p.timerLoad&0xFF | uint16(val&7)<<8
+		p.length.Load(val >> 3)
+		p.dutyPos = 0
+		p.envelope.Restart()
+	}
+}
+
+func (p *mmc5Pulse) setEnabled(enabled bool) {
+	p.length.SetEnabled(enabled)
+}
+
+func (p *mmc5Pulse) clockTimer() {
+	if p.timer > 0 {
+		p.timer--
+		return
+	}
+	p.timer = p.timerLoad
+	p.dutyPos = (p.dutyPos + 1) & 7
+}
+
+// clockFrame clocks both the envelope and length counter.
+func (p *mmc5Pulse) clockFrame() {
+	p.envelope.Clock()
+	p.length.Clock()
+}
+
+// output returns the current channel level, from 0-15. Unlike the APU, periods below 8 aren't
+// muted, as there's no sweep unit.
+func (p *mmc5Pulse) output() int {
+	if !p.length.Active() || !apu.PulseDuty(p.duty, p.dutyPos) {
+		return 0
+	}
+	return int(p.envelope.Volume())
+}
@@ -0,0 +1,347 @@
+package cartridge
+
+import "testing"
+
+// testCHR builds a CHR image where every byte holds the number of the bank of the given size that
+// contains it.
+func testCHR(size, bankSize int) []byte {
+	chr := make([]byte, size)
+	for i := range chr {
+		chr[i] = byte(i / bankSize)
+	}
+	return chr
+}
+
+// testRenderLine simulates the PPU's reads from the sprite fetches of a scanline through to the
+// background fetches of the next one, with the background at $0000 and sprites at $1000. It
+// returns the sprite pattern bytes fetched, and the background bytes fetched for each column.
+func testRenderLine(m *mmc5, vram []byte, row int) (sprites [8]byte, bg [34][4]byte) {
+	reads := 0
+	read := func(addr uint16) byte {
+		val := m.PPURead(addr, vram)
+		reads++
+		if reads%2 == 0 {
+			m.Step()
+		}
+		return val
+	}
+	ntAddr := func(column int) uint16 {
+		return 0x2000 + uint16(row/8*32+column&31)
+	}
+	fetchTile := func(column int) {
+		bg[column][0] = read(ntAddr(column))
+		bg[column][1] = read(0x23C0 + uint16(row/32*8+(column&31)/4))
+		bg[column][2] = read(uint16(bg[column][0])*16 + uint16(row&7))
+		bg[column][3] = read(uint16(bg[column][0])*16 + uint16(row&7) + 8)
+	}
+
+	m.SpriteFetch(true)
+	for i := range sprites {
+		read(0x2000)
+		read(0x2000)
+		sprites[i] = read(0x1FF0)
+		read(0x1FF8)
+	}
+	m.SpriteFetch(false)
+	fetchTile(0)
+	fetchTile(1)
+	read(ntAddr(2))
+	read(ntAddr(2))
+	for column := 2; column < 34; column++ {
+		fetchTile(column)
+	}
+	return sprites, bg
+}
+
+// testIdle simulates CPU cycles without any PPU reads, such as during vertical blank.
+func testIdle(m *mmc5, cycles int) {
+	for i := 0; i < cycles; i++ {
+		m.Step()
+	}
+}
+
+func TestMMC5PRGBanking(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(32), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CPUWrite(0x5114, 0x81)
+	m.CPUWrite(0x5115, 0x87)
+	m.CPUWrite(0x5116, 0x89)
+	m.CPUWrite(0x5117, 0x8F)
+
+	for _, test := range []struct {
+		mode     byte
+		expected [4]byte
+	}{
+		{0, [4]byte{12, 13, 14, 15}},
+		{1, [4]byte{6, 7, 14, 15}},
+		{2, [4]byte{6, 7, 9, 15}},
+		{3, [4]byte{1, 7, 9, 15}},
+	} {
+		m.CPUWrite(0x5100, test.mode)
+		for i, bank := range test.expected {
+			addr := 0x8000 + uint16(i)*0x2000
+			if actual := m.CPURead(addr); actual != bank {
+				t.Errorf("PRG mode %d: expected bank %d at %#x, got %d", test.mode, bank, addr, actual)
+			}
+		}
+	}
+
+	// $5117 always selects ROM, even with bit 7 clear
+	m.CPUWrite(0x5117, 0x0F)
+	for _, test := range []struct {
+		mode     byte
+		expected [4]byte
+	}{
+		{0, [4]byte{12, 13, 14, 15}},
+		{1, [4]byte{6, 7, 14, 15}},
+	} {
+		m.CPUWrite(0x5100, test.mode)
+		for i, bank := range test.expected {
+			addr := 0x8000 + uint16(i)*0x2000
+			if actual := m.CPURead(addr); actual != bank {
+				t.Errorf("PRG mode %d with $5117 bit 7 clear: expected bank %d at %#x, got %d", test.mode, bank, addr, actual)
+			}
+		}
+	}
+	m.CPUWrite(0x5100, 3)
+
+	// RAM is only writable with both protect registers set, and can be mapped into $8000
+	m.CPUWrite(0x5113, 2)
+	m.CPUWrite(0x6000, 0x42)
+	if val := m.CPURead(0x6000); val != 0 {
+		t.Errorf("expected RAM to be write protected, got %#x", val)
+	}
+	m.CPUWrite(0x5102, 2)
+	m.CPUWrite(0x5103, 1)
+	m.CPUWrite(0x6000, 0x42)
+	m.CPUWrite(0x5114, 2)
+	if val := m.CPURead(0x8000); val != 0x42 {
+		t.Errorf("expected RAM bank 2 at $8000, got %#x", val)
+	}
+	m.CPUWrite(0x8001, 0x24)
+	m.CPUWrite(0x5113, 2)
+	if val := m.CPURead(0x6001); val != 0x24 {
+		t.Errorf("expected write through $8000 to reach RAM, got %#x", val)
+	}
+}
+
+func TestMMC5CHRBanking(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), testCHR(0x40000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	m.CPUWrite(0x5101, 3)
+	for i := uint16(0); i < 8; i++ {
+		m.CPUWrite(0x5120+i, byte(0x10+i))
+	}
+	for i := uint16(0); i < 4; i++ {
+		m.CPUWrite(0x5128+i, byte(0x20+i))
+	}
+
+	// 8x8 sprites use the last written set for everything
+	if bank := m.PPURead(0x1C00, vram); bank != 0x23 {
+		t.Errorf("expected last written background bank %#x, got %#x", 0x23, bank)
+	}
+	m.CPUWrite(0x5127, 0x17)
+	if bank := m.PPURead(0x1C00, vram); bank != 0x17 {
+		t.Errorf("expected last written sprite bank %#x, got %#x", 0x17, bank)
+	}
+
+	// 8x16 sprites use separate sets
+	m.PPURegisterWrite(0, 0x20)
+	sprites, bg := testRenderLine(m, vram, 0)
+	if sprites[0] != 0x17 {
+		t.Errorf("expected sprite fetch from bank %#x, got %#x", 0x17, sprites[0])
+	}
+	if bg[2][2] != 0x20 {
+		t.Errorf("expected background fetch from bank %#x, got %#x", 0x20, bg[2][2])
+	}
+
+	// Upper bits are applied when a bank is written
+	m.CPUWrite(0x5130, 1)
+	m.CPUWrite(0x5120, 0x02)
+	m.PPURegisterWrite(0, 0)
+	testIdle(m, 3)
+	if bank := m.PPURead(0x0000, vram); bank != 0x02 {
+		t.Errorf("expected bank %#x to wrap to %#x, got %#x", 0x102, 0x02, bank)
+	}
+}
+
+func TestMMC5Nametables(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	// CIRAM page 1, CIRAM page 0, ExRAM and fill mode
+	m.CPUWrite(0x5105, 0xE1)
+	m.CPUWrite(0x5106, 0x42)
+	m.CPUWrite(0x5107, 2)
+	m.PPUWrite(0x2000, 1, vram)
+	m.PPUWrite(0x2400, 2, vram)
+	m.PPUWrite(0x2800, 3, vram)
+
+	if vram[0x400] != 1 || vram[0] != 2 {
+		t.Errorf("expected CIRAM pages to be swapped, got %#x and %#x", vram[0x400], vram[0])
+	}
+	if val := m.PPURead(0x2800, vram); val != 3 {
+		t.Errorf("expected ExRAM nametable to hold 3, got %#x", val)
+	}
+	if val := m.PPURead(0x2C10, vram); val != 0x42 {
+		t.Errorf("expected fill tile, got %#x", val)
+	}
+	if val := m.PPURead(0x2FC0, vram); val != 0xAA {
+		t.Errorf("expected fill attribute, got %#x", val)
+	}
+
+	// ExRAM is only CPU readable in modes 2 and 3, and read-only in mode 3
+	if val := m.CPURead(0x5C00); val != 0 {
+		t.Errorf("expected ExRAM to be unreadable in mode 0, got %#x", val)
+	}
+	m.CPUWrite(0x5104, 2)
+	m.CPUWrite(0x5C01, 0x24)
+	m.CPUWrite(0x5104, 3)
+	m.CPUWrite(0x5C01, 0x42)
+	if val := m.CPURead(0x5C01); val != 0x24 {
+		t.Errorf("expected ExRAM to hold %#x, got %#x", 0x24, val)
+	}
+}
+
+func TestMMC5ExRAMWrites(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	m.CPUWrite(0x5104, 2)
+	m.CPUWrite(0x5C00, 0x55)
+	m.CPUWrite(0x5C01, 0x55)
+
+	// Modes 0 and 1 store 0 outside of rendering, and the written value while rendering
+	m.CPUWrite(0x5104, 1)
+	m.CPUWrite(0x5C00, 0x24)
+	testRenderLine(m, vram, 0)
+	testRenderLine(m, vram, 0)
+	m.CPUWrite(0x5C01, 0x42)
+
+	m.CPUWrite(0x5104, 2)
+	if val := m.CPURead(0x5C00); val != 0 {
+		t.Errorf("expected write outside rendering to store 0, got %#x", val)
+	}
+	if val := m.CPURead(0x5C01); val != 0x42 {
+		t.Errorf("expected write while rendering to store %#x, got %#x", 0x42, val)
+	}
+}
+
+func TestMMC5ExtendedAttributes(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), testCHR(0x100000, 0x1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	m.CPUWrite(0x5105, 0)
+	m.CPUWrite(0x5130, 1)
+	// Palette 3 and 4KB bank 5, for the third tile of the first row. ExRAM is written in mode 2,
+	// as mode 1 is only writable while rendering.
+	m.CPUWrite(0x5104, 2)
+	m.CPUWrite(0x5C02, 0xC5)
+	m.CPUWrite(0x5104, 1)
+
+	testRenderLine(m, vram, 0)
+	_, bg := testRenderLine(m, vram, 0)
+	if bg[2][1] != 0xFF {
+		t.Errorf("expected palette 3 from the attribute fetch, got %#x", bg[2][1])
+	}
+	if bg[2][2] != 0x45 || bg[2][3] != 0x45 {
+		t.Errorf("expected pattern fetches from 4KB bank %#x, got %#x and %#x", 0x45, bg[2][2], bg[2][3])
+	}
+	if bg[3][1] != 0 || bg[3][2] != 0x40 {
+		t.Errorf("expected palette 0 and bank %#x for the fourth tile, got %#x and %#x", 0x40, bg[3][1], bg[3][2])
+	}
+}
+
+func TestMMC5Split(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), testCHR(0x40000, 0x1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	for i := range vram {
+		vram[i] = 0x11
+	}
+	m.CPUWrite(0x5105, 0)
+	m.CPUWrite(0x5104, 2)
+	for i := uint16(0); i < 0x3C0; i++ {
+		m.CPUWrite(0x5C00+i, 0x22)
+	}
+	for i := uint16(0x3C0); i < 0x400; i++ {
+		m.CPUWrite(0x5C00+i, 0xFF)
+	}
+	m.CPUWrite(0x5104, 0)
+	// Split the left 4 tiles, using bank 7
+	m.CPUWrite(0x5200, 0x84)
+	m.CPUWrite(0x5202, 7)
+
+	testRenderLine(m, vram, 0)
+	_, bg := testRenderLine(m, vram, 0)
+	for column, fetched := range bg {
+		expected := [4]byte{0x11, 0x11, 0, 0}
+		if column < 4 {
+			expected = [4]byte{0x22, 0xFF, 7, 7}
+		}
+		if fetched != expected {
+			t.Errorf("column %d: expected fetches %#x, got %#x", column, expected, fetched)
+		}
+	}
+}
+
+func TestMMC5IRQ(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vram := make([]byte, 0x1000)
+	m.CPUWrite(0x5203, 10)
+	m.CPUWrite(0x5204, 0x80)
+
+	for frame := 0; frame < 2; frame++ {
+		// Pre-render line
+		testRenderLine(m, vram, 0)
+		if status := m.CPURead(0x5204); status != 0x40 {
+			t.Fatalf("expected in frame status, got %#x", status)
+		}
+		for line := 0; line < 240; line++ {
+			testRenderLine(m, vram, line)
+			if pending := m.IRQ(); pending != (line == 9) {
+				t.Fatalf("frame %d: expected IRQ %t after line %d", frame, line == 9, line)
+			}
+			if line == 9 {
+				if status := m.CPURead(0x5204); status != 0xC0 {
+					t.Errorf("expected pending and in frame status, got %#x", status)
+				}
+				if m.IRQ() {
+					t.Error("expected status read to acknowledge the IRQ")
+				}
+			}
+		}
+		testIdle(m, 20*114)
+		if status := m.CPURead(0x5204); status != 0 {
+			t.Errorf("expected out of frame status in vertical blank, got %#x", status)
+		}
+	}
+}
+
+func TestMMC5Multiplier(t *testing.T) {
+	m, err := newMMC5(&RomInfo{Mapper: 5}, testPRG(4), make([]byte, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.CPUWrite(0x5205, 0xC8)
+	m.CPUWrite(0x5206, 0x37)
+	if product := uint16(m.CPURead(0x5206))<<8 | uint16(m.CPURead(0x5205)); product != 0xC8*0x37 {
+		t.Errorf("expected product %#x, got %#x", 0xC8*0x37, product)
+	}
+}
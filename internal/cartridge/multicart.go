@@ -0,0 +1,277 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Multicart boards combine many small games in one cartridge, with a single register that selects
+// the game's PRG and CHR banks, and the arrangement of them.
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_015
+
+func newMulticart15(info *RomInfo, prg, chr []byte) (*multicart15, error) {
+	if len(prg) < 0x4000 || len(prg)%0x4000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 16KB, got %d B", len(prg))
+	}
+	return &multicart15{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+	}, nil
+}
+
+type multicart15 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+
+	// mode is selected by the low bits of the register address: 0 is NROM-256, 1 is UNROM, 2 is
+	// NROM-64 and 3 is NROM-128
+	mode    byte
+	prgBank byte
+	// subBank selects the 8KB half of the 16KB bank in NROM-64 mode
+	subBank byte
+}
+
+func (m *multicart15) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return m.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return m.prg[m.prgAddr(addr)]
+
+	}
+	logrus.Debugf("Read from unmapped multicart address %#X", addr)
+	return 0
+}
+
+func (m *multicart15) prgAddr(addr uint16) int {
+	bank := int(m.prgBank)
+	switch m.mode {
+	case 0:
+		if addr >= 0xC000 {
+			bank |= 1
+		}
+	case 1:
+		if addr >= 0xC000 {
+			bank |= 7
+		}
+	case 2:
+		return (bank*0x4000 + int(m.subBank)*0x2000 + int(addr&0x1FFF)) % len(m.prg)
+	}
+	return (bank*0x4000 + int(addr&0x3FFF)) % len(m.prg)
+}
+
+func (m *multicart15) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		m.ram[addr-0x6000] = val
+
+	} else if addr >= 0x8000 {
+		m.mode = byte(addr & 3)
+		m.prgBank = val & 0x3F
+		m.subBank = val >> 7
+		if val&0x40 != 0 {
+			m.mirroring = MirrorHorizontal
+		} else {
+			m.mirroring = MirrorVertical
+		}
+
+	} else {
+		logrus.Debugf("Write to unmapped multicart address %#X", addr)
+	}
+}
+
+func (m *multicart15) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return m.chr[addr]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[m.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled multicart PPU memory read from address %#x", addr))
+}
+
+func (m *multicart15) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// CHR RAM is write protected in the NROM modes, other than NROM-64
+		if m.mode == 1 || m.mode == 2 {
+			m.chr[addr] = val
+		}
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[m.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled multicart PPU memory write to address %#x", addr))
+	}
+}
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_225
+
+func newMulticart225(info *RomInfo, prg, chr []byte) (*multicart225, error) {
+	if len(prg) < 0x4000 || len(prg)%0x4000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 16KB, got %d B", len(prg))
+	}
+	return &multicart225{
+		prg:       prg,
+		chr:       chr,
+		mirroring: info.Mirroring,
+	}, nil
+}
+
+type multicart225 struct {
+	prg,
+	chr []byte
+	mirroring Mirroring
+	// ram holds four 4-bit registers at $5800-$5803
+	ram [4]byte
+
+	prgBank,
+	chrBank byte
+	// prg16 mirrors a 16KB PRG bank, rather than switching 32KB
+	prg16 bool
+}
+
+func (m *multicart225) CPURead(addr uint16) byte {
+	if addr >= 0x5800 && addr < 0x6000 {
+		return m.ram[addr&3]
+
+	} else if addr >= 0x8000 {
+		bank := int(m.prgBank)
+		if !m.prg16 {
+			bank = bank&^1 | int(addr>>14)&1
+		}
+		return m.prg[(bank*0x4000+int(addr&0x3FFF))%len(m.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped multicart address %#X", addr)
+	return 0
+}
+
+func (m *multicart225) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x5800 && addr < 0x6000 {
+		m.ram[addr&3] = val & 0xF
+
+	} else if addr >= 0x8000 {
+		// The register is written through the address lines, with A14 selecting the high bank
+		high := byte(addr>>14) & 1
+		m.prgBank = high<<6 | byte(addr>>6)&0x3F
+		m.chrBank = high<<6 | byte(addr)&0x3F
+		m.prg16 = addr&0x1000 != 0
+		if addr&0x2000 != 0 {
+			m.mirroring = MirrorHorizontal
+		} else {
+			m.mirroring = MirrorVertical
+		}
+
+	} else {
+		logrus.Debugf("Write to unmapped multicart address %#X", addr)
+	}
+}
+
+func (m *multicart225) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return m.chr[m.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[m.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled multicart PPU memory read from address %#x", addr))
+}
+
+func (m *multicart225) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		m.chr[m.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[m.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled multicart PPU memory write to address %#x", addr))
+	}
+}
+
+func (m *multicart225) chrAddr(addr uint16) int {
+	return (int(m.chrBank)*0x2000 + int(addr)) % len(m.chr)
+}
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_226
+
+func newMulticart226(info *RomInfo, prg, chr []byte) (*multicart226, error) {
+	if len(prg) < 0x4000 || len(prg)%0x4000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 16KB, got %d B", len(prg))
+	}
+	return &multicart226{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+	}, nil
+}
+
+type multicart226 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+
+	regs [2]byte
+}
+
+func (m *multicart226) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return m.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		// The PRG bank is spread across bits 0-4 and 7 of the first register, and bit 0 of the
+		// second
+		bank := int(m.regs[0]&0x1F) | int(m.regs[0]&0x80)>>2 | int(m.regs[1]&1)<<6
+		// Bit 5 mirrors a 16KB bank, rather than switching 32KB
+		if m.regs[0]&0x20 == 0 {
+			bank = bank&^1 | int(addr>>14)&1
+		}
+		return m.prg[(bank*0x4000+int(addr&0x3FFF))%len(m.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped multicart address %#X", addr)
+	return 0
+}
+
+func (m *multicart226) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		m.ram[addr-0x6000] = val
+
+	} else if addr >= 0x8000 {
+		m.regs[addr&1] = val
+		if m.regs[0]&0x40 != 0 {
+			m.mirroring = MirrorVertical
+		} else {
+			m.mirroring = MirrorHorizontal
+		}
+
+	} else {
+		logrus.Debugf("Write to unmapped multicart address %#X", addr)
+	}
+}
+
+func (m *multicart226) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return m.chr[addr]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[m.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled multicart PPU memory read from address %#x", addr))
+}
+
+func (m *multicart226) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Boards use CHR RAM
+		m.chr[addr] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[m.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled multicart PPU memory write to address %#x", addr))
+	}
+}
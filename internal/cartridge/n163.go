@@ -0,0 +1,190 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/Namco_163
+
+func newN163(info *RomInfo, prg, chr []byte) (*n163, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &n163{
+		prg:   prg,
+		chr:   chr,
+		ram:   newPRGRAM(info),
+		audio: newN163Audio(info.Submapper),
+	}, nil
+}
+
+type n163 struct {
+	prg,
+	chr,
+	ram []byte
+
+	prgBanks [3]byte
+	// chrBanks holds 1KB banks for $0000-$2FFF. Banks $E0-$FF select the console's nametable RAM
+	// instead of CHR ROM, which is always the case for the nametables at $2000-$2FFF.
+	chrBanks [12]byte
+	// chrROMOnly disables nametable RAM in the pattern tables at $0000 and $1000 respectively
+	chrROMOnly [2]bool
+	// ramWritable holds the write protection for each 2KB of PRG RAM
+	ramWritable [4]bool
+
+	irqCounter uint16
+	irqEnabled,
+	irqPending bool
+
+	audio         *n163Audio
+	audioDisabled bool
+}
+
+func (n *n163) CPURead(addr uint16) byte {
+	switch {
+	case addr >= 0x4800 && addr < 0x5000:
+		return n.audio.readData()
+
+	case addr >= 0x5000 && addr < 0x5800:
+		return byte(n.irqCounter)
+
+	case addr >= 0x5800 && addr < 0x6000:
+		val := byte(n.irqCounter >> 8)
+		if n.irqEnabled {
+			val |= 0x80
+		}
+		return val
+
+	case addr >= 0x6000 && addr < 0x8000:
+		return n.ram[addr-0x6000]
+
+	case addr >= 0xE000:
+		// Last bank is fixed
+		return n.prg[len(n.prg)-0x2000+int(addr&0x1FFF)]
+
+	case addr >= 0x8000:
+		bank := n.prgBanks[(addr-0x8000)/0x2000]
+		return n.prg[(int(bank)*0x2000+int(addr&0x1FFF))%len(n.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped N163 address %#X", addr)
+	return 0
+}
+
+func (n *n163) CPUWrite(addr uint16, val byte) {
+	switch {
+	case addr >= 0x4800 && addr < 0x5000:
+		n.audio.writeData(val)
+
+	case addr >= 0x5000 && addr < 0x5800:
+		n.irqCounter = n.irqCounter&0x7F00 | uint16(val)
+		n.irqPending = false
+
+	case addr >= 0x5800 && addr < 0x6000:
+		n.irqCounter = n.irqCounter&0xFF | uint16(val&0x7F)<<8
+		n.irqEnabled = val&0x80 != 0
+		n.irqPending = false
+
+	case addr >= 0x6000 && addr < 0x8000:
+		if n.ramWritable[(addr-0x6000)/0x800] {
+			n.ram[addr-0x6000] = val
+		}
+
+	case addr >= 0x8000 && addr < 0xE000:
+		n.chrBanks[(addr-0x8000)/0x800] = val
+
+	case addr >= 0xE000 && addr < 0xE800:
+		n.prgBanks[0] = val & 0x3F
+		n.audioDisabled = val&0x40 != 0
+
+	case addr >= 0xE800 && addr < 0xF000:
+		n.prgBanks[1] = val & 0x3F
+		n.chrROMOnly[0] = val&0x40 != 0
+		n.chrROMOnly[1] = val&0x80 != 0
+
+	case addr >= 0xF000 && addr < 0xF800:
+		n.prgBanks[2] = val & 0x3F
+
+	case addr >= 0xF800:
+		// Writes are only enabled with $4x in the high nibble, and the low bits then protect each
+		// 2KB of RAM
+		for i := range n.ramWritable {
+			n.ramWritable[i] = val&0xF0 == 0x40 && (val>>uint(i))&1 == 0
+		}
+		n.audio.writeAddr(val)
+
+	default:
+		logrus.Debugf("Write to unmapped N163 address %#X", addr)
+	}
+}
+
+func (n *n163) PPURead(addr uint16, vram []byte) byte {
+	if addr <= 0x3EFF {
+		if bank, ok := n.nametableBank(addr); ok {
+			return vram[int(bank)*0x400+int(addr&0x3FF)]
+		}
+		return n.chr[n.chrAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled N163 PPU memory read from address %#x", addr))
+}
+
+func (n *n163) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr <= 0x3EFF {
+		if bank, ok := n.nametableBank(addr); ok {
+			vram[int(bank)*0x400+int(addr&0x3FF)] = val
+			return
+		}
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		n.chr[n.chrAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled N163 PPU memory write to address %#x", addr))
+	}
+}
+
+// slot returns the 1KB bank register for a PPU address, with $3000-$3EFF mirroring $2000-$2EFF.
+func (n *n163) slot(addr uint16) int {
+	if addr >= 0x3000 {
+		addr -= 0x1000
+	}
+	return int(addr >> 10)
+}
+
+// nametableBank returns the nametable RAM page mapped at a PPU address, if the bank selects
+// nametable RAM rather than CHR ROM.
+func (n *n163) nametableBank(addr uint16) (byte, bool) {
+	slot := n.slot(addr)
+	bank := n.chrBanks[slot]
+	if bank >= 0xE0 && (slot >= 8 || !n.chrROMOnly[slot/4]) {
+		return bank & 1, true
+	}
+	return 0, false
+}
+
+func (n *n163) chrAddr(addr uint16) int {
+	bank := n.chrBanks[n.slot(addr)]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(n.chr)
+}
+
+func (n *n163) Step() {
+	// The counter stops once it reaches $7FFF
+	if n.irqEnabled && n.irqCounter < 0x7FFF {
+		n.irqCounter++
+		if n.irqCounter == 0x7FFF {
+			n.irqPending = true
+		}
+	}
+	n.audio.step()
+}
+
+func (n *n163) IRQ() bool {
+	return n.irqPending
+}
+
+func (n *n163) AudioOutput() float64 {
+	if n.audioDisabled {
+		return 0
+	}
+	return n.audio.output()
+}
@@ -0,0 +1,116 @@
+package cartridge
+
+import "math"
+
+// n163Audio implements the Namco 163 expansion sound: up to 8 wavetable channels, which read
+// 4-bit samples from 128 bytes of internal RAM shared with the channel registers.
+// http://wiki.nesdev.com/w/index.php/Namco_163_audio
+type n163Audio struct {
+	ram           [0x80]byte
+	addr          byte
+	autoIncrement bool
+
+	// Only one channel is updated (and output) at a time, so the active channels are multiplexed
+	// and become quieter and buzzier as more are enabled
+	channel,
+	cycles,
+	current int
+	// level scales each step of the output relative to the APU
+	level float64
+}
+
+func newN163Audio(submapper byte) *n163Audio {
+	return &n163Audio{
+		level: n163Level(submapper),
+	}
+}
+
+// n163UpdateCycles is the number of CPU cycles spent updating each channel
+const n163UpdateCycles = 15
+
+// n163Level returns the scale of each step of the output relative to the APU. Boards mix the N163
+// through different resistors, which NES 2.0 submappers 3-5 identify as 11-13dB, 16-17dB and
+// 18-19.5dB louder than the APU, and submapper 2 as having no expansion sound. The middle of each
+// range is used, with the quietest for boards without a submapper.
+// http://wiki.nesdev.com/w/index.php/NES_2.0_submappers#019:_Namco_129.2F163
+func n163Level(submapper byte) float64 {
+	gain := 12.0
+	switch submapper {
+	case 2:
+		return 0
+	case 4:
+		gain = 16.5
+	case 5:
+		gain = 18.75
+	}
+	// A full volume channel spans 15 sample steps at volume 15, against 15 volume steps for a
+	// full volume APU pulse
+	return apuPulseStep / 15 * math.Pow(10, gain/20)
+}
+
+func (n *n163Audio) writeAddr(val byte) {
+	n.addr = val & 0x7F
+	n.autoIncrement = val>>7 == 1
+}
+
+func (n *n163Audio) readData() byte {
+	val := n.ram[n.addr]
+	n.increment()
+	return val
+}
+
+func (n *n163Audio) writeData(val byte) {
+	n.ram[n.addr] = val
+	n.increment()
+}
+
+func (n *n163Audio) increment() {
+	if n.autoIncrement {
+		n.addr = (n.addr + 1) & 0x7F
+	}
+}
+
+// enabledChannels returns the number of active channels, which are always the highest numbered.
+func (n *n163Audio) enabledChannels() int {
+	return int(n.ram[0x7F]>>4&7) + 1
+}
+
+// step advances the chip by a CPU cycle.
+func (n *n163Audio) step() {
+	n.cycles++
+	if n.cycles < n163UpdateCycles {
+		return
+	}
+	n.cycles = 0
+
+	if n.channel < 8-n.enabledChannels() {
+		n.channel = 7
+	}
+	n.current = n.updateChannel(n.channel)
+	n.channel--
+}
+
+// updateChannel advances the phase of a channel, and returns its output.
+func (n *n163Audio) updateChannel(channel int) int {
+	regs := n.ram[0x40+channel*8 : 0x48+channel*8]
+	freq := uint32(regs[0]) | uint32(regs[2])<<8 | uint32(regs[4]&3)<<16
+	phase := uint32(regs[1]) | uint32(regs[3])<<8 | uint32(regs[5])<<16
+	length := uint32(256-int(regs[4]&0xFC)) << 16
+
+	phase = (phase + freq) % length
+	regs[1] = byte(phase)
+	regs[3] = byte(phase >> 8)
+	regs[5] = byte(phase >> 16)
+
+	// Samples are packed two per byte, low nibble first
+	sampleAddr := (int(regs[6]) + int(phase>>16)) & 0xFF
+	sample := n.ram[sampleAddr>>1]
+	if sampleAddr&1 == 1 {
+		sample >>= 4
+	}
+	return (int(sample&0xF) - 8) * int(regs[7]&0xF)
+}
+
+func (n *n163Audio) output() float64 {
+	return float64(n.current) * n.level
+}
@@ -0,0 +1,101 @@
+package cartridge
+
+import "testing"
+
+func TestN163Banking(t *testing.T) {
+	n, err := newN163(&RomInfo{}, testPRG(32), testCHR(0x40000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.CPUWrite(0xE000, 3)
+	n.CPUWrite(0xE800, 4)
+	n.CPUWrite(0xF000, 5)
+	for addr, expected := range map[uint16]byte{0x8000: 3, 0xA000: 4, 0xC000: 5, 0xE000: 31} {
+		if bank := n.CPURead(addr); bank != expected {
+			t.Errorf("expected bank %d at %#x, got %d", expected, addr, bank)
+		}
+	}
+
+	vram := make([]byte, 0x1000)
+	vram[0x400] = 0x99
+	// Pattern table banks map CHR ROM below $E0, and nametable RAM above
+	n.CPUWrite(0x8000, 0x20)
+	n.CPUWrite(0x8800, 0xE1)
+	if bank := n.PPURead(0x0000, vram); bank != 0x20 {
+		t.Errorf("expected CHR bank $20 at $0000, got %#x", bank)
+	}
+	if val := n.PPURead(0x0400, vram); val != 0x99 {
+		t.Errorf("expected nametable RAM at $0400, got %#x", val)
+	}
+	// ...unless disabled for the pattern table
+	n.CPUWrite(0xE800, 0x44)
+	if bank := n.PPURead(0x0400, vram); bank != 0xE1 {
+		t.Errorf("expected CHR bank $E1 at $0400, got %#x", bank)
+	}
+
+	// Nametables can map CHR ROM as well, with $3000 mirroring $2000
+	n.CPUWrite(0xC000, 0xE1)
+	n.CPUWrite(0xC800, 0x30)
+	n.PPUWrite(0x2001, 0x42, vram)
+	if val := vram[0x401]; val != 0x42 {
+		t.Errorf("expected write to nametable RAM page 1, got %#x", val)
+	}
+	if bank := n.PPURead(0x3400, vram); bank != 0x30 {
+		t.Errorf("expected CHR bank $30 at $3400, got %#x", bank)
+	}
+}
+
+func TestN163RAMProtect(t *testing.T) {
+	n, _ := newN163(&RomInfo{}, testPRG(4), make([]byte, 0x2000))
+	n.CPUWrite(0x6000, 0x42)
+	if val := n.CPURead(0x6000); val != 0 {
+		t.Errorf("expected PRG RAM to be write protected, got %#x", val)
+	}
+	// Protect only $6800-$6FFF
+	n.CPUWrite(0xF800, 0x42)
+	n.CPUWrite(0x6000, 0x42)
+	n.CPUWrite(0x6800, 0x42)
+	if val := n.CPURead(0x6000); val != 0x42 {
+		t.Errorf("expected PRG RAM to be writable, got %#x", val)
+	}
+	if val := n.CPURead(0x6800); val != 0 {
+		t.Errorf("expected $6800 to be write protected, got %#x", val)
+	}
+}
+
+func TestN163IRQ(t *testing.T) {
+	n, _ := newN163(&RomInfo{}, testPRG(4), make([]byte, 0x2000))
+	n.CPUWrite(0x5000, 0xFD)
+	n.CPUWrite(0x5800, 0xFF)
+	if val := n.CPURead(0x5800); val != 0xFF {
+		t.Errorf("expected counter high byte and enable to read back, got %#x", val)
+	}
+	n.Step()
+	if n.IRQ() {
+		t.Fatal("unexpected IRQ before the counter reached $7FFF")
+	}
+	n.Step()
+	if !n.IRQ() {
+		t.Fatal("expected IRQ when the counter reached $7FFF")
+	}
+	// The counter stops at $7FFF
+	n.Step()
+	if val := n.CPURead(0x5000); val != 0xFF {
+		t.Errorf("expected counter to stop at $7FFF, got low byte %#x", val)
+	}
+	n.CPUWrite(0x5800, 0)
+	if n.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+}
+
+func TestN163Audio(t *testing.T) {
+	n, _ := newN163(&RomInfo{}, testPRG(4), make([]byte, 0x2000))
+	// The sound address port shares $F800 with the write protection
+	n.CPUWrite(0xF800, 0x80|0x7F)
+	n.CPUWrite(0x4800, 0x70)
+	n.CPUWrite(0xF800, 0x7F)
+	if val := n.CPURead(0x4800); val != 0x70 {
+		t.Errorf("expected sound RAM to read back, got %#x", val)
+	}
+}
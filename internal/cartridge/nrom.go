@@ -5,7 +5,7 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func newNROM(prg, chr []byte) (*nrom, error) {
+func newNROM(info *RomInfo, prg, chr []byte) (*nrom, error) {
 	var prgMask uint16
 	if len(prg) == 0x4000 {
 		prgMask = 0x3FFF
@@ -16,11 +16,12 @@ func newNROM(prg, chr []byte) (*nrom, error) {
 	}
 
 	return &nrom{
-		prgMask: prgMask,
-		prg:     prg,
-		chr:     chr,
+		prgMask:   prgMask,
+		prg:       prg,
+		chr:       chr,
+		mirroring: info.Mirroring,
 		// TODO: Unclear if this should actually be provided?
-		ram: make([]byte, 0x2000),
+		ram: newPRGRAM(info),
 	}, nil
 }
 
@@ -28,7 +29,8 @@ type nrom struct {
 	prgMask uint16
 	prg,
 	chr []byte
-	ram []byte
+	ram       []byte
+	mirroring Mirroring
 }
 
 func (n *nrom) CPURead(addr uint16) byte {
@@ -53,7 +55,7 @@ func (n *nrom) PPURead(addr uint16, vram []byte) byte {
 		return n.chr[addr]
 	}
 	if addr >= 0x2000 && addr <= 0x3EFF {
-		return vram[addr&0xFFF]
+		return vram[n.mirroring.NametableAddr(addr)]
 	}
 	panic(fmt.Sprintf("unhandled NROM PPU memory read from address %#x", addr))
 }
@@ -63,7 +65,7 @@ func (n *nrom) PPUWrite(addr uint16, val byte, vram []byte) {
 		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
 		n.chr[addr] = val
 	} else if addr >= 0x2000 && addr <= 0x3EFF {
-		vram[addr&0xFFF] = val
+		vram[n.mirroring.NametableAddr(addr)] = val
 	} else {
 		panic(fmt.Sprintf("unhandled NROM PPU memory write to address %#x", addr))
 	}
@@ -0,0 +1,335 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NSF implements a synthetic cartridge for playing NSF music files. Alongside the bankswitched
+// program data, it maps a small driver routine which calls the file's INIT routine for the
+// selected track, then polls a timer register to call PLAY at the rate requested by the file.
+// http://wiki.nesdev.com/w/index.php/NSF
+type NSF struct {
+	config NSFConfig
+	// prg holds the program data, padded to align with 4KB banks
+	prg   []byte
+	banks [8]int
+	ram   []byte
+
+	driver [driverSize]byte
+
+	// Play timer, tracked in units of CPU cycles * 1000000
+	timer,
+	timerPeriod uint64
+	playPending bool
+
+	// Expansion audio chips, which are nil if not used by the file
+	vrc6      *vrc6Audio
+	vrc7      *vrc7Audio
+	fds       *fdsAudio
+	n163      *n163Audio
+	sunsoft5B *sunsoft5BAudio
+	mmc5      *mmc5Audio
+	chips     []audioChip
+}
+
+// audioChip is implemented by each of the expansion audio chips.
+type audioChip interface {
+	step()
+	output() float64
+}
+
+// NSFConfig describes how the program data from an NSF file is mapped and called.
+type NSFConfig struct {
+	Data []byte
+	LoadAddress,
+	InitAddress,
+	PlayAddress uint16
+	// Bankswitch holds the initial values of the bank registers - if they are all zero, the file
+	// does not use bankswitching
+	Bankswitch [8]byte
+	// PlaySpeed is the period between calls to PLAY, in microseconds
+	PlaySpeed uint16
+	// Expansion holds the flags for the expansion audio chips used by the file
+	Expansion byte
+}
+
+// NSF expansion audio flags
+const (
+	NSFExpansionVRC6 = 1 << iota
+	NSFExpansionVRC7
+	NSFExpansionFDS
+	NSFExpansionMMC5
+	NSFExpansionN163
+	NSFExpansionSunsoft5B
+)
+
+const (
+	driverAddress     = 0x4100
+	driverSize        = 0x100
+	driverPlayAddress = driverAddress + 0x20
+	driverRTIAddress  = driverAddress + 0x2B
+	// driverTimerReg reports whether a call to PLAY is due in bit 7, and is cleared when read
+	driverTimerReg = driverAddress + 0xF0
+
+	nsfBankSize  = 0x1000
+	nsfCPUClock  = 1789773
+	microsPerSec = 1000000
+)
+
+func NewNSF(config NSFConfig) (*NSF, error) {
+	if config.LoadAddress < 0x8000 {
+		return nil, fmt.Errorf("unsupported NSF load address %#x", config.LoadAddress)
+	}
+	if config.PlaySpeed == 0 {
+		return nil, fmt.Errorf("invalid NSF play speed %d", config.PlaySpeed)
+	}
+
+	n := &NSF{
+		config:      config,
+		ram:         make([]byte, 0x2000),
+		timerPeriod: uint64(config.PlaySpeed) * nsfCPUClock,
+	}
+	n.resetExpansion()
+
+	var padding int
+	if n.bankswitched() {
+		padding = int(config.LoadAddress & 0xFFF)
+	} else {
+		padding = int(config.LoadAddress - 0x8000)
+	}
+	size := padding + len(config.Data)
+	if size%nsfBankSize != 0 {
+		size += nsfBankSize - size%nsfBankSize
+	}
+	n.prg = make([]byte, size)
+	copy(n.prg[padding:], config.Data)
+
+	return n, nil
+}
+
+func (n *NSF) bankswitched() bool {
+	for _, bank := range n.config.Bankswitch {
+		if bank != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTrack prepares the cartridge to play the given (zero-based) track from the next CPU reset.
+func (n *NSF) SetTrack(track byte) {
+	for i := range n.banks {
+		if n.bankswitched() {
+			n.setBank(i, n.config.Bankswitch[i])
+		} else {
+			n.setBank(i, byte(i))
+		}
+	}
+	for i := range n.ram {
+		n.ram[i] = 0
+	}
+	n.timer = 0
+	n.playPending = false
+	n.resetExpansion()
+	n.assembleDriver(track)
+}
+
+func (n *NSF) setBank(slot int, bank byte) {
+	n.banks[slot] = int(bank) % (len(n.prg) / nsfBankSize)
+}
+
+// resetExpansion initializes the expansion audio chips used by the file.
+func (n *NSF) resetExpansion() {
+	n.chips = nil
+	if n.config.Expansion&NSFExpansionVRC6 != 0 {
+		n.vrc6 = &vrc6Audio{}
+		n.chips = append(n.chips, n.vrc6)
+	}
+	if n.config.Expansion&NSFExpansionVRC7 != 0 {
+		n.vrc7 = newVRC7Audio()
+		n.chips = append(n.chips, n.vrc7)
+	}
+	if n.config.Expansion&NSFExpansionFDS != 0 {
+		n.fds = newFDSAudio()
+		n.chips = append(n.chips, n.fds)
+	}
+	if n.config.Expansion&NSFExpansionMMC5 != 0 {
+		n.mmc5 = &mmc5Audio{}
+		n.chips = append(n.chips, n.mmc5)
+	}
+	if n.config.Expansion&NSFExpansionN163 != 0 {
+		n.n163 = newN163Audio(0)
+		n.chips = append(n.chips, n.n163)
+	}
+	if n.config.Expansion&NSFExpansionSunsoft5B != 0 {
+		n.sunsoft5B = newSunsoft5BAudio()
+		n.chips = append(n.chips, n.sunsoft5B)
+	}
+}
+
+// assembleDriver builds the driver routine, which is mapped at $4100.
+func (n *NSF) assembleDriver(track byte) {
+	init := n.config.InitAddress
+	play := n.config.PlayAddress
+	code := []byte{
+		// Reset
+		0x78,       // SEI
+		0xD8,       // CLD
+		0xA2, 0xFF, // LDX #$FF
+		0x9A, // TXS
+		// Silence the APU
+		0xA9, 0x00, // LDA #$00
+		0xA2, 0x13, // LDX #$13
+		0x9D, 0x00, 0x40, // STA $4000,X
+		0xCA,       // DEX
+		0x10, 0xFA, // BPL -6
+		0xA9, 0x0F, // LDA #$0F
+		0x8D, 0x15, 0x40, // STA $4015
+		0xA9, 0x40, // LDA #$40
+		0x8D, 0x17, 0x40, // STA $4017
+		// Call INIT with the track in A and NTSC region in X
+		0xA9, track, // LDA #track
+		0xA2, 0x00, // LDX #$00
+		0x20, byte(init), byte(init >> 8), // JSR init
+		// Wait for the timer, then call PLAY
+		0x2C, byte(driverTimerReg & 0xFF), byte(driverTimerReg >> 8), // BIT timer
+		0x10, 0xFB, // BPL -5
+		0x20, byte(play), byte(play >> 8), // JSR play
+		0x4C, byte(driverPlayAddress & 0xFF), byte(driverPlayAddress >> 8), // JMP wait
+		// NMI/IRQ handler
+		0x40, // RTI
+	}
+	copy(n.driver[:], code)
+}
+
+// Step advances the play timer and expansion audio by a CPU cycle.
+func (n *NSF) Step() {
+	n.timer += microsPerSec
+	if n.timer >= n.timerPeriod {
+		n.timer -= n.timerPeriod
+		n.playPending = true
+	}
+	for _, chip := range n.chips {
+		chip.step()
+	}
+}
+
+func (n *NSF) AudioOutput() float64 {
+	var level float64
+	for _, chip := range n.chips {
+		level += chip.output()
+	}
+	return level
+}
+
+func (n *NSF) CPURead(addr uint16) byte {
+	if addr == driverTimerReg {
+		var val byte
+		if n.playPending {
+			val = 0x80
+		}
+		n.playPending = false
+		return val
+
+	} else if addr >= driverAddress && addr < driverAddress+driverSize {
+		return n.driver[addr-driverAddress]
+
+	} else if n.fds != nil && addr >= 0x4040 && addr <= 0x4092 {
+		return n.fds.read(addr)
+
+	} else if n.n163 != nil && addr == 0x4800 {
+		return n.n163.readData()
+
+	} else if n.mmc5 != nil && addr >= 0x5000 && addr <= 0x5015 {
+		if val, ok := n.mmc5.read(addr); ok {
+			return val
+		}
+
+	} else if addr >= 0x6000 && addr < 0x8000 {
+		return n.ram[addr-0x6000]
+
+	} else if addr >= 0xFFFA {
+		// Vectors are overridden to point into the driver
+		switch addr {
+		case 0xFFFC:
+			return byte(driverAddress & 0xFF)
+		case 0xFFFD:
+			return byte(driverAddress >> 8)
+		case 0xFFFA, 0xFFFE:
+			return byte(driverRTIAddress & 0xFF)
+		default:
+			return byte(driverRTIAddress >> 8)
+		}
+
+	} else if addr >= 0x8000 {
+		slot := int(addr-0x8000) / nsfBankSize
+		val := n.prg[n.banks[slot]*nsfBankSize+int(addr&0xFFF)]
+		if n.mmc5 != nil {
+			n.mmc5.observeRead(addr, val)
+		}
+		return val
+
+	}
+	logrus.Debugf("Read from unmapped NSF address %#X", addr)
+	return 0
+}
+
+func (n *NSF) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x5FF8 && addr <= 0x5FFF {
+		n.setBank(int(addr-0x5FF8), val)
+
+	} else if addr >= 0x6000 && addr < 0x8000 {
+		n.ram[addr-0x6000] = val
+
+	} else if n.fds != nil && addr >= 0x4040 && addr <= 0x4092 {
+		n.fds.write(addr, val)
+
+	} else if n.fds != nil && addr >= 0x8000 && addr < 0xE000 {
+		// FDS programs run from RAM on the disk system, so may write to their own code and data
+		slot := int(addr-0x8000) / nsfBankSize
+		n.prg[n.banks[slot]*nsfBankSize+int(addr&0xFFF)] = val
+
+	} else if n.vrc7 != nil && addr == 0x9010 {
+		n.vrc7.writeAddr(val)
+
+	} else if n.vrc7 != nil && addr == 0x9030 {
+		n.vrc7.writeData(val)
+
+	} else if n.vrc6 != nil && addr >= 0x9000 && addr <= 0xB002 {
+		n.vrc6.write(addr, val)
+
+	} else if n.mmc5 != nil && addr >= 0x5000 && addr <= 0x5015 {
+		n.mmc5.write(addr, val)
+
+	} else if n.n163 != nil && addr == 0x4800 {
+		n.n163.writeData(val)
+
+	} else if n.n163 != nil && addr == 0xF800 {
+		n.n163.writeAddr(val)
+
+	} else if n.sunsoft5B != nil && addr == 0xC000 {
+		n.sunsoft5B.writeReg(val)
+
+	} else if n.sunsoft5B != nil && addr == 0xE000 {
+		n.sunsoft5B.writeData(val)
+
+	} else {
+		logrus.Debugf("Write to unmapped NSF address %#X", addr)
+	}
+}
+
+func (n *NSF) PPURead(addr uint16, vram []byte) byte {
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[addr&0xFFF]
+	}
+	// No CHR - the PPU isn't used for anything
+	return 0
+}
+
+func (n *NSF) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[addr&0xFFF] = val
+	}
+}
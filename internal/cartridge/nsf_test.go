@@ -0,0 +1,50 @@
+package cartridge
+
+import "testing"
+
+func testNSFCartridge(t *testing.T, expansion byte) *NSF {
+	n, err := NewNSF(NSFConfig{
+		Data:        []byte{0x60},
+		LoadAddress: 0x8000,
+		InitAddress: 0x8000,
+		PlayAddress: 0x8000,
+		PlaySpeed:   16639,
+		Expansion:   expansion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNSFVRC7(t *testing.T) {
+	n := testNSFCartridge(t, NSFExpansionVRC7)
+	n.CPUWrite(0x9010, 0x30)
+	n.CPUWrite(0x9030, 0x5A)
+	if c := n.vrc7.channels[0]; c.instrument != 5 || c.volume != 0xA {
+		t.Errorf("expected instrument 5 and volume 10 on channel 0, got %d and %d", c.instrument, c.volume)
+	}
+	if len(n.chips) != 1 {
+		t.Errorf("expected VRC7 to be mixed, got %d chips", len(n.chips))
+	}
+}
+
+func TestNSFFDS(t *testing.T) {
+	n := testNSFCartridge(t, NSFExpansionFDS)
+	// Wave RAM is writable while enabled by $4089
+	n.CPUWrite(0x4089, 0x80)
+	n.CPUWrite(0x4040, 0x3F)
+	if val := n.CPURead(0x4040); val != 0x3F {
+		t.Errorf("expected wave RAM to hold %#x, got %#x", 0x3F, val)
+	}
+	// Direct volume gain, read back from $4090
+	n.CPUWrite(0x4080, 0x80|0x20)
+	if val := n.CPURead(0x4090); val != 0x20 {
+		t.Errorf("expected volume gain %#x, got %#x", 0x20, val)
+	}
+	// The program area is RAM
+	n.CPUWrite(0x8001, 0x42)
+	if val := n.CPURead(0x8001); val != 0x42 {
+		t.Errorf("expected program area to be writable, got %#x", val)
+	}
+}
@@ -0,0 +1,53 @@
+package cartridge
+
+import "sync"
+
+// Constructor creates a cartridge from its ROM contents. chr holds the CHR ROM, or zeroed CHR RAM
+// if the cartridge has none.
+type Constructor func(info *RomInfo, prg, chr []byte) (Cartridge, error)
+
+type mapperID struct {
+	mapper    uint16
+	submapper byte
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[mapperID]Constructor{}
+)
+
+// Register adds a constructor for a mapper and submapper, which is used in place of any built-in
+// mapper. The constructor for submapper 0 is also used for any other submapper without its own.
+// Registering the same mapper and submapper again replaces the earlier constructor.
+//
+// The returned function restores the previous registration, so that tests can clean up after
+// themselves.
+func Register(mapper uint16, submapper byte, constructor Constructor) (restore func()) {
+	if constructor == nil {
+		panic("cartridge: Register constructor is nil")
+	}
+	id := mapperID{mapper, submapper}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	previous, ok := registry[id]
+	registry[id] = constructor
+	return func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		if ok {
+			registry[id] = previous
+		} else {
+			delete(registry, id)
+		}
+	}
+}
+
+// registered returns the constructor added for a mapper and submapper, or nil if there is none.
+func registered(mapper uint16, submapper byte) Constructor {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if constructor, ok := registry[mapperID{mapper, submapper}]; ok {
+		return constructor
+	}
+	return registry[mapperID{mapper, 0}]
+}
@@ -0,0 +1,80 @@
+package cartridge
+
+// RomInfo describes a cartridge, as parsed from an iNES or NES 2.0 header.
+// http://wiki.nesdev.com/w/index.php/NES_2.0
+type RomInfo struct {
+	// NES2 is set if the header is in NES 2.0 format, in which case all fields are populated.
+	// Otherwise, fields not supported by iNES are left as their zero values.
+	NES2      bool
+	Mapper    uint16
+	Submapper byte
+	// Mirroring is the nametable arrangement wired on the board, which some mappers override
+	Mirroring Mirroring
+
+	// RAM sizes in bytes - NVRAM is battery backed
+	PRGRAMSize,
+	PRGNVRAMSize,
+	CHRRAMSize,
+	CHRNVRAMSize int
+	Battery bool
+	// Trainer holds the 512 byte trainer, if present, which is mapped at $7000
+	Trainer []byte
+	// SaveData holds the contents of save storage from a previous session, as returned by
+	// Saver.SaveData, which is loaded when the cartridge is created
+	SaveData []byte
+
+	Timing  Timing
+	Console ConsoleType
+	// ConsoleDetails holds the Vs. System PPU and hardware types, or the extended console type
+	ConsoleDetails  byte
+	MiscROMs        byte
+	ExpansionDevice byte
+}
+
+// Timing is the CPU/PPU timing region that a cartridge targets.
+type Timing byte
+
+const (
+	TimingNTSC Timing = iota
+	TimingPAL
+	// TimingMulti indicates the cartridge works in multiple regions
+	TimingMulti
+	TimingDendy
+)
+
+// ConsoleType is the type of console that a cartridge targets.
+type ConsoleType byte
+
+const (
+	ConsoleNES ConsoleType = iota
+	ConsoleVsSystem
+	ConsolePlaychoice10
+	// ConsoleExtended indicates the console type is given by RomInfo.ConsoleDetails
+	ConsoleExtended
+)
+
+const (
+	defaultPRGRAMSize = 0x2000
+	// trainerOffset is the offset of $7000 in PRG RAM
+	trainerOffset = 0x1000
+)
+
+// newPRGRAM allocates PRG RAM for the cartridge, at least 8KB, and loads the trainer if present.
+func newPRGRAM(info *RomInfo) []byte {
+	size := info.PRGRAMSize + info.PRGNVRAMSize
+	if size < defaultPRGRAMSize {
+		size = defaultPRGRAMSize
+	}
+	ram := make([]byte, size)
+	copy(ram[trainerOffset:], info.Trainer)
+	return ram
+}
+
+// hasBusConflicts returns whether writes to a discrete mapper's ROM registers conflict with the ROM
+// output, in which case the written value is ANDed with the ROM byte at the address. Submapper 1
+// indicates no bus conflicts and 2 indicates bus conflicts. Without a submapper they're emulated,
+// since games written for the conflicting boards write matching values, and so work either way.
+// http://wiki.nesdev.com/w/index.php/Bus_conflict
+func hasBusConflicts(info *RomInfo) bool {
+	return info.Submapper != 1
+}
@@ -0,0 +1,165 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_018
+
+func newSS88006(info *RomInfo, prg, chr []byte) (*ss88006, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &ss88006{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		mirroring: info.Mirroring,
+	}, nil
+}
+
+// ss88006Mirroring maps the mirroring register to the nametable arrangement
+var ss88006Mirroring = [4]Mirroring{MirrorHorizontal, MirrorVertical, MirrorSingleScreenA, MirrorSingleScreenB}
+
+// ss88006IRQMasks holds the bits of the IRQ counter that are clocked for each counter size, from
+// the control register's bits 1-3
+var ss88006IRQMasks = [8]uint16{0xFFFF, 0x0FFF, 0x00FF, 0x00FF, 0x000F, 0x000F, 0x000F, 0x000F}
+
+type ss88006 struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring Mirroring
+
+	// Bank numbers are written as nibbles
+	prgBanks [3]byte
+	chrBanks [8]byte
+	ramEnabled,
+	ramWritable bool
+
+	irqReload,
+	irqCounter,
+	irqMask uint16
+	irqEnabled,
+	irqPending bool
+}
+
+func (s *ss88006) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if !s.ramEnabled {
+			return 0
+		}
+		return s.ram[addr-0x6000]
+
+	} else if addr >= 0xE000 {
+		// Last bank is fixed
+		return s.prg[len(s.prg)-0x2000+int(addr&0x1FFF)]
+
+	} else if addr >= 0x8000 {
+		bank := s.prgBanks[(addr-0x8000)/0x2000]
+		return s.prg[(int(bank)*0x2000+int(addr&0x1FFF))%len(s.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped SS88006 address %#X", addr)
+	return 0
+}
+
+func (s *ss88006) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if s.ramEnabled && s.ramWritable {
+			s.ram[addr-0x6000] = val
+		}
+		return
+	}
+	if addr < 0x8000 {
+		logrus.Debugf("Write to unmapped SS88006 address %#X", addr)
+		return
+	}
+
+	reg := addr & 0xF003
+	// Even registers hold the low nibble of a pair, and odd registers the high nibble
+	nibble := func(bank byte) byte {
+		if reg&1 == 0 {
+			return bank&0xF0 | val&0xF
+		}
+		return bank&0xF | val<<4
+	}
+	switch {
+	case reg <= 0x9001:
+		bank := (reg-0x8000)>>12*2 + (reg&2)>>1
+		s.prgBanks[bank] = nibble(s.prgBanks[bank])
+
+	case reg == 0x9002:
+		s.ramEnabled = val&1 == 1
+		s.ramWritable = val&2 != 0
+
+	case reg >= 0xA000 && reg <= 0xD003:
+		bank := (reg-0xA000)>>12*2 + (reg&2)>>1
+		s.chrBanks[bank] = nibble(s.chrBanks[bank])
+
+	case reg >= 0xE000 && reg <= 0xE003:
+		shift := (reg & 3) * 4
+		s.irqReload = s.irqReload&^(0xF<<shift) | uint16(val&0xF)<<shift
+
+	case reg == 0xF000:
+		s.irqCounter = s.irqReload
+		s.irqPending = false
+
+	case reg == 0xF001:
+		s.irqEnabled = val&1 == 1
+		s.irqMask = ss88006IRQMasks[val>>1&7]
+		s.irqPending = false
+
+	case reg == 0xF002:
+		s.mirroring = ss88006Mirroring[val&3]
+
+	case reg == 0xF003:
+		// The ADPCM sound chip on some boards plays samples from its own ROM, which isn't
+		// included in dumps
+		logrus.Debugf("Unsupported SS88006 sound write %#X", val)
+	}
+}
+
+func (s *ss88006) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return s.chr[s.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[s.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled SS88006 PPU memory read from address %#x", addr))
+}
+
+func (s *ss88006) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		s.chr[s.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[s.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled SS88006 PPU memory write to address %#x", addr))
+	}
+}
+
+func (s *ss88006) chrAddr(addr uint16) int {
+	bank := s.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(s.chr)
+}
+
+func (s *ss88006) Step() {
+	if !s.irqEnabled {
+		return
+	}
+	// Only the bits selected by the counter size are clocked, and the IRQ fires when they wrap
+	counter := s.irqCounter & s.irqMask
+	if counter == 0 {
+		s.irqPending = true
+	}
+	s.irqCounter = s.irqCounter&^s.irqMask | (counter-1)&s.irqMask
+}
+
+func (s *ss88006) IRQ() bool {
+	return s.irqPending
+}
@@ -0,0 +1,73 @@
+package cartridge
+
+import "testing"
+
+func TestSS88006Banking(t *testing.T) {
+	s, err := newSS88006(&RomInfo{}, testPRG(32), testCHR(0x40000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Banks are written as low and high nibbles
+	for _, write := range [][2]uint16{
+		{0x8000, 0x3}, {0x8001, 0x1},
+		{0x8002, 0x4}, {0x8003, 0x1},
+		{0x9000, 0x5}, {0x9001, 0x1},
+		{0xA000, 0x7}, {0xA001, 0x2},
+		{0xD002, 0x8}, {0xD003, 0xA},
+	} {
+		s.CPUWrite(write[0], byte(write[1]))
+	}
+	for addr, expected := range map[uint16]byte{0x8000: 19, 0xA000: 20, 0xC000: 21, 0xE000: 31} {
+		if bank := s.CPURead(addr); bank != expected {
+			t.Errorf("expected bank %d at %#x, got %d", expected, addr, bank)
+		}
+	}
+	if bank := s.PPURead(0x0000, nil); bank != 0x27 {
+		t.Errorf("expected CHR bank $27 at $0000, got %#x", bank)
+	}
+	if bank := s.PPURead(0x1C00, nil); bank != 0xA8 {
+		t.Errorf("expected CHR bank $A8 at $1C00, got %#x", bank)
+	}
+
+	// PRG RAM needs to be enabled, and writable to write to it
+	s.CPUWrite(0x9002, 1)
+	s.CPUWrite(0x6000, 0x42)
+	if val := s.CPURead(0x6000); val != 0 {
+		t.Errorf("expected PRG RAM to be write protected, got %#x", val)
+	}
+	s.CPUWrite(0x9002, 3)
+	s.CPUWrite(0x6000, 0x42)
+	if val := s.CPURead(0x6000); val != 0x42 {
+		t.Errorf("expected PRG RAM to be writable, got %#x", val)
+	}
+
+	s.CPUWrite(0xF002, 1)
+	if s.mirroring != MirrorVertical {
+		t.Errorf("expected vertical mirroring, got %d", s.mirroring)
+	}
+}
+
+func TestSS88006IRQ(t *testing.T) {
+	s, _ := newSS88006(&RomInfo{}, testPRG(4), make([]byte, 0x2000))
+	// Reload $1231, with a 4-bit counter that only clocks the low nibble
+	for i, val := range []byte{0x1, 0x3, 0x2, 0x1} {
+		s.CPUWrite(0xE000+uint16(i), val)
+	}
+	s.CPUWrite(0xF000, 0)
+	s.CPUWrite(0xF001, 0x09)
+	s.Step()
+	if s.IRQ() {
+		t.Fatal("unexpected IRQ before the counter wrapped")
+	}
+	s.Step()
+	if !s.IRQ() {
+		t.Fatal("expected IRQ when the counter wrapped")
+	}
+	if s.irqCounter != 0x123F {
+		t.Errorf("expected only the low nibble to wrap, got %#x", s.irqCounter)
+	}
+	s.CPUWrite(0xF001, 0x09)
+	if s.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+}
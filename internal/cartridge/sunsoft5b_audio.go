@@ -0,0 +1,181 @@
+package cartridge
+
+import "math"
+
+// sunsoft5BAudio implements the Sunsoft 5B expansion sound, a variant of the AY-3-8910 with three
+// square wave channels, a shared noise generator and a shared envelope generator.
+// http://wiki.nesdev.com/w/index.php/Sunsoft_5B_audio
+type sunsoft5BAudio struct {
+	reg byte
+
+	tones    [3]sunsoft5BTone
+	volumes  [3]byte
+	envelope sunsoft5BEnvelope
+	// mixer disables tone (bits 0-2) and noise (bits 3-5) for each channel
+	mixer byte
+
+	noisePeriod,
+	noiseCounter byte
+	noise uint32
+
+	cycles uint
+}
+
+// sunsoft5BLevels holds the output amplitude for each of the 32 levels, which are spaced 1.5dB
+// apart. Volume registers select every second level.
+var sunsoft5BLevels = func() (levels [32]float64) {
+	for i := 1; i < len(levels); i++ {
+		levels[i] = math.Pow(10, -1.5*float64(31-i)/20)
+	}
+	return levels
+}()
+
+// sunsoft5BLevel scales a full amplitude channel relative to the APU. Without a hardware
+// measurement of the cartridge's mix to calibrate against, a channel at the highest volume
+// register setting matches the 15 volume steps of a full volume APU pulse channel.
+const sunsoft5BLevel = apuPulseStep * 15
+
+func newSunsoft5BAudio() *sunsoft5BAudio {
+	return &sunsoft5BAudio{
+		noise: 1,
+	}
+}
+
+func (s *sunsoft5BAudio) writeReg(val byte) {
+	s.reg = val & 0xF
+}
+
+func (s *sunsoft5BAudio) writeData(val byte) {
+	switch s.reg {
+	case 0, 2, 4:
+		tone := &s.tones[s.reg/2]
+		tone.period = tone.period&0xF00 | uint16(val)
+	case 1, 3, 5:
+		tone := &s.tones[s.reg/2]
+		tone.period = tone.period&0xFF | uint16(val&0xF)<<8
+	case 6:
+		s.noisePeriod = val & 0x1F
+	case 7:
+		s.mixer = val
+	case 8, 9, 10:
+		s.volumes[s.reg-8] = val & 0x1F
+	case 11:
+		s.envelope.period = s.envelope.period&0xFF00 | uint16(val)
+	case 12:
+		s.envelope.period = s.envelope.period&0xFF | uint16(val)<<8
+	case 13:
+		s.envelope.writeShape(val)
+	}
+}
+
+// step advances the chip by a CPU cycle.
+func (s *sunsoft5BAudio) step() {
+	s.cycles++
+	// Envelope steps are half the length of the tone steps, giving it a 32 step resolution
+	if s.cycles%8 == 0 {
+		s.envelope.clock()
+	}
+	if s.cycles%16 == 0 {
+		for i := range s.tones {
+			s.tones[i].clock()
+		}
+	}
+	if s.cycles%32 == 0 {
+		s.noiseCounter++
+		if s.noiseCounter >= s.noisePeriod {
+			s.noiseCounter = 0
+			// 17-bit LFSR
+			feedback := (s.noise ^ s.noise>>3) & 1
+			s.noise = s.noise>>1 | feedback<<16
+		}
+	}
+}
+
+func (s *sunsoft5BAudio) output() float64 {
+	var level float64
+	for i, tone := range s.tones {
+		toneOn := tone.high || s.mixer>>uint(i)&1 == 1
+		noiseOn := s.noise&1 == 1 || s.mixer>>uint(i+3)&1 == 1
+		if !toneOn || !noiseOn {
+			continue
+		}
+		if s.volumes[i]&0x10 != 0 {
+			level += sunsoft5BLevels[s.envelope.level()]
+		} else if volume := s.volumes[i] & 0xF; volume != 0 {
+			level += sunsoft5BLevels[volume*2+1]
+		}
+	}
+	return level * sunsoft5BLevel
+}
+
+type sunsoft5BTone struct {
+	period,
+	counter uint16
+	high bool
+}
+
+func (t *sunsoft5BTone) clock() {
+	t.counter++
+	if t.counter >= t.period {
+		t.counter = 0
+		t.high = !t.high
+	}
+}
+
+type sunsoft5BEnvelope struct {
+	period,
+	counter uint16
+	shape,
+	step byte
+	attack,
+	holding bool
+}
+
+func (e *sunsoft5BEnvelope) writeShape(val byte) {
+	e.shape = val & 0xF
+	e.step = 0
+	e.counter = 0
+	e.attack = e.shape&4 != 0
+	e.holding = false
+}
+
+func (e *sunsoft5BEnvelope) clock() {
+	e.counter++
+	if e.counter < e.period {
+		return
+	}
+	e.counter = 0
+	if e.holding {
+		return
+	}
+	if e.step < 31 {
+		e.step++
+		return
+	}
+
+	// End of a ramp - the shape determines what happens next
+	continueBit, alternate, hold := e.shape&8 != 0, e.shape&2 != 0, e.shape&1 != 0
+	switch {
+	case !continueBit:
+		// Drop to 0 and stay there
+		e.holding = true
+		e.attack = false
+	case hold:
+		e.holding = true
+		if alternate {
+			e.attack = !e.attack
+		}
+	case alternate:
+		e.attack = !e.attack
+		e.step = 0
+	default:
+		e.step = 0
+	}
+}
+
+func (e *sunsoft5BEnvelope) level() byte {
+	if e.attack {
+		return e.step
+	}
+	return 31 - e.step
+}
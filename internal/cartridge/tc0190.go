@@ -0,0 +1,151 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_033
+// http://wiki.nesdev.com/w/index.php/INES_Mapper_048
+
+func newTC0190(info *RomInfo, prg, chr []byte, tc0690 bool) (*tc0190, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &tc0190{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		tc0690:    tc0690,
+		mirroring: info.Mirroring,
+	}, nil
+}
+
+type tc0190 struct {
+	prg,
+	chr,
+	ram []byte
+	// tc0690 is set for mapper 48, which moves the mirroring control to $E000, and adds an MMC3
+	// style scanline IRQ
+	tc0690    bool
+	mirroring Mirroring
+
+	prgBanks [2]byte
+	// chrBanks holds two 2KB banks at $0000, followed by four 1KB banks at $1000
+	chrBanks [6]byte
+
+	irq a12IRQ
+}
+
+func (t *tc0190) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return t.ram[addr-0x6000]
+
+	} else if addr >= 0xC000 {
+		// Last two banks are fixed
+		return t.prg[len(t.prg)-0x4000+int(addr&0x3FFF)]
+
+	} else if addr >= 0x8000 {
+		bank := t.prgBanks[(addr-0x8000)/0x2000]
+		return t.prg[(int(bank)*0x2000+int(addr&0x1FFF))%len(t.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped TC0190 address %#X", addr)
+	return 0
+}
+
+func (t *tc0190) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		t.ram[addr-0x6000] = val
+		return
+	}
+
+	switch addr & 0xE003 {
+	case 0x8000:
+		t.prgBanks[0] = val & 0x3F
+		if !t.tc0690 {
+			t.writeMirroring(val)
+		}
+	case 0x8001:
+		t.prgBanks[1] = val & 0x3F
+	case 0x8002, 0x8003:
+		t.chrBanks[addr&1] = val
+	case 0xA000, 0xA001, 0xA002, 0xA003:
+		t.chrBanks[2+addr&3] = val
+	default:
+		if t.tc0690 {
+			t.writeTC0690(addr, val)
+		} else {
+			logrus.Debugf("Write to unmapped TC0190 address %#X", addr)
+		}
+	}
+}
+
+func (t *tc0190) writeTC0690(addr uint16, val byte) {
+	switch addr & 0xE003 {
+	case 0xC000:
+		// The counter counts up from the written value, which is equivalent to the MMC3 counting
+		// down from its complement
+		t.irq.latch = val ^ 0xFF
+	case 0xC001:
+		t.irq.counter = 0
+		t.irq.reload = true
+	case 0xC002:
+		t.irq.enabled = true
+	case 0xC003:
+		t.irq.enabled = false
+		t.irq.pending = false
+	case 0xE000:
+		t.writeMirroring(val)
+	}
+}
+
+func (t *tc0190) writeMirroring(val byte) {
+	if val&0x40 != 0 {
+		t.mirroring = MirrorHorizontal
+	} else {
+		t.mirroring = MirrorVertical
+	}
+}
+
+func (t *tc0190) PPURead(addr uint16, vram []byte) byte {
+	t.irq.observe(addr)
+	if addr < 0x2000 {
+		return t.chr[t.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[t.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled TC0190 PPU memory read from address %#x", addr))
+}
+
+func (t *tc0190) PPUWrite(addr uint16, val byte, vram []byte) {
+	t.irq.observe(addr)
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		t.chr[t.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[t.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled TC0190 PPU memory write to address %#x", addr))
+	}
+}
+
+func (t *tc0190) chrAddr(addr uint16) int {
+	var bank int
+	if addr < 0x1000 {
+		bank = int(t.chrBanks[addr>>11])*2 + int(addr>>10)&1
+	} else {
+		bank = int(t.chrBanks[2+(addr-0x1000)>>10])
+	}
+	return (bank*0x400 + int(addr&0x3FF)) % len(t.chr)
+}
+
+func (t *tc0190) Step() {
+	t.irq.step()
+}
+
+func (t *tc0190) IRQ() bool {
+	return t.irq.pending
+}
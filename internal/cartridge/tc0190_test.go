@@ -0,0 +1,63 @@
+package cartridge
+
+import "testing"
+
+func TestTC0190Banking(t *testing.T) {
+	for _, tc0690 := range []bool{false, true} {
+		tc, err := newTC0190(&RomInfo{Mirroring: MirrorVertical}, testPRG(16), testCHR(0x40000, 0x400), tc0690)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.CPUWrite(0x8000, 0x40|3)
+		tc.CPUWrite(0x8001, 4)
+		tc.CPUWrite(0x8002, 0x10)
+		tc.CPUWrite(0x8003, 0x20)
+		tc.CPUWrite(0xA003, 0x45)
+		for addr, expected := range map[uint16]byte{0x8000: 3, 0xA000: 4, 0xC000: 14, 0xE000: 15} {
+			if bank := tc.CPURead(addr); bank != expected {
+				t.Errorf("TC0690 %v: expected bank %d at %#x, got %d", tc0690, expected, addr, bank)
+			}
+		}
+		// 2KB banks are numbered in 2KB units
+		for addr, expected := range map[uint16]byte{0x0000: 0x20, 0x0400: 0x21, 0x0800: 0x40, 0x1C00: 0x45} {
+			if bank := tc.PPURead(addr, nil); bank != expected {
+				t.Errorf("TC0690 %v: expected CHR bank %#x at %#x, got %#x", tc0690, expected, addr, bank)
+			}
+		}
+
+		// The TC0690 moves mirroring to $E000
+		expected := MirrorHorizontal
+		if tc0690 {
+			expected = MirrorVertical
+		}
+		if tc.mirroring != expected {
+			t.Errorf("TC0690 %v: expected mirroring %d, got %d", tc0690, expected, tc.mirroring)
+		}
+		tc.CPUWrite(0xE000, 0x40)
+		if tc0690 && tc.mirroring != MirrorHorizontal {
+			t.Errorf("TC0690 %v: expected horizontal mirroring, got %d", tc0690, tc.mirroring)
+		}
+	}
+}
+
+func TestTC0690IRQ(t *testing.T) {
+	tc, _ := newTC0190(&RomInfo{}, testPRG(4), make([]byte, 0x2000), true)
+	// The latch is written inverted, for an IRQ after 4 scanlines
+	tc.CPUWrite(0xC000, 0xFC)
+	tc.CPUWrite(0xC001, 0)
+	tc.CPUWrite(0xC002, 0)
+	for line := 0; line < 3; line++ {
+		testScanline(tc)
+		if tc.IRQ() {
+			t.Fatalf("unexpected IRQ after %d scanlines", line+1)
+		}
+	}
+	testScanline(tc)
+	if !tc.IRQ() {
+		t.Fatal("expected IRQ after 4 scanlines")
+	}
+	tc.CPUWrite(0xC003, 0)
+	if tc.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+}
@@ -0,0 +1,84 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/UxROM
+
+func newUxROM(info *RomInfo, prg, chr []byte) (*uxrom, error) {
+	if len(prg) < 0x4000 || len(prg)%0x4000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 16KB, got %d B", len(prg))
+	}
+	return &uxrom{
+		prg:          prg,
+		chr:          chr,
+		ram:          newPRGRAM(info),
+		mirroring:    info.Mirroring,
+		busConflicts: hasBusConflicts(info),
+	}, nil
+}
+
+type uxrom struct {
+	prg,
+	chr,
+	ram []byte
+	mirroring    Mirroring
+	busConflicts bool
+
+	prgBank byte
+}
+
+func (u *uxrom) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		return u.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 && addr < 0xC000 {
+		return u.prg[(int(u.prgBank)*0x4000+int(addr&0x3FFF))%len(u.prg)]
+
+	} else if addr >= 0xC000 {
+		// Last bank is fixed
+		return u.prg[len(u.prg)-0x4000+int(addr&0x3FFF)]
+
+	}
+	logrus.Debugf("Read from unmapped UxROM address %#X", addr)
+	return 0
+}
+
+func (u *uxrom) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		u.ram[addr-0x6000] = val
+
+	} else if addr >= 0x8000 {
+		if u.busConflicts {
+			val &= u.CPURead(addr)
+		}
+		u.prgBank = val
+
+	} else {
+		logrus.Debugf("Write to unmapped UxROM address %#X", addr)
+	}
+}
+
+func (u *uxrom) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return u.chr[addr]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[u.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled UxROM PPU memory read from address %#x", addr))
+}
+
+func (u *uxrom) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Boards generally use CHR RAM
+		u.chr[addr] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[u.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled UxROM PPU memory write to address %#x", addr))
+	}
+}
@@ -0,0 +1,246 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/VRC2_and_VRC4
+
+// vrcWiring describes which CPU address lines are connected to a Konami VRC's A0 and A1 register
+// select inputs. Where a submapper doesn't distinguish between boards, both boards' lines are
+// combined, which works since games only write to addresses valid for their own board.
+type vrcWiring struct {
+	a0, a1 uint16
+	// vrc2 is set for boards with a VRC2, which lacks the IRQ counter and PRG swap mode
+	vrc2 bool
+	// chrShift is set for VRC2a, which ignores the low bit of CHR bank numbers
+	chrShift bool
+}
+
+// vrc4Wiring returns the wiring for a VRC2/VRC4 mapper and submapper.
+// http://wiki.nesdev.com/w/index.php/NES_2.0_submappers#021.2C_023.2C_025:_Konami_VRC2.2FVRC4
+func vrc4Wiring(mapper uint16, submapper byte) (vrcWiring, error) {
+	switch mapper {
+	case 21:
+		switch submapper {
+		case 1:
+			// VRC4a
+			return vrcWiring{a0: 0x02, a1: 0x04}, nil
+		case 2:
+			// VRC4c
+			return vrcWiring{a0: 0x40, a1: 0x80}, nil
+		}
+		return vrcWiring{a0: 0x42, a1: 0x84}, nil
+	case 22:
+		// VRC2a
+		return vrcWiring{a0: 0x02, a1: 0x01, vrc2: true, chrShift: true}, nil
+	case 23:
+		switch submapper {
+		case 1:
+			// VRC4f
+			return vrcWiring{a0: 0x01, a1: 0x02}, nil
+		case 2:
+			// VRC4e
+			return vrcWiring{a0: 0x04, a1: 0x08}, nil
+		case 3:
+			// VRC2b
+			return vrcWiring{a0: 0x01, a1: 0x02, vrc2: true}, nil
+		}
+		return vrcWiring{a0: 0x05, a1: 0x0A}, nil
+	case 25:
+		switch submapper {
+		case 1:
+			// VRC4b
+			return vrcWiring{a0: 0x02, a1: 0x01}, nil
+		case 2:
+			// VRC4d
+			return vrcWiring{a0: 0x08, a1: 0x04}, nil
+		case 3:
+			// VRC2c
+			return vrcWiring{a0: 0x02, a1: 0x01, vrc2: true}, nil
+		}
+		return vrcWiring{a0: 0x0A, a1: 0x05}, nil
+	}
+	return vrcWiring{}, fmt.Errorf("mapper %d is not a VRC2 or VRC4", mapper)
+}
+
+// reg translates a CPU address to a register address with A0 and A1 in the low bits.
+func (w vrcWiring) reg(addr uint16) uint16 {
+	reg := addr & 0xF000
+	if addr&w.a0 != 0 {
+		reg |= 1
+	}
+	if addr&w.a1 != 0 {
+		reg |= 2
+	}
+	return reg
+}
+
+func newVRC4(info *RomInfo, prg, chr []byte) (*vrc4, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	wiring, err := vrc4Wiring(info.Mapper, info.Submapper)
+	if err != nil {
+		return nil, err
+	}
+	return &vrc4{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		wiring:    wiring,
+		mirroring: info.Mirroring,
+		// VRC2 boards have no RAM enable
+		ramEnabled: wiring.vrc2,
+	}, nil
+}
+
+type vrc4 struct {
+	prg,
+	chr,
+	ram []byte
+	wiring vrcWiring
+
+	prgBanks   [2]byte
+	prgSwap    bool
+	chrBanks   [8]uint16
+	mirroring  Mirroring
+	ramEnabled bool
+
+	irq vrcIRQ
+}
+
+func (v *vrc4) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if !v.ramEnabled {
+			return 0
+		}
+		return v.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 {
+		return v.prg[v.prgAddr(addr)]
+
+	}
+	logrus.Debugf("Read from unmapped VRC4 address %#X", addr)
+	return 0
+}
+
+func (v *vrc4) prgAddr(addr uint16) int {
+	secondLast := len(v.prg)/0x2000 - 2
+	var bank int
+	switch addr & 0xE000 {
+	case 0x8000:
+		bank = int(v.prgBanks[0])
+		if v.prgSwap {
+			bank = secondLast
+		}
+	case 0xA000:
+		bank = int(v.prgBanks[1])
+	case 0xC000:
+		bank = secondLast
+		if v.prgSwap {
+			bank = int(v.prgBanks[0])
+		}
+	case 0xE000:
+		bank = secondLast + 1
+	}
+	return (bank*0x2000 + int(addr&0x1FFF)) % len(v.prg)
+}
+
+func (v *vrc4) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if v.ramEnabled {
+			v.ram[addr-0x6000] = val
+		}
+		return
+	}
+	if addr < 0x8000 {
+		logrus.Debugf("Write to unmapped VRC4 address %#X", addr)
+		return
+	}
+
+	reg := v.wiring.reg(addr)
+	switch {
+	case reg <= 0x8003:
+		v.prgBanks[0] = val & 0x1F
+
+	case reg == 0x9000, reg == 0x9001:
+		if v.wiring.vrc2 {
+			v.mirroring = vrc6Mirroring[val&1]
+		} else {
+			v.mirroring = vrc6Mirroring[val&3]
+		}
+
+	case reg == 0x9002, reg == 0x9003:
+		if !v.wiring.vrc2 {
+			v.ramEnabled = val&1 == 1
+			v.prgSwap = val&2 != 0
+		}
+
+	case reg <= 0xA003:
+		v.prgBanks[1] = val & 0x1F
+
+	case reg <= 0xE003:
+		// Each 1KB bank is written as a low and high nibble
+		bank := (reg-0xB000)>>12*2 + (reg&2)>>1
+		if reg&1 == 0 {
+			v.chrBanks[bank] = v.chrBanks[bank]&0x1F0 | uint16(val&0xF)
+		} else {
+			v.chrBanks[bank] = v.chrBanks[bank]&0xF | uint16(val&0x1F)<<4
+		}
+
+	case v.wiring.vrc2:
+		// No IRQ counter
+
+	case reg == 0xF000:
+		v.irq.writeLatch(v.irq.latch&0xF0 | val&0xF)
+
+	case reg == 0xF001:
+		v.irq.writeLatch(v.irq.latch&0xF | val<<4)
+
+	case reg == 0xF002:
+		v.irq.writeControl(val)
+
+	case reg == 0xF003:
+		v.irq.acknowledge()
+	}
+}
+
+func (v *vrc4) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return v.chr[v.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[v.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled VRC4 PPU memory read from address %#x", addr))
+}
+
+func (v *vrc4) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		v.chr[v.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[v.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled VRC4 PPU memory write to address %#x", addr))
+	}
+}
+
+func (v *vrc4) chrAddr(addr uint16) int {
+	bank := int(v.chrBanks[addr>>10])
+	if v.wiring.chrShift {
+		bank >>= 1
+	}
+	return (bank*0x400 + int(addr&0x3FF)) % len(v.chr)
+}
+
+func (v *vrc4) Step() {
+	v.irq.step()
+}
+
+func (v *vrc4) IRQ() bool {
+	return v.irq.pending
+}
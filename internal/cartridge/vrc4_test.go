@@ -0,0 +1,115 @@
+package cartridge
+
+import "testing"
+
+func TestVRC4Wiring(t *testing.T) {
+	tests := []struct {
+		name      string
+		mapper    uint16
+		submapper byte
+		// Addresses of registers $x001, $x002 and $x003 on the board
+		reg1, reg2, reg3 uint16
+	}{
+		{"VRC4a", 21, 1, 0x02, 0x04, 0x06},
+		{"VRC4c", 21, 2, 0x40, 0x80, 0xC0},
+		{"VRC4a/VRC4c", 21, 0, 0x40, 0x04, 0x06},
+		{"VRC4f", 23, 1, 0x01, 0x02, 0x03},
+		{"VRC4e", 23, 2, 0x04, 0x08, 0x0C},
+		{"VRC4b", 25, 1, 0x02, 0x01, 0x03},
+		{"VRC4d", 25, 2, 0x08, 0x04, 0x0C},
+		{"VRC4b/VRC4d", 25, 0, 0x08, 0x01, 0x03},
+	}
+	for _, test := range tests {
+		v, err := newVRC4(&RomInfo{Mapper: test.mapper, Submapper: test.submapper}, testPRG(16), testCHR(0x40000, 0x400))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// CHR bank 3 is $C002 (low nibble) and $C003 (high nibble)
+		v.CPUWrite(0xC000|test.reg2, 0x4)
+		v.CPUWrite(0xC000|test.reg3, 0x2)
+		if bank := v.PPURead(0x0C00, nil); bank != 0x24 {
+			t.Errorf("%s: expected CHR bank $24 at $0C00, got %#x", test.name, bank)
+		}
+		// CHR bank 2 is $C000 and $C001
+		v.CPUWrite(0xC000, 0x3)
+		v.CPUWrite(0xC000|test.reg1, 0x1)
+		if bank := v.PPURead(0x0800, nil); bank != 0x13 {
+			t.Errorf("%s: expected CHR bank $13 at $0800, got %#x", test.name, bank)
+		}
+
+		// PRG swap mode moves the $8000 bank to $C000
+		v.CPUWrite(0x8000, 3)
+		v.CPUWrite(0xA000, 4)
+		v.CPUWrite(0x9000|test.reg2, 0x02)
+		if bank := v.CPURead(0xC000); bank != 3 {
+			t.Errorf("%s: expected bank 3 at $C000 in swap mode, got %d", test.name, bank)
+		}
+		if bank := v.CPURead(0x8000); bank != 14 {
+			t.Errorf("%s: expected second last bank at $8000 in swap mode, got %d", test.name, bank)
+		}
+		if bank := v.CPURead(0xA000); bank != 4 {
+			t.Errorf("%s: expected bank 4 at $A000, got %d", test.name, bank)
+		}
+		if bank := v.CPURead(0xE000); bank != 15 {
+			t.Errorf("%s: expected last bank at $E000, got %d", test.name, bank)
+		}
+	}
+}
+
+func TestVRC2(t *testing.T) {
+	// VRC2a ignores the low bit of CHR banks
+	v, err := newVRC4(&RomInfo{Mapper: 22}, testPRG(16), testCHR(0x20000, 0x400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.CPUWrite(0xB000, 0x6)
+	if bank := v.PPURead(0x0000, nil); bank != 3 {
+		t.Errorf("expected VRC2a CHR bank 6 to select bank 3, got %d", bank)
+	}
+	// VRC2 has no RAM enable or PRG swap mode
+	v.CPUWrite(0x8000, 3)
+	v.CPUWrite(0x9002, 0x02)
+	if bank := v.CPURead(0x8000); bank != 3 {
+		t.Errorf("expected bank 3 at $8000, got %d", bank)
+	}
+	v.CPUWrite(0x6000, 0x42)
+	if val := v.CPURead(0x6000); val != 0x42 {
+		t.Errorf("expected PRG RAM to be enabled, got %#x", val)
+	}
+
+	// VRC2b shares mapper 23 with VRC4e/f, and is selected by submapper 3
+	v, _ = newVRC4(&RomInfo{Mapper: 23, Submapper: 3}, testPRG(16), testCHR(0x20000, 0x400))
+	v.CPUWrite(0xB000, 0x6)
+	if bank := v.PPURead(0x0000, nil); bank != 6 {
+		t.Errorf("expected VRC2b CHR bank 6, got %d", bank)
+	}
+	v.CPUWrite(0xF002, 0x06)
+	v.Step()
+	if v.IRQ() {
+		t.Error("expected VRC2 to have no IRQ counter")
+	}
+}
+
+func TestVRC4IRQ(t *testing.T) {
+	v, _ := newVRC4(&RomInfo{Mapper: 21, Submapper: 1}, testPRG(4), make([]byte, 0x2000))
+	// The latch is written as two nibbles
+	v.CPUWrite(0xF000, 0xD)
+	v.CPUWrite(0xF002, 0xF)
+	if v.irq.latch != 0xFD {
+		t.Fatalf("expected latch $FD, got %#x", v.irq.latch)
+	}
+	v.CPUWrite(0xF004, 0x06)
+	for i := 0; i < 3; i++ {
+		if v.IRQ() {
+			t.Fatalf("unexpected IRQ after %d cycles", i)
+		}
+		v.Step()
+	}
+	if !v.IRQ() {
+		t.Fatal("expected IRQ after counter overflowed")
+	}
+	v.CPUWrite(0xF006, 0)
+	if v.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+}
@@ -0,0 +1,150 @@
+package cartridge
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/VRC6
+
+func newVRC6(info *RomInfo, prg, chr []byte, swapped bool) (*vrc6, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	return &vrc6{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		swapped:   swapped,
+		mirroring: info.Mirroring,
+	}, nil
+}
+
+// vrc6Mirroring maps the mirroring bits of $B003, in the standard PPU banking mode.
+var vrc6Mirroring = [4]Mirroring{MirrorVertical, MirrorHorizontal, MirrorSingleScreenA, MirrorSingleScreenB}
+
+type vrc6 struct {
+	prg,
+	chr,
+	ram []byte
+	// swapped is set for mapper 26, which has the A0 and A1 address lines swapped
+	swapped bool
+
+	prgBank16,
+	prgBank8 byte
+	chrBanks   [8]byte
+	ramEnabled bool
+	mirroring  Mirroring
+
+	irq   vrcIRQ
+	audio vrc6Audio
+}
+
+func (v *vrc6) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if !v.ramEnabled {
+			return 0
+		}
+		return v.ram[addr-0x6000]
+
+	} else if addr >= 0x8000 && addr < 0xC000 {
+		return v.prg[(int(v.prgBank16)*0x4000+int(addr&0x3FFF))%len(v.prg)]
+
+	} else if addr >= 0xC000 && addr < 0xE000 {
+		return v.prg[(int(v.prgBank8)*0x2000+int(addr&0x1FFF))%len(v.prg)]
+
+	} else if addr >= 0xE000 {
+		// Last bank is fixed
+		return v.prg[len(v.prg)-0x2000+int(addr&0x1FFF)]
+
+	}
+	logrus.Debugf("Read from unmapped VRC6 address %#X", addr)
+	return 0
+}
+
+func (v *vrc6) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if v.ramEnabled {
+			v.ram[addr-0x6000] = val
+		}
+		return
+	}
+	if addr < 0x8000 {
+		logrus.Debugf("Write to unmapped VRC6 address %#X", addr)
+		return
+	}
+
+	reg := addr & 0xF003
+	if v.swapped {
+		reg = reg&0xF000 | (reg&1)<<1 | (reg&2)>>1
+	}
+	switch reg {
+	case 0x8000, 0x8001, 0x8002, 0x8003:
+		v.prgBank16 = val & 0xF
+
+	case 0x9000, 0x9001, 0x9002, 0x9003, 0xA000, 0xA001, 0xA002, 0xB000, 0xB001, 0xB002:
+		v.audio.write(reg, val)
+
+	case 0xB003:
+		// TODO: Handle the alternate PPU banking modes
+		v.mirroring = vrc6Mirroring[(val>>2)&3]
+		v.ramEnabled = val>>7 == 1
+
+	case 0xC000, 0xC001, 0xC002, 0xC003:
+		v.prgBank8 = val & 0x1F
+
+	case 0xD000, 0xD001, 0xD002, 0xD003:
+		v.chrBanks[reg&3] = val
+
+	case 0xE000, 0xE001, 0xE002, 0xE003:
+		v.chrBanks[4+reg&3] = val
+
+	case 0xF000:
+		v.irq.writeLatch(val)
+
+	case 0xF001:
+		v.irq.writeControl(val)
+
+	case 0xF002:
+		v.irq.acknowledge()
+	}
+}
+
+func (v *vrc6) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return v.chr[v.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[v.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled VRC6 PPU memory read from address %#x", addr))
+}
+
+func (v *vrc6) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		logrus.Warnf("Write to read-only CHR address %#X in cartridge", addr)
+		v.chr[v.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[v.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled VRC6 PPU memory write to address %#x", addr))
+	}
+}
+
+func (v *vrc6) chrAddr(addr uint16) int {
+	bank := v.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(v.chr)
+}
+
+func (v *vrc6) Step() {
+	v.irq.step()
+	v.audio.step()
+}
+
+func (v *vrc6) IRQ() bool {
+	return v.irq.pending
+}
+
+func (v *vrc6) AudioOutput() float64 {
+	return v.audio.output()
+}
@@ -0,0 +1,161 @@
+package cartridge
+
+// vrc6Audio implements the VRC6 expansion sound: two pulse channels with selectable duty and a
+// sawtooth channel.
+// http://wiki.nesdev.com/w/index.php/VRC6_audio
+type vrc6Audio struct {
+	pulse1,
+	pulse2 vrc6Pulse
+	saw vrc6Saw
+
+	halt bool
+	// shift reduces each channel's period, speeding up all of the oscillators
+	shift uint
+}
+
+// write handles a write to a sound register, given as $9000-$9003, $A000-$A002 or $B000-$B002.
+func (v *vrc6Audio) write(reg uint16, val byte) {
+	switch reg {
+	case 0x9000, 0x9001, 0x9002:
+		v.pulse1.write(reg&3, val)
+	case 0x9003:
+		v.halt = val&1 == 1
+		if val&4 != 0 {
+			v.shift = 8
+		} else if val&2 != 0 {
+			v.shift = 4
+		} else {
+			v.shift = 0
+		}
+	case 0xA000, 0xA001, 0xA002:
+		v.pulse2.write(reg&3, val)
+	case 0xB000, 0xB001, 0xB002:
+		v.saw.write(reg&3, val)
+	}
+}
+
+// step advances the channels by a CPU cycle.
+func (v *vrc6Audio) step() {
+	if v.halt {
+		return
+	}
+	v.pulse1.clockTimer(v.shift)
+	v.pulse2.clockTimer(v.shift)
+	v.saw.clockTimer(v.shift)
+}
+
+func (v *vrc6Audio) output() float64 {
+	level := v.pulse1.output() + v.pulse2.output() + v.saw.output()
+	return float64(level) * apuPulseStep
+}
+
+// vrc6Timer is the 12-bit divider that clocks each VRC6 channel.
+type vrc6Timer struct {
+	period,
+	value uint16
+	enabled bool
+}
+
+// clock counts the timer down, and returns true when it reloads.
+func (t *vrc6Timer) clock(shift uint) bool {
+	if !t.enabled {
+		return false
+	}
+	if t.value == 0 {
+		t.value = t.period >> shift
+		return true
+	}
+	t.value--
+	return false
+}
+
+type vrc6Pulse struct {
+	timer vrc6Timer
+	volume,
+	duty,
+	step byte
+	// ignoreDuty outputs the volume constantly, for use as a DAC
+	ignoreDuty bool
+}
+
+func (p *vrc6Pulse) write(reg uint16, val byte) {
+	switch reg {
+	case 0:
+		p.ignoreDuty = val>>7 == 1
+		p.duty = (val >> 4) & 7
+		p.volume = val & 0xF
+	case 1:
+		p.timer.period = p.timer.period&0xF00 | uint16(val)
+	case 2:
+		p.timer.period = p.timer.period&0xFF | uint16(val&0xF)<<8
+		p.timer.enabled = val>>7 == 1
+		if !p.timer.enabled {
+			p.step = 15
+		}
+	}
+}
+
+func (p *vrc6Pulse) clockTimer(shift uint) {
+	if p.timer.clock(shift) {
+		if p.step == 0 {
+			p.step = 15
+		} else {
+			p.step--
+		}
+	}
+}
+
+func (p *vrc6Pulse) output() int {
+	if !p.timer.enabled {
+		return 0
+	}
+	if p.ignoreDuty || p.step <= p.duty {
+		return int(p.volume)
+	}
+	return 0
+}
+
+type vrc6Saw struct {
+	timer vrc6Timer
+	rate,
+	accumulator,
+	step byte
+}
+
+func (s *vrc6Saw) write(reg uint16, val byte) {
+	switch reg {
+	case 0:
+		s.rate = val & 0x3F
+	case 1:
+		s.timer.period = s.timer.period&0xF00 | uint16(val)
+	case 2:
+		s.timer.period = s.timer.period&0xFF | uint16(val&0xF)<<8
+		s.timer.enabled = val>>7 == 1
+		if !s.timer.enabled {
+			s.accumulator = 0
+			s.step = 0
+		}
+	}
+}
+
+func (s *vrc6Saw) clockTimer(shift uint) {
+	if !s.timer.clock(shift) {
+		return
+	}
+	// The rate is added on every other clock, and the accumulator resets after the seventh add
+	s.step++
+	if s.step == 14 {
+		s.step = 0
+		s.accumulator = 0
+	} else if s.step%2 == 0 {
+		s.accumulator += s.rate
+	}
+}
+
+func (s *vrc6Saw) output() int {
+	if !s.timer.enabled {
+		return 0
+	}
+	// Only the top 5 bits are output
+	return int(s.accumulator >> 3)
+}
@@ -0,0 +1,105 @@
+package cartridge
+
+import "testing"
+
+// testPRG builds a PRG image where the first byte of each 8KB bank holds its bank number.
+func testPRG(banks int) []byte {
+	prg := make([]byte, banks*0x2000)
+	for i := 0; i < banks; i++ {
+		prg[i*0x2000] = byte(i)
+	}
+	return prg
+}
+
+func TestVRC6Banking(t *testing.T) {
+	for _, swapped := range []bool{false, true} {
+		v, err := newVRC6(&RomInfo{}, testPRG(16), make([]byte, 0x2000), swapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.CPUWrite(0x8000, 3)
+		v.CPUWrite(0xC000, 5)
+		if bank := v.CPURead(0x8000); bank != 6 {
+			t.Errorf("expected 16KB bank 3 to map 8KB bank 6 at $8000, got %d", bank)
+		}
+		if bank := v.CPURead(0xC000); bank != 5 {
+			t.Errorf("expected bank 5 at $C000, got %d", bank)
+		}
+		if bank := v.CPURead(0xE000); bank != 15 {
+			t.Errorf("expected last bank fixed at $E000, got %d", bank)
+		}
+
+		// $B003 enables PRG RAM, and is $B003 regardless of the swapped address lines
+		v.CPUWrite(0x6000, 0x42)
+		if val := v.CPURead(0x6000); val != 0 {
+			t.Errorf("expected PRG RAM to be disabled, got %#x", val)
+		}
+		v.CPUWrite(0xB003, 0x80)
+		v.CPUWrite(0x6000, 0x42)
+		if val := v.CPURead(0x6000); val != 0x42 {
+			t.Errorf("expected PRG RAM to be enabled, got %#x", val)
+		}
+	}
+}
+
+func TestVRC6IRQ(t *testing.T) {
+	v, _ := newVRC6(&RomInfo{}, testPRG(4), make([]byte, 0x2000), false)
+	// Cycle mode, reloading from $FD - the IRQ fires when the counter overflows from $FF
+	v.CPUWrite(0xF000, 0xFD)
+	v.CPUWrite(0xF001, 0x06)
+	for i := 0; i < 3; i++ {
+		if v.IRQ() {
+			t.Fatalf("unexpected IRQ after %d cycles", i)
+		}
+		v.Step()
+	}
+	if !v.IRQ() {
+		t.Fatal("expected IRQ after counter overflowed")
+	}
+	v.CPUWrite(0xF002, 0)
+	if v.IRQ() {
+		t.Error("expected IRQ to be acknowledged")
+	}
+	// Acknowledging without the A bit set disables the counter
+	for i := 0; i < 0x200; i++ {
+		v.Step()
+	}
+	if v.IRQ() {
+		t.Error("expected IRQ counter to be disabled after acknowledge")
+	}
+}
+
+func TestVRC6Audio(t *testing.T) {
+	v, _ := newVRC6(&RomInfo{}, testPRG(4), make([]byte, 0x2000), false)
+	// Pulse 1: duty 8/16, volume 15, period 15 (16 cycles per step)
+	v.CPUWrite(0x9000, 0x7F)
+	v.CPUWrite(0x9001, 0x0F)
+	v.CPUWrite(0x9002, 0x80)
+
+	var high int
+	for i := 0; i < 16*16; i++ {
+		v.Step()
+		if v.AudioOutput() > 0 {
+			high++
+		}
+	}
+	if high != 8*16 {
+		t.Errorf("expected pulse to be high for half of the cycle, got %d/%d", high, 16*16)
+	}
+
+	// Sawtooth only, with rate 42 - the accumulator reaches 6*42 before resetting
+	v.CPUWrite(0x9002, 0x00)
+	v.CPUWrite(0xB000, 42)
+	v.CPUWrite(0xB001, 0x00)
+	v.CPUWrite(0xB002, 0x80)
+	var peak float64
+	for i := 0; i < 14*2; i++ {
+		v.Step()
+		if out := v.AudioOutput(); out > peak {
+			peak = out
+		}
+	}
+	if expected := float64(6*42>>3) * apuPulseStep; peak != expected {
+		t.Errorf("expected sawtooth peak %f, got %f", expected, peak)
+	}
+}
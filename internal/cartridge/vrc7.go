@@ -0,0 +1,156 @@
+package cartridge
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// http://wiki.nesdev.com/w/index.php/VRC7
+
+func newVRC7(info *RomInfo, prg, chr []byte) (*vrc7, error) {
+	if len(prg) < 0x4000 || len(prg)%0x2000 != 0 {
+		return nil, fmt.Errorf("expected PRG ROM to be a multiple of 8KB, got %d B", len(prg))
+	}
+	// The second register of each pair is selected by A4 on VRC7a, and A3 on VRC7b
+	var a0 uint16
+	switch info.Submapper {
+	case 1:
+		a0 = 0x08
+	case 2:
+		a0 = 0x10
+	default:
+		a0 = 0x18
+	}
+	return &vrc7{
+		prg:       prg,
+		chr:       chr,
+		ram:       newPRGRAM(info),
+		a0:        a0,
+		mirroring: info.Mirroring,
+		audio:     newVRC7Audio(),
+	}, nil
+}
+
+type vrc7 struct {
+	prg,
+	chr,
+	ram []byte
+	// a0 holds the address lines which select the second register of each pair
+	a0 uint16
+
+	prgBanks   [3]byte
+	chrBanks   [8]byte
+	mirroring  Mirroring
+	ramEnabled bool
+
+	irq   vrcIRQ
+	audio *vrc7Audio
+}
+
+func (v *vrc7) CPURead(addr uint16) byte {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if !v.ramEnabled {
+			return 0
+		}
+		return v.ram[addr-0x6000]
+
+	} else if addr >= 0xE000 {
+		// Last bank is fixed
+		return v.prg[len(v.prg)-0x2000+int(addr&0x1FFF)]
+
+	} else if addr >= 0x8000 {
+		bank := v.prgBanks[(addr-0x8000)/0x2000]
+		return v.prg[(int(bank)*0x2000+int(addr&0x1FFF))%len(v.prg)]
+
+	}
+	logrus.Debugf("Read from unmapped VRC7 address %#X", addr)
+	return 0
+}
+
+func (v *vrc7) CPUWrite(addr uint16, val byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		if v.ramEnabled {
+			v.ram[addr-0x6000] = val
+		}
+		return
+	}
+	if addr < 0x8000 {
+		logrus.Debugf("Write to unmapped VRC7 address %#X", addr)
+		return
+	}
+
+	// Audio ports are decoded separately, from A4 and A5
+	switch addr & 0xF030 {
+	case 0x9010:
+		v.audio.writeAddr(val)
+		return
+	case 0x9030:
+		v.audio.writeData(val)
+		return
+	}
+
+	reg := addr & 0xF000
+	if addr&v.a0 != 0 {
+		reg |= 1
+	}
+	switch reg {
+	case 0x8000:
+		v.prgBanks[0] = val & 0x3F
+	case 0x8001:
+		v.prgBanks[1] = val & 0x3F
+	case 0x9000:
+		v.prgBanks[2] = val & 0x3F
+	case 0xA000, 0xA001, 0xB000, 0xB001, 0xC000, 0xC001, 0xD000, 0xD001:
+		v.chrBanks[(reg-0xA000)>>12*2+reg&1] = val
+	case 0xE000:
+		v.mirroring = vrc6Mirroring[val&3]
+		v.audio.silenced = val&0x40 != 0
+		v.ramEnabled = val>>7 == 1
+	case 0xE001:
+		v.irq.writeLatch(val)
+	case 0xF000:
+		v.irq.writeControl(val)
+	case 0xF001:
+		v.irq.acknowledge()
+	}
+}
+
+func (v *vrc7) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return v.chr[v.chrAddr(addr)]
+	}
+	if addr >= 0x2000 && addr <= 0x3EFF {
+		return vram[v.mirroring.NametableAddr(addr)]
+	}
+	panic(fmt.Sprintf("unhandled VRC7 PPU memory read from address %#x", addr))
+}
+
+func (v *vrc7) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		// Some boards use CHR RAM
+		v.chr[v.chrAddr(addr)] = val
+	} else if addr >= 0x2000 && addr <= 0x3EFF {
+		vram[v.mirroring.NametableAddr(addr)] = val
+	} else {
+		panic(fmt.Sprintf("unhandled VRC7 PPU memory write to address %#x", addr))
+	}
+}
+
+func (v *vrc7) chrAddr(addr uint16) int {
+	bank := v.chrBanks[addr>>10]
+	return (int(bank)*0x400 + int(addr&0x3FF)) % len(v.chr)
+}
+
+func (v *vrc7) Step() {
+	v.irq.step()
+	v.audio.step()
+}
+
+func (v *vrc7) IRQ() bool {
+	return v.irq.pending
+}
+
+func (v *vrc7) AudioOutput() float64 {
+	return v.audio.output()
+}
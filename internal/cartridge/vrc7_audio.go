@@ -0,0 +1,336 @@
+package cartridge
+
+import "math"
+
+// vrc7Audio implements the VRC7 expansion sound, a cut down Yamaha YM2413 (OPLL) with six FM
+// channels. Each channel has a modulator and carrier operator, configured by one of 15 built-in
+// instrument patches or a single custom patch.
+// http://wiki.nesdev.com/w/index.php/VRC7_audio
+type vrc7Audio struct {
+	reg      byte
+	custom   [8]byte
+	channels [6]vrc7Channel
+	// silenced is set by the sound reset bit of $E000
+	silenced bool
+
+	cycles int
+	// lfo counts samples for the tremolo and vibrato oscillators
+	lfo    int
+	sample float64
+}
+
+const (
+	// vrc7SampleCycles is the number of CPU cycles between output samples
+	vrc7SampleCycles = 36
+	vrc7SampleRate   = 3579545.0 / 72
+	// vrc7Level scales a full amplitude channel relative to the APU. Without a hardware
+	// measurement of the cartridge's mix to calibrate against, a carrier at full output matches
+	// the 15 volume steps of a full volume APU pulse channel.
+	vrc7Level = apuPulseStep * 15
+	// vrc7MaxAttenuation is the range of the envelope generator, in dB
+	vrc7MaxAttenuation = 48
+	// vrc7ModulationDepth is the carrier phase offset (in radians) at the modulator's peak output
+	vrc7ModulationDepth = 4 * math.Pi
+)
+
+// vrc7Patches holds the built-in instruments. Instrument 0 is the custom patch.
+// http://wiki.nesdev.com/w/index.php/VRC7_audio#Internal_patch_set
+var vrc7Patches = [16][8]byte{
+	{},
+	{0x03, 0x21, 0x05, 0x06, 0xE8, 0x81, 0x42, 0x27}, // Buzzy bell
+	{0x13, 0x41, 0x14, 0x0D, 0xD8, 0xF6, 0x23, 0x12}, // Guitar
+	{0x11, 0x11, 0x08, 0x08, 0xFA, 0xB2, 0x20, 0x12}, // Wurly
+	{0x31, 0x61, 0x0C, 0x07, 0xA8, 0x64, 0x61, 0x27}, // Flute
+	{0x32, 0x21, 0x1E, 0x06, 0xE1, 0x76, 0x01, 0x28}, // Clarinet
+	{0x02, 0x01, 0x06, 0x00, 0xA3, 0xE2, 0xF4, 0xF4}, // Synth
+	{0x21, 0x61, 0x1D, 0x07, 0x82, 0x81, 0x11, 0x07}, // Trumpet
+	{0x23, 0x21, 0x22, 0x17, 0xA2, 0x72, 0x01, 0x17}, // Organ
+	{0x35, 0x11, 0x25, 0x00, 0x40, 0x73, 0x72, 0x01}, // Bells
+	{0xB5, 0x01, 0x0F, 0x0F, 0xA8, 0xA5, 0x51, 0x02}, // Vibes
+	{0x17, 0xC1, 0x24, 0x07, 0xF8, 0xF8, 0x22, 0x12}, // Vibraphone
+	{0x71, 0x23, 0x11, 0x06, 0x65, 0x74, 0x18, 0x16}, // Tutti
+	{0x01, 0x02, 0xD3, 0x05, 0xC9, 0x95, 0x03, 0x02}, // Fretless
+	{0x61, 0x63, 0x0C, 0x00, 0x94, 0xC0, 0x33, 0xF6}, // Synth bass
+	{0x21, 0x72, 0x0D, 0x00, 0xC1, 0xD5, 0x56, 0x06}, // Sweep
+}
+
+// vrc7Multipliers holds the frequency multiplier for each MULT value
+var vrc7Multipliers = [16]float64{0.5, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 10, 12, 12, 15, 15}
+
+// vrc7KeyScaleLevels holds the attenuation in dB applied by key scaling, for the top 4 bits of the
+// F-number at the highest octave
+var vrc7KeyScaleLevels = [16]float64{
+	0, 18, 24, 27.75, 30, 32.25, 33.75, 35.25, 36, 37.5, 38.25, 39, 39.75, 40.5, 41.25, 42,
+}
+
+// vrc7FeedbackLevels holds the modulator self-modulation depth in radians for each FB value
+var vrc7FeedbackLevels = [8]float64{
+	0, math.Pi / 16, math.Pi / 8, math.Pi / 4, math.Pi / 2, math.Pi, 2 * math.Pi, 4 * math.Pi,
+}
+
+func newVRC7Audio() *vrc7Audio {
+	v := &vrc7Audio{}
+	for i := range v.channels {
+		v.channels[i].modulator.attenuation = vrc7MaxAttenuation
+		v.channels[i].carrier.attenuation = vrc7MaxAttenuation
+	}
+	return v
+}
+
+func (v *vrc7Audio) writeAddr(val byte) {
+	v.reg = val
+}
+
+func (v *vrc7Audio) writeData(val byte) {
+	switch {
+	case v.reg < 0x08:
+		v.custom[v.reg] = val
+	case v.reg >= 0x10 && v.reg <= 0x15:
+		c := &v.channels[v.reg&0xF]
+		c.fnum = c.fnum&0x100 | uint16(val)
+	case v.reg >= 0x20 && v.reg <= 0x25:
+		c := &v.channels[v.reg&0xF]
+		c.fnum = c.fnum&0xFF | uint16(val&1)<<8
+		c.block = val >> 1 & 7
+		c.sustain = val&0x20 != 0
+		key := val&0x10 != 0
+		if key && !c.key {
+			c.modulator.keyOn()
+			c.carrier.keyOn()
+		} else if !key && c.key {
+			c.modulator.state = vrc7Release
+			c.carrier.state = vrc7Release
+		}
+		c.key = key
+	case v.reg >= 0x30 && v.reg <= 0x35:
+		c := &v.channels[v.reg&0xF]
+		c.instrument = val >> 4
+		c.volume = val & 0xF
+	}
+}
+
+// step advances the chip by a CPU cycle.
+func (v *vrc7Audio) step() {
+	if v.silenced {
+		return
+	}
+	v.cycles++
+	if v.cycles < vrc7SampleCycles {
+		return
+	}
+	v.cycles = 0
+
+	// Tremolo reaches 4.8dB at 3.7Hz, and vibrato sways by 7 cents at 6.4Hz
+	v.lfo++
+	t := float64(v.lfo) / vrc7SampleRate
+	am := 4.8 * (1 - math.Cos(2*math.Pi*3.7*t)) / 2
+	pm := math.Pow(2, 7.0/1200*math.Sin(2*math.Pi*6.4*t))
+
+	v.sample = 0
+	for i := range v.channels {
+		c := &v.channels[i]
+		patch := &vrc7Patches[c.instrument]
+		if c.instrument == 0 {
+			patch = &v.custom
+		}
+		v.sample += c.clock(patch, am, pm)
+	}
+}
+
+func (v *vrc7Audio) output() float64 {
+	if v.silenced {
+		return 0
+	}
+	return v.sample * vrc7Level
+}
+
+type vrc7Channel struct {
+	fnum uint16
+	block,
+	instrument,
+	volume byte
+	sustain,
+	key bool
+
+	modulator,
+	carrier vrc7Operator
+	// feedback holds the last two modulator outputs, which are averaged for self-modulation
+	feedback [2]float64
+}
+
+// clock generates the channel's next sample.
+func (c *vrc7Channel) clock(patch *[8]byte, am, pm float64) float64 {
+	modPatch := vrc7OperatorPatch{
+		flags:   patch[0],
+		ksl:     patch[2] >> 6,
+		attack:  patch[4] >> 4,
+		decay:   patch[4] & 0xF,
+		sustain: patch[6] >> 4,
+		release: patch[6] & 0xF,
+		rectify: patch[3]&0x08 != 0,
+	}
+	carPatch := vrc7OperatorPatch{
+		flags:   patch[1],
+		ksl:     patch[3] >> 6,
+		attack:  patch[5] >> 4,
+		decay:   patch[5] & 0xF,
+		sustain: patch[7] >> 4,
+		release: patch[7] & 0xF,
+		rectify: patch[3]&0x10 != 0,
+	}
+
+	feedback := (c.feedback[0] + c.feedback[1]) / 2 * vrc7FeedbackLevels[patch[3]&7]
+	mod := c.modulator.clock(c, &modPatch, float64(patch[2]&0x3F)*0.75, feedback, am, pm)
+	c.feedback[1] = c.feedback[0]
+	c.feedback[0] = mod
+
+	return c.carrier.clock(c, &carPatch, float64(c.volume)*3, mod*vrc7ModulationDepth, am, pm)
+}
+
+// keyCode combines the block and the top bit of the F-number, for scaling envelope rates.
+func (c *vrc7Channel) keyCode() int {
+	return int(c.block)<<1 | int(c.fnum>>8)
+}
+
+type vrc7EnvelopeState byte
+
+const (
+	vrc7Attack vrc7EnvelopeState = iota
+	vrc7Decay
+	vrc7Sustain
+	vrc7Release
+)
+
+// vrc7OperatorPatch holds one operator's half of an instrument patch.
+type vrc7OperatorPatch struct {
+	// flags holds the AM, vibrato, EG type and KSR bits in 7-4, and MULT in 3-0
+	flags,
+	ksl,
+	attack,
+	decay,
+	sustain,
+	release byte
+	rectify bool
+}
+
+type vrc7Operator struct {
+	// phase is the position within the waveform, from 0 to 1
+	phase float64
+	// attenuation is the envelope level in dB
+	attenuation float64
+	state       vrc7EnvelopeState
+}
+
+func (o *vrc7Operator) keyOn() {
+	o.phase = 0
+	o.state = vrc7Attack
+}
+
+// clock advances the operator by a sample and returns its output, from -1 to 1. The level is the
+// fixed attenuation in dB from the total level or volume, and modulation is the phase offset in
+// radians.
+func (o *vrc7Operator) clock(c *vrc7Channel, p *vrc7OperatorPatch, level, modulation, am, pm float64) float64 {
+	freq := float64(c.fnum) * math.Pow(2, float64(c.block)) / (1 << 19) * vrc7Multipliers[p.flags&0xF]
+	if p.flags&0x40 != 0 {
+		freq *= pm
+	}
+	o.phase += freq
+	o.phase -= math.Floor(o.phase)
+
+	o.clockEnvelope(c, p)
+	if o.attenuation >= vrc7MaxAttenuation {
+		return 0
+	}
+
+	level += o.attenuation
+	if p.ksl != 0 {
+		ksl := vrc7KeyScaleLevels[c.fnum>>5] - 6*float64(7-c.block)
+		if ksl > 0 {
+			level += ksl / float64(int(1)<<(3-p.ksl))
+		}
+	}
+	if p.flags&0x80 != 0 {
+		level += am
+	}
+
+	out := math.Sin(2*math.Pi*o.phase + modulation)
+	if p.rectify && out < 0 {
+		out = 0
+	}
+	return out * math.Pow(10, -level/20)
+}
+
+// clockEnvelope advances the envelope generator by a sample.
+func (o *vrc7Operator) clockEnvelope(c *vrc7Channel, p *vrc7OperatorPatch) {
+	keyScale := c.keyCode()
+	if p.flags&0x10 == 0 {
+		keyScale >>= 2
+	}
+	sustained := p.flags&0x20 != 0
+
+	switch o.state {
+	case vrc7Attack:
+		if p.attack == 15 {
+			o.attenuation = 0
+		} else {
+			// The attack is exponential, approaching 0dB quickly and then slowing down
+			o.attenuation -= o.attenuation * vrc7AttackStep(p.attack, keyScale)
+		}
+		if o.attenuation < 0.1 {
+			o.attenuation = 0
+			o.state = vrc7Decay
+		}
+	case vrc7Decay:
+		o.attenuation += vrc7DecayStep(p.decay, keyScale)
+		if sustainLevel := float64(p.sustain) * 3; o.attenuation >= sustainLevel {
+			o.attenuation = sustainLevel
+			o.state = vrc7Sustain
+		}
+	case vrc7Sustain:
+		// Sustained tones hold until released, and percussive tones continue to fade
+		if !sustained {
+			o.attenuation += vrc7DecayStep(p.release, keyScale)
+		}
+	case vrc7Release:
+		switch {
+		case c.sustain:
+			o.attenuation += vrc7DecayStep(5, keyScale)
+		case sustained:
+			o.attenuation += vrc7DecayStep(p.release, keyScale)
+		default:
+			o.attenuation += vrc7DecayStep(7, keyScale)
+		}
+	}
+	if o.attenuation > vrc7MaxAttenuation {
+		o.attenuation = vrc7MaxAttenuation
+	}
+}
+
+// vrc7Rate combines a 4-bit envelope rate with key scaling. The resulting rate doubles the speed
+// of the envelope every 4 steps.
+func vrc7Rate(rate byte, keyScale int) float64 {
+	r := int(rate)*4 + keyScale
+	if r > 63 {
+		r = 63
+	}
+	return math.Pow(2, float64(r-4)/4)
+}
+
+// vrc7AttackStep returns the fraction of the attenuation removed each sample during the attack.
+// At rate 1 the attack takes roughly 2.8 seconds.
+func vrc7AttackStep(rate byte, keyScale int) float64 {
+	if rate == 0 {
+		return 0
+	}
+	step := math.Log(vrc7MaxAttenuation/0.1) / (2.83 * vrc7SampleRate) * vrc7Rate(rate, keyScale)
+	return math.Min(step, 1)
+}
+
+// vrc7DecayStep returns the attenuation in dB added each sample during a decay or release. At
+// rate 1 the envelope takes roughly 20 seconds to decay to silence.
+func vrc7DecayStep(rate byte, keyScale int) float64 {
+	if rate == 0 {
+		return 0
+	}
+	return vrc7MaxAttenuation / (19.6 * vrc7SampleRate) * vrc7Rate(rate, keyScale)
+}
@@ -0,0 +1,60 @@
+package cartridge
+
+import "testing"
+
+func TestVRC7Banking(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		submapper byte
+		// a0 is the address line which selects the second register of each pair
+		a0 uint16
+	}{
+		{"VRC7b", 1, 0x08},
+		{"VRC7a", 2, 0x10},
+	} {
+		v, err := newVRC7(&RomInfo{Mapper: 85, Submapper: test.submapper}, testPRG(16), testCHR(0x40000, 0x400))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.CPUWrite(0x8000, 3)
+		v.CPUWrite(0x8000|test.a0, 4)
+		v.CPUWrite(0x9000, 5)
+		for addr, expected := range map[uint16]byte{0x8000: 3, 0xA000: 4, 0xC000: 5, 0xE000: 15} {
+			if bank := v.CPURead(addr); bank != expected {
+				t.Errorf("%s: expected bank %d at %#x, got %d", test.name, expected, addr, bank)
+			}
+		}
+
+		v.CPUWrite(0xB000, 0x20)
+		v.CPUWrite(0xB000|test.a0, 0x21)
+		if bank := v.PPURead(0x0800, nil); bank != 0x20 {
+			t.Errorf("%s: expected CHR bank $20 at $0800, got %#x", test.name, bank)
+		}
+		if bank := v.PPURead(0x0C00, nil); bank != 0x21 {
+			t.Errorf("%s: expected CHR bank $21 at $0C00, got %#x", test.name, bank)
+		}
+
+		// $E000 selects mirroring and enables PRG RAM
+		v.CPUWrite(0xE000, 0x81)
+		if v.mirroring != MirrorHorizontal {
+			t.Errorf("%s: expected horizontal mirroring, got %d", test.name, v.mirroring)
+		}
+		v.CPUWrite(0x6000, 0x42)
+		if val := v.CPURead(0x6000); val != 0x42 {
+			t.Errorf("%s: expected PRG RAM to be enabled, got %#x", test.name, val)
+		}
+
+		// IRQ latch, control and acknowledge
+		v.CPUWrite(0xE000|test.a0, 0xFE)
+		v.CPUWrite(0xF000, 0x06)
+		v.Step()
+		v.Step()
+		if !v.IRQ() {
+			t.Errorf("%s: expected IRQ after counter overflowed", test.name)
+		}
+		v.CPUWrite(0xF000|test.a0, 0)
+		if v.IRQ() {
+			t.Errorf("%s: expected IRQ to be acknowledged", test.name)
+		}
+	}
+}
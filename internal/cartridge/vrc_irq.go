@@ -0,0 +1,59 @@
+package cartridge
+
+// vrcIRQ implements the IRQ counter shared by Konami's VRC4, VRC6 and VRC7 mappers. The counter
+// is clocked either every CPU cycle, or once per scanline via a prescaler that approximates 341
+// PPU cycles per scanline.
+// http://wiki.nesdev.com/w/index.php/VRC_IRQ
+type vrcIRQ struct {
+	latch,
+	counter byte
+	prescaler int
+	enabled,
+	enableAfterAck,
+	cycleMode,
+	pending bool
+}
+
+const vrcPrescalerPeriod = 341
+
+func (v *vrcIRQ) writeLatch(val byte) {
+	v.latch = val
+}
+
+func (v *vrcIRQ) writeControl(val byte) {
+	v.enableAfterAck = val&1 == 1
+	v.enabled = (val>>1)&1 == 1
+	v.cycleMode = (val>>2)&1 == 1
+	v.pending = false
+	if v.enabled {
+		v.counter = v.latch
+		v.prescaler = vrcPrescalerPeriod
+	}
+}
+
+func (v *vrcIRQ) acknowledge() {
+	v.pending = false
+	v.enabled = v.enableAfterAck
+}
+
+// step advances the counter by a CPU cycle.
+func (v *vrcIRQ) step() {
+	if !v.enabled {
+		return
+	}
+	if !v.cycleMode {
+		// Scanline mode - there are 341/3 CPU cycles per scanline
+		v.prescaler -= 3
+		if v.prescaler > 0 {
+			return
+		}
+		v.prescaler += vrcPrescalerPeriod
+	}
+
+	if v.counter == 0xFF {
+		v.counter = v.latch
+		v.pending = true
+	} else {
+		v.counter++
+	}
+}
@@ -117,6 +117,10 @@ func (c *CPU) Reset() {
 	c.regs.StackPtr = 0xFF
 	c.pc = c.read16(resetVector)
 	c.halted = false
+	// Abandon any instruction or interrupt that was in progress
+	c.opQueue.clear()
+	c.shouldNMI = false
+	c.shouldIRQ = false
 
 	if c.config.trace {
 		logrus.Debugf("CPU: Reset to PC %#x", c.pc)
@@ -29,3 +29,10 @@ func (o *opQueue) pop() func() {
 	o.start %= maxOps
 	return fn
 }
+
+func (o *opQueue) clear() {
+	for i := range o.ops {
+		o.ops[i] = nil
+	}
+	o.start, o.end = 0, 0
+}
@@ -37,6 +37,9 @@ func (p *PPU) Step() {
 		if visibleLine && visibleCycle {
 			p.renderPixel()
 		}
+		if renderLine && p.lineCycle == 321 && p.fetchObserver != nil {
+			p.fetchObserver.SpriteFetch(false)
+		}
 		if renderLine && fetchCycle {
 			p.tileData <<= 4
 			switch p.lineCycle % 8 {
@@ -52,6 +55,10 @@ func (p *PPU) Step() {
 				p.storeTileData()
 			}
 		}
+		if renderLine && (p.lineCycle == 337 || p.lineCycle == 339) {
+			// Unused nametable fetches, which the MMC5 uses to detect the start of scanlines
+			p.read8(0x2000 | (p.vramAddr & 0x0FFF))
+		}
 
 		if renderLine {
 			if fetchCycle && p.lineCycle%8 == 0 {
@@ -78,6 +85,14 @@ func (p *PPU) Step() {
 				p.spriteCount = 0
 			}
 		}
+		// Sprite patterns are fetched for the next line, including dummy fetches for unused
+		// slots, which mappers such as the MMC3 count scanlines with
+		if renderLine && p.lineCycle >= 257 && p.lineCycle <= 320 {
+			if p.lineCycle == 257 && p.fetchObserver != nil {
+				p.fetchObserver.SpriteFetch(true)
+			}
+			p.fetchSpriteData()
+		}
 	}
 
 	// Move to the next cell/scanline as needed
@@ -205,30 +220,55 @@ func (p *PPU) renderPixel() {
 	p.backBuffer[y][x] = p.ReadPalette(uint16(color)) % 64
 }
 
-func (p *PPU) fetchSpritePattern(i, row int) uint32 {
-	tile := p.oam[i*4+1]
-	attributes := p.oam[i*4+2]
-	var address uint16
+// spritePatternAddress returns the address of the low pattern byte for a row of a sprite.
+func (p *PPU) spritePatternAddress(tile, attributes byte, row int) uint16 {
 	if !p.regs.TallSprites {
 		if attributes&0x80 == 0x80 {
 			row = 7 - row
 		}
-		address = p.regs.SpritePatternTableAddress + uint16(tile)*16 + uint16(row)
-	} else {
-		if attributes&0x80 == 0x80 {
-			row = 15 - row
-		}
-		table := tile & 1
-		tile &= 0xFE
-		if row > 7 {
-			tile++
-			row -= 8
+		return p.regs.SpritePatternTableAddress + uint16(tile)*16 + uint16(row)
+	}
+	if attributes&0x80 == 0x80 {
+		row = 15 - row
+	}
+	table := tile & 1
+	tile &= 0xFE
+	if row > 7 {
+		tile++
+		row -= 8
+	}
+	return 0x1000*uint16(table) + uint16(tile)*16 + uint16(row)
+}
+
+// fetchSpriteData performs the memory access for the current cycle of the sprite fetch period,
+// where each of the 8 sprite slots takes 8 cycles: two garbage nametable fetches, then the low
+// and high pattern bytes.
+func (p *PPU) fetchSpriteData() {
+	slot := (p.lineCycle - 257) / 8
+	switch (p.lineCycle - 257) % 8 {
+	case 0, 2:
+		p.read8(0x2000 | (p.vramAddr & 0x0FFF))
+	case 4:
+		p.spriteLowByte = p.read8(p.spriteFetchAddress(slot))
+	case 6:
+		highTileByte := p.read8(p.spriteFetchAddress(slot) + 8)
+		if slot < p.spriteCount {
+			p.spritePatterns[slot] = spritePattern(p.spriteAttributes[slot], p.spriteLowByte, highTileByte)
 		}
-		address = 0x1000*uint16(table) + uint16(tile)*16 + uint16(row)
 	}
+}
+
+// spriteFetchAddress returns the pattern address fetched for a sprite slot. Unused slots fetch
+// tile $FF.
+func (p *PPU) spriteFetchAddress(slot int) uint16 {
+	if slot < p.spriteCount {
+		return p.spriteAddresses[slot]
+	}
+	return p.spritePatternAddress(0xFF, 0, 0)
+}
+
+func spritePattern(attributes, lowTileByte, highTileByte byte) uint32 {
 	a := (attributes & 3) << 2
-	lowTileByte := p.read8(address)
-	highTileByte := p.read8(address + 8)
 	var data uint32
 	for i := 0; i < 8; i++ {
 		var p1, p2 byte
@@ -266,7 +306,8 @@ func (p *PPU) evaluateSprites() {
 			continue
 		}
 		if count < 8 {
-			p.spritePatterns[count] = p.fetchSpritePattern(i, row)
+			p.spriteAddresses[count] = p.spritePatternAddress(p.oam[i*4+1], a, row)
+			p.spriteAttributes[count] = a
 			p.spritePositions[count] = x
 			p.spritePriorities[count] = (a >> 5) & 1
 			p.spriteIndexes[count] = byte(i)
@@ -25,6 +25,9 @@ func NewPPU(mem Memory, opts ...Option) *PPU {
 		oam:      make([]byte, oamSize),
 		scanLine: 261,
 	}
+	if observer, ok := mem.(FetchObserver); ok {
+		ppu.fetchObserver = observer
+	}
 	return ppu
 }
 
@@ -34,14 +37,24 @@ type Memory interface {
 	NMI()
 }
 
+// FetchObserver is optionally implemented by Memory, to be told when the PPU moves between
+// fetching sprite and background data while rendering. This allows cartridges to map separate
+// pattern data for sprites and backgrounds.
+type FetchObserver interface {
+	// SpriteFetch is called with true when sprite fetches start at cycle 257 of a rendered
+	// scanline, and false when background fetches resume at cycle 321.
+	SpriteFetch(active bool)
+}
+
 type PPU struct {
 	config *config
 	cycles uint64
 
-	mem         Memory
-	vram        []byte
-	oam         []byte
-	paletteData [32]byte
+	mem           Memory
+	fetchObserver FetchObserver
+	vram          []byte
+	oam           []byte
+	paletteData   [32]byte
 
 	regs    Registers
 	portBus byte
@@ -75,6 +88,10 @@ type PPU struct {
 	spritePositions  [8]byte
 	spritePriorities [8]byte
 	spriteIndexes    [8]byte
+	// Evaluated sprites for the next line, which are fetched during cycles 257-320
+	spriteAddresses  [8]uint16
+	spriteAttributes [8]byte
+	spriteLowByte    byte
 
 	// Display buffers
 	backBuffer  [DisplayHeight][DisplayWidth]byte
@@ -0,0 +1,98 @@
+package gophernes
+
+import (
+	"github.com/tomnz/gophernes/internal/cartridge"
+)
+
+// Cartridge is a cartridge board, which is mapped into the CPU address space at $4020-$FFFF and the
+// PPU address space at $0000-$3EFF. Boards can also implement Clocked, Interrupter, ExpansionAudio,
+// RenderObserver and Saver, to connect to more of the console.
+type Cartridge = cartridge.Cartridge
+
+// Clocked is implemented by cartridges with hardware that is driven by the CPU clock, such as
+// cycle-based IRQ counters.
+type Clocked = cartridge.Clocked
+
+// Interrupter is implemented by cartridges with hardware that can interrupt the CPU.
+type Interrupter = cartridge.Interrupter
+
+// ExpansionAudio is implemented by cartridges with expansion audio hardware.
+type ExpansionAudio = cartridge.Audio
+
+// RenderObserver is implemented by cartridges that track the PPU's rendering, such as to map
+// separate pattern data for sprites and backgrounds.
+type RenderObserver = cartridge.RenderObserver
+
+// Saver is implemented by cartridges with save storage that isn't mapped into memory, such as
+// serial EEPROMs.
+type Saver = cartridge.Saver
+
+// RomInfo describes a cartridge, as parsed from an iNES or NES 2.0 header.
+type RomInfo = cartridge.RomInfo
+
+// Mirroring is the arrangement of the PPU nametables at $2000-$2FFF.
+type Mirroring = cartridge.Mirroring
+
+const (
+	MirrorHorizontal    = cartridge.MirrorHorizontal
+	MirrorVertical      = cartridge.MirrorVertical
+	MirrorSingleScreenA = cartridge.MirrorSingleScreenA
+	MirrorSingleScreenB = cartridge.MirrorSingleScreenB
+	MirrorFourScreen    = cartridge.MirrorFourScreen
+)
+
+// Timing is the CPU/PPU timing region that a cartridge targets.
+type Timing = cartridge.Timing
+
+const (
+	TimingNTSC  = cartridge.TimingNTSC
+	TimingPAL   = cartridge.TimingPAL
+	TimingMulti = cartridge.TimingMulti
+	TimingDendy = cartridge.TimingDendy
+)
+
+// ConsoleType is the type of console that a cartridge targets.
+type ConsoleType = cartridge.ConsoleType
+
+const (
+	ConsoleNES          = cartridge.ConsoleNES
+	ConsoleVsSystem     = cartridge.ConsoleVsSystem
+	ConsolePlaychoice10 = cartridge.ConsolePlaychoice10
+	ConsoleExtended     = cartridge.ConsoleExtended
+)
+
+// Banks maps a window of the CPU or PPU address space onto equally sized banks of ROM or RAM, for
+// implementing bank switching.
+type Banks = cartridge.Banks
+
+// NewBanks creates a window of size bytes starting at base, which maps data in banks of bankSize
+// bytes. Every slot initially selects the first bank.
+func NewBanks(data []byte, base uint16, size, bankSize int) *Banks {
+	return cartridge.NewBanks(data, base, size, bankSize)
+}
+
+// NewPRGBanks creates a window over the CPU's $8000-$FFFF, which maps PRG ROM in banks of bankSize
+// bytes.
+func NewPRGBanks(prg []byte, bankSize int) *Banks {
+	return cartridge.NewPRGBanks(prg, bankSize)
+}
+
+// NewCHRBanks creates a window over the PPU's $0000-$1FFF, which maps CHR ROM or RAM in banks of
+// bankSize bytes.
+func NewCHRBanks(chr []byte, bankSize int) *Banks {
+	return cartridge.NewCHRBanks(chr, bankSize)
+}
+
+// MapperConstructor creates a cartridge from its ROM contents. chr holds the CHR ROM, or zeroed CHR
+// RAM if the cartridge has none.
+type MapperConstructor = cartridge.Constructor
+
+// RegisterMapper adds a constructor for a mapper and submapper, which is used in place of any
+// built-in mapper when loading an iNES or NES 2.0 ROM. The constructor for submapper 0 is also used
+// for any other submapper without its own, so it covers iNES ROMs, which have no submapper.
+// Registering the same mapper and submapper again replaces the earlier constructor.
+//
+// It's intended to be called from the init function of a package implementing the mapper.
+func RegisterMapper(id uint16, submapper byte, constructor MapperConstructor) {
+	cartridge.Register(id, submapper, constructor)
+}
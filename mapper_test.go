@@ -0,0 +1,187 @@
+package gophernes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tomnz/gophernes/internal/cartridge"
+)
+
+// testMapperROM builds an iNES image for the given mapper, with 32KB of PRG ROM and 8KB of CHR
+// ROM. The program is placed at $E000 and the IRQ handler at $E100, assuming the last 8KB of PRG
+// ROM is mapped there on power up.
+func testMapperROM(mapper byte, program, handler []byte) []byte {
+	prg := make([]byte, 0x8000)
+	copy(prg[0x6000:], program)
+	copy(prg[0x6100:], handler)
+	// Reset and IRQ vectors
+	copy(prg[0x7FFC:], []byte{0x00, 0xE0, 0x00, 0xE1})
+
+	image := testINESImage(inesHeader{PrgLen: 2, ChrLen: 1, Flags6: mapper << 4}, nil, 0, chrLenMultiplier)
+	return append(image[:16], append(prg, image[16:]...)...)
+}
+
+// testRenderingSetup is a program prefix which disables the APU frame IRQ, and enables rendering
+// with sprites at $1000.
+var testRenderingSetup = []byte{
+	0x78,       // SEI
+	0xA9, 0x40, // LDA #$40
+	0x8D, 0x17, 0x40, // STA $4017
+	0xA9, 0x08, // LDA #$08
+	0x8D, 0x00, 0x20, // STA $2000
+	0xA9, 0x18, // LDA #$18
+	0x8D, 0x01, 0x20, // STA $2001
+}
+
+func TestMMC3ScanlineIRQ(t *testing.T) {
+	program := append(testRenderingSetup[:len(testRenderingSetup):len(testRenderingSetup)],
+		0xA9, 0x09, // LDA #$09 - IRQ every 10 scanlines
+		0x8D, 0x00, 0xC0, // STA $C000
+		0x8D, 0x01, 0xC0, // STA $C001
+		0x8D, 0x01, 0xE0, // STA $E001
+		0x58,             // CLI
+		0x4C, 0x1C, 0xE0, // JMP $E01C
+	)
+	handler := []byte{
+		0xE6, 0x10, // INC $10
+		0x8D, 0x00, 0xE0, // STA $E000
+		0x8D, 0x01, 0xE0, // STA $E001
+		0x40, // RTI
+	}
+	console, err := NewConsole(bytes.NewReader(testMapperROM(4, program, handler)), nil, nil, nil, WithRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	console.RunFrames(1)
+	start := console.ram[0x10]
+	console.RunFrames(0)
+	// 240 visible lines and the pre-render line clock the counter
+	if irqs := console.ram[0x10] - start; irqs < 23 || irqs > 25 {
+		t.Errorf("expected 24 IRQs in a frame, got %d", irqs)
+	}
+}
+
+func TestMMC5ScanlineIRQ(t *testing.T) {
+	program := append(testRenderingSetup[:len(testRenderingSetup):len(testRenderingSetup)],
+		0xA9, 0x64, // LDA #100
+		0x8D, 0x03, 0x52, // STA $5203
+		0xA9, 0x80, // LDA #$80
+		0x8D, 0x04, 0x52, // STA $5204
+		0x58,             // CLI
+		0x4C, 0x1B, 0xE0, // JMP $E01B
+	)
+	handler := []byte{
+		0xE6, 0x10, // INC $10
+		0xAD, 0x04, 0x52, // LDA $5204
+		0x40, // RTI
+	}
+	console, err := NewConsole(bytes.NewReader(testMapperROM(5, program, handler)), nil, nil, nil, WithRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	console.RunFrames(1)
+	start := console.ram[0x10]
+	console.RunFrames(2)
+	if irqs := console.ram[0x10] - start; irqs != 3 {
+		t.Errorf("expected an IRQ for each of 3 frames, got %d", irqs)
+	}
+}
+
+// testBoard is a minimal external board, built on the public cartridge types, which switches the
+// 8KB PRG bank at $8000 on writes there.
+type testBoard struct {
+	prg,
+	chr *Banks
+	mirroring Mirroring
+}
+
+func (b *testBoard) CPURead(addr uint16) byte {
+	if b.prg.Contains(addr) {
+		return b.prg.Read(addr)
+	}
+	return 0
+}
+
+func (b *testBoard) CPUWrite(addr uint16, val byte) {
+	if b.prg.Contains(addr) {
+		b.prg.Select(0, int(val))
+	}
+}
+
+func (b *testBoard) PPURead(addr uint16, vram []byte) byte {
+	if addr < 0x2000 {
+		return b.chr.Read(addr)
+	}
+	return vram[b.mirroring.NametableAddr(addr)]
+}
+
+func (b *testBoard) PPUWrite(addr uint16, val byte, vram []byte) {
+	if addr < 0x2000 {
+		b.chr.Write(addr, val)
+	} else {
+		vram[b.mirroring.NametableAddr(addr)] = val
+	}
+}
+
+func TestRegisterMapper(t *testing.T) {
+	var info *RomInfo
+	// The registry is process-wide, so register through the internal package to restore it after
+	defer cartridge.Register(0xF00, 0, func(i *RomInfo, prg, chr []byte) (Cartridge, error) {
+		info = i
+		board := &testBoard{
+			prg:       NewPRGBanks(prg, 0x2000),
+			chr:       NewCHRBanks(chr, 0x2000),
+			mirroring: i.Mirroring,
+		}
+		board.prg.Select(3, -1)
+		return board, nil
+	})()
+
+	prg := make([]byte, 0x8000)
+	prg[0x4000] = 0x5A
+	copy(prg[0x6000:], []byte{
+		0xA9, 0x02, // LDA #$02
+		0x8D, 0x00, 0x80, // STA $8000
+		0xAD, 0x00, 0x80, // LDA $8000
+		0x85, 0x00, // STA $00
+		0x4C, 0x0A, 0xE0, // JMP $E00A
+	})
+	copy(prg[0x7FFC:], []byte{0x00, 0xE0})
+	// NES 2.0 header for mapper $F00 submapper 3, which falls back to the submapper 0 board
+	image := testINESImage(inesHeader{PrgLen: 2, ChrLen: 1, Flags6: 0x01, Flags7: 0x08, Flags8: 0x3F}, nil, 0, chrLenMultiplier)
+	image = append(image[:16], append(prg, image[16:]...)...)
+
+	console, err := NewConsole(bytes.NewReader(image), nil, nil, nil, WithRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.Mapper != 0xF00 || info.Submapper != 3 || info.Mirroring != MirrorVertical {
+		t.Fatalf("expected registered board to be constructed for mapper $F00 submapper 3, got %+v", info)
+	}
+	console.RunFrames(1)
+	if actual := console.ram[0]; actual != 0x5A {
+		t.Errorf("expected program to read $5A from the switched bank, got %#x", actual)
+	}
+}
+
+func TestRegisterMapperRestore(t *testing.T) {
+	// Overrides NROM for the duration of the test
+	restore := cartridge.Register(0, 0, func(*RomInfo, []byte, []byte) (Cartridge, error) {
+		return &testBoard{}, nil
+	})
+	cart, err := loadINES(bytes.NewReader(testMapperROM(0, nil, nil)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cart.(*testBoard); !ok {
+		t.Errorf("expected the registered board, got %T", cart)
+	}
+
+	restore()
+	if cart, err = loadINES(bytes.NewReader(testMapperROM(0, nil, nil)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cart.(*testBoard); ok {
+		t.Error("expected the built-in board after restoring the registry")
+	}
+}
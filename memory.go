@@ -21,14 +21,15 @@ func (c *Console) CPURead(addr uint16) byte {
 	} else if addr >= 0x4000 && addr < 0x4020 {
 		// Memory-mapped registers
 		switch addr & 0x1F {
-		case 0x15:
-			return c.apu.ReadReg(0x15)
+		case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0xA, 0xB, 0xC, 0xE, 0xF, 0x10, 0x11, 0x12, 0x13, 0x15:
+			// APU
+			return c.apu.ReadReg(byte(addr&0x1F), byte(addr>>8))
 
 		case 0x16:
-			return 0
+			return c.ports[Port1].read(byte(addr >> 8))
 
 		case 0x17:
-			return 0x03
+			return c.ports[Port2].read(byte(addr >> 8))
 
 		default:
 			logrus.Infof("Read from unhandled IO addr: %#X", addr)
@@ -50,15 +51,20 @@ func (c *Console) CPUWrite(addr uint16, val byte) {
 
 	} else if addr >= 0x2000 && addr < 0x4000 {
 		c.ppu.WriteReg(byte(addr&0x7), val)
+		if c.renderObserver != nil {
+			c.renderObserver.PPURegisterWrite(byte(addr&0x7), val)
+		}
 
 	} else if addr >= 0x4000 && addr < 0x4020 {
 		switch addr & 0x1F {
-		case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0xA, 0xB, 0xC, 0xE, 0xF, 0x10, 0x11, 0x12, 0x13, 0x15:
+		case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0xA, 0xB, 0xC, 0xE, 0xF, 0x10, 0x11, 0x12, 0x13, 0x15, 0x17:
 			// APU
 			c.apu.WriteReg(byte(addr&0x1F), val)
 
-		case 0x16, 0x17:
-			// Controllers
+		case 0x16:
+			// Controllers - both ports share the strobe line
+			c.ports[Port1].strobe(val&1 == 1)
+			c.ports[Port2].strobe(val&1 == 1)
 
 		case 0x14:
 			// OAM DMA
@@ -135,3 +141,9 @@ func (p *ppuMemory) Read(addr uint16, vram []byte) byte {
 func (p *ppuMemory) Write(addr uint16, val byte, vram []byte) {
 	p.PPUWrite(addr, val, vram)
 }
+
+func (p *ppuMemory) SpriteFetch(active bool) {
+	if p.renderObserver != nil {
+		p.renderObserver.SpriteFetch(active)
+	}
+}
@@ -0,0 +1,371 @@
+package gophernes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tomnz/gophernes/internal/cartridge"
+)
+
+// http://wiki.nesdev.com/w/index.php/NSF
+// http://wiki.nesdev.com/w/index.php/NSF2
+// http://wiki.nesdev.com/w/index.php/NSFe
+
+var (
+	nsfMagic  = [5]byte{'N', 'E', 'S', 'M', 0x1a}
+	nsfeMagic = [4]byte{'N', 'S', 'F', 'E'}
+)
+
+// defaultNSFSpeed is the standard NTSC PLAY period (in microseconds), used if a file doesn't
+// specify one.
+const defaultNSFSpeed = 16639
+
+type nsfHeader struct {
+	Magic        [5]byte
+	Version      byte
+	TotalSongs   byte
+	StartingSong byte
+	LoadAddress,
+	InitAddress,
+	PlayAddress uint16
+	SongName,
+	Artist,
+	Copyright [32]byte
+	SpeedNTSC  uint16
+	Bankswitch [8]byte
+	SpeedPAL   uint16
+	Region,
+	Expansion byte
+	// NSF2 fields
+	Flags byte
+	// DataLength is the 24-bit length of the program data, if metadata follows it
+	DataLength [3]byte
+}
+
+// NSFExpansion is a set of flags indicating which expansion audio chips an NSF file uses.
+type NSFExpansion byte
+
+const (
+	NSFExpansionVRC6      NSFExpansion = cartridge.NSFExpansionVRC6
+	NSFExpansionVRC7      NSFExpansion = cartridge.NSFExpansionVRC7
+	NSFExpansionFDS       NSFExpansion = cartridge.NSFExpansionFDS
+	NSFExpansionMMC5      NSFExpansion = cartridge.NSFExpansionMMC5
+	NSFExpansionN163      NSFExpansion = cartridge.NSFExpansionN163
+	NSFExpansionSunsoft5B NSFExpansion = cartridge.NSFExpansionSunsoft5B
+)
+
+// supportedNSFExpansion holds the expansion audio chips that can be played.
+const supportedNSFExpansion = NSFExpansionVRC6 | NSFExpansionVRC7 | NSFExpansionFDS | NSFExpansionMMC5 |
+	NSFExpansionN163 | NSFExpansionSunsoft5B
+
+// NSFInfo describes the contents of an NSF file.
+type NSFInfo struct {
+	Title,
+	Artist,
+	Copyright,
+	Ripper string
+	Expansion NSFExpansion
+	// Tracks lists every track in the file, in the order they are stored
+	Tracks []NSFTrack
+	// Playlist holds the (zero-based) tracks to play, in order - this may skip or repeat tracks
+	Playlist []int
+}
+
+// NSFTrack describes a single track in an NSF file.
+type NSFTrack struct {
+	Name string
+	// Duration is the authored length of the track before fading out, or 0 if unknown
+	Duration time.Duration
+	// Fade is the length of the fade out after the track's duration
+	Fade time.Duration
+}
+
+type nsfFile struct {
+	cartridge     *cartridge.NSF
+	info          *NSFInfo
+	startingTrack int
+}
+
+func loadNSF(file io.Reader) (*nsfFile, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) >= len(nsfeMagic) && bytes.Equal(data[:len(nsfeMagic)], nsfeMagic[:]) {
+		return loadNSFe(data[len(nsfeMagic):])
+	}
+
+	header := nsfHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	data = data[binary.Size(header):]
+	if header.Magic != nsfMagic {
+		return nil, errors.New("does not appear to be an NSF file: invalid header")
+	}
+
+	meta := &nsfMetadata{
+		auth: []string{
+			nsfString(header.SongName[:]),
+			nsfString(header.Artist[:]),
+			nsfString(header.Copyright[:]),
+		},
+	}
+	// NSF2 files may append NSFe metadata chunks after the program data
+	dataLength := int(header.DataLength[0]) | int(header.DataLength[1])<<8 | int(header.DataLength[2])<<16
+	if header.Version >= 2 && dataLength != 0 {
+		if dataLength > len(data) {
+			return nil, fmt.Errorf("NSF2 data length %#x exceeds file size", dataLength)
+		}
+		if err := readNSFeChunks(data[dataLength:], meta.parseChunk); err != nil {
+			return nil, err
+		}
+		data = data[:dataLength]
+	}
+
+	return newNSFFile(data, nsfInit{
+		tracks:        header.TotalSongs,
+		startingTrack: int(header.StartingSong) - 1,
+		loadAddress:   header.LoadAddress,
+		initAddress:   header.InitAddress,
+		playAddress:   header.PlayAddress,
+		bankswitch:    header.Bankswitch,
+		speed:         header.SpeedNTSC,
+		expansion:     header.Expansion,
+	}, meta)
+}
+
+func loadNSFe(chunks []byte) (*nsfFile, error) {
+	init := nsfInit{
+		tracks: 1,
+	}
+	meta := &nsfMetadata{}
+	var data []byte
+	var hasInfo bool
+
+	err := readNSFeChunks(chunks, func(id string, body []byte) error {
+		switch id {
+		case "INFO":
+			if len(body) < 8 {
+				return errors.New("NSFe INFO chunk is too short")
+			}
+			hasInfo = true
+			init.loadAddress = binary.LittleEndian.Uint16(body[0:])
+			init.initAddress = binary.LittleEndian.Uint16(body[2:])
+			init.playAddress = binary.LittleEndian.Uint16(body[4:])
+			init.expansion = body[7]
+			if len(body) > 8 {
+				init.tracks = body[8]
+			}
+			if len(body) > 9 {
+				// Unlike plain NSF, the starting track is zero-based
+				init.startingTrack = int(body[9])
+			}
+		case "DATA":
+			data = body
+		case "BANK":
+			copy(init.bankswitch[:], body)
+		case "RATE":
+			if len(body) >= 2 {
+				init.speed = binary.LittleEndian.Uint16(body)
+			}
+		default:
+			return meta.parseChunk(id, body)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !hasInfo {
+		return nil, errors.New("NSFe file is missing INFO chunk")
+	}
+	if data == nil {
+		return nil, errors.New("NSFe file is missing DATA chunk")
+	}
+
+	return newNSFFile(data, init, meta)
+}
+
+// nsfInit holds the parameters needed to start playing an NSF file, from either format.
+type nsfInit struct {
+	tracks        byte
+	startingTrack int
+	loadAddress,
+	initAddress,
+	playAddress uint16
+	bankswitch [8]byte
+	speed      uint16
+	expansion  byte
+}
+
+func newNSFFile(data []byte, init nsfInit, meta *nsfMetadata) (*nsfFile, error) {
+	if init.tracks == 0 {
+		return nil, errors.New("NSF file contains no songs")
+	}
+	if init.startingTrack < 0 || init.startingTrack >= int(init.tracks) {
+		return nil, fmt.Errorf("invalid NSF starting song %d", init.startingTrack+1)
+	}
+	if unsupported := NSFExpansion(init.expansion) &^ supportedNSFExpansion; unsupported != 0 {
+		logrus.Warnf("NSF expansion audio %#x is not supported, and will not be heard", byte(unsupported))
+	}
+
+	info, err := meta.info(int(init.tracks), NSFExpansion(init.expansion))
+	if err != nil {
+		return nil, err
+	}
+
+	speed := init.speed
+	if speed == 0 {
+		speed = defaultNSFSpeed
+	}
+
+	cart, err := cartridge.NewNSF(cartridge.NSFConfig{
+		Data:        data,
+		LoadAddress: init.loadAddress,
+		InitAddress: init.initAddress,
+		PlayAddress: init.playAddress,
+		Bankswitch:  init.bankswitch,
+		PlaySpeed:   speed,
+		Expansion:   init.expansion,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &nsfFile{
+		cartridge:     cart,
+		info:          info,
+		startingTrack: init.startingTrack,
+	}, nil
+}
+
+// readNSFeChunks calls handle with the ID and body of each chunk, until the end of the data or an
+// NEND chunk.
+func readNSFeChunks(data []byte, handle func(id string, body []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return errors.New("truncated NSFe chunk header")
+		}
+		length := binary.LittleEndian.Uint32(data)
+		id := string(data[4:8])
+		data = data[8:]
+		if uint64(length) > uint64(len(data)) {
+			return fmt.Errorf("truncated NSFe chunk %q", id)
+		}
+		if id == "NEND" {
+			return nil
+		}
+		if err := handle(id, data[:length]); err != nil {
+			return err
+		}
+		data = data[length:]
+	}
+	return nil
+}
+
+// nsfMetadata accumulates the optional metadata chunks shared by NSFe and NSF2 files.
+type nsfMetadata struct {
+	// auth holds the title, artist, copyright and ripper
+	auth,
+	names []string
+	durations,
+	fades []int32
+	playlist []byte
+}
+
+func (m *nsfMetadata) parseChunk(id string, body []byte) error {
+	switch id {
+	case "auth":
+		m.auth = nsfStrings(body)
+	case "tlbl":
+		m.names = nsfStrings(body)
+	case "time":
+		m.durations = nsfTimes(body)
+	case "fade":
+		m.fades = nsfTimes(body)
+	case "plst":
+		m.playlist = body
+	default:
+		// Chunks starting with an uppercase letter are required to play the file correctly
+		if id[0] >= 'A' && id[0] <= 'Z' {
+			return fmt.Errorf("unsupported NSFe chunk %q", id)
+		}
+	}
+	return nil
+}
+
+func (m *nsfMetadata) info(tracks int, expansion NSFExpansion) (*NSFInfo, error) {
+	info := &NSFInfo{
+		Expansion: expansion,
+		Tracks:    make([]NSFTrack, tracks),
+	}
+	auth := make([]string, 4)
+	copy(auth, m.auth)
+	info.Title, info.Artist, info.Copyright, info.Ripper = auth[0], auth[1], auth[2], auth[3]
+
+	for i := range info.Tracks {
+		track := &info.Tracks[i]
+		if i < len(m.names) {
+			track.Name = m.names[i]
+		}
+		// Negative times indicate the default should be used
+		if i < len(m.durations) && m.durations[i] > 0 {
+			track.Duration = time.Duration(m.durations[i]) * time.Millisecond
+		}
+		if i < len(m.fades) && m.fades[i] > 0 {
+			track.Fade = time.Duration(m.fades[i]) * time.Millisecond
+		}
+	}
+
+	if len(m.playlist) == 0 {
+		for i := 0; i < tracks; i++ {
+			info.Playlist = append(info.Playlist, i)
+		}
+		return info, nil
+	}
+	for _, track := range m.playlist {
+		if int(track) >= tracks {
+			return nil, fmt.Errorf("NSF playlist contains invalid track %d", track)
+		}
+		info.Playlist = append(info.Playlist, int(track))
+	}
+	return info, nil
+}
+
+// nsfString reads a null-terminated string.
+func nsfString(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
+
+// nsfStrings reads a sequence of null-terminated strings.
+func nsfStrings(data []byte) []string {
+	var strs []string
+	for len(data) > 0 {
+		str := nsfString(data)
+		strs = append(strs, str)
+		if len(str) >= len(data) {
+			break
+		}
+		data = data[len(str)+1:]
+	}
+	return strs
+}
+
+// nsfTimes reads a sequence of 32-bit millisecond times.
+func nsfTimes(data []byte) []int32 {
+	times := make([]int32, len(data)/4)
+	for i := range times {
+		times[i] = int32(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return times
+}
@@ -0,0 +1,197 @@
+package gophernes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testNSF builds an NSF file where INIT stores the track number at $11, and PLAY increments $10.
+func testNSF(t *testing.T) []byte {
+	header := nsfHeader{
+		Magic:        nsfMagic,
+		Version:      1,
+		TotalSongs:   3,
+		StartingSong: 2,
+		LoadAddress:  0x8000,
+		InitAddress:  0x8000,
+		PlayAddress:  0x8010,
+		SpeedNTSC:    defaultNSFSpeed,
+	}
+	prg := make([]byte, 0x13)
+	copy(prg, []byte{
+		0x85, 0x11, // STA $11
+		0x60, // RTS
+	})
+	copy(prg[0x10:], []byte{
+		0xE6, 0x10, // INC $10
+		0x60, // RTS
+	})
+
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(prg)
+	return buf.Bytes()
+}
+
+func TestNSFPlayer(t *testing.T) {
+	console, err := NewNSFConsole(bytes.NewReader(testNSF(t)), nil, nil, nil, WithRate(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if console.Tracks() != 3 {
+		t.Fatalf("expected 3 tracks, got %d", console.Tracks())
+	}
+
+	console.RunFrames(60)
+	if track := console.ram[0x11]; track != 1 {
+		t.Errorf("expected INIT to be called with starting track 1, got %d", track)
+	}
+	// PLAY is called at ~60.1Hz, and the PPU also runs at ~60.1Hz
+	if plays := console.ram[0x10]; plays < 59 || plays > 61 {
+		t.Errorf("expected PLAY to be called ~60 times, got %d", plays)
+	}
+
+	if err := console.SelectTrack(2); err != nil {
+		t.Fatal(err)
+	}
+	console.RunFrames(1)
+	if track := console.ram[0x11]; track != 2 {
+		t.Errorf("expected INIT to be called with track 2, got %d", track)
+	}
+	if err := console.SelectTrack(3); err == nil {
+		t.Error("expected error selecting out of range track")
+	}
+}
+
+func nsfeChunk(id string, body []byte) []byte {
+	chunk := make([]byte, 8, 8+len(body))
+	binary.LittleEndian.PutUint32(chunk, uint32(len(body)))
+	copy(chunk[4:], id)
+	return append(chunk, body...)
+}
+
+func nsfeTimes(times ...int32) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, times)
+	return buf.Bytes()
+}
+
+// testNSFe builds an NSFe file with the same program as testNSF, and full metadata.
+func testNSFe(t *testing.T) []byte {
+	nsf := testNSF(t)
+	buf := &bytes.Buffer{}
+	buf.Write(nsfeMagic[:])
+	buf.Write(nsfeChunk("INFO", []byte{
+		0x00, 0x80, // Load
+		0x00, 0x80, // Init
+		0x10, 0x80, // Play
+		0x00, // Region
+		0x00, // Expansion
+		0x03, // Songs
+		0x02, // Starting song
+	}))
+	buf.Write(nsfeChunk("DATA", nsf[binary.Size(nsfHeader{}):]))
+	buf.Write(nsfeChunk("auth", []byte("Title\x00Artist\x00Copyright\x00Ripper\x00")))
+	buf.Write(nsfeChunk("tlbl", []byte("One\x00Two\x00Three\x00")))
+	buf.Write(nsfeChunk("time", nsfeTimes(100, -1, 50)))
+	buf.Write(nsfeChunk("fade", nsfeTimes(50, -1)))
+	buf.Write(nsfeChunk("plst", []byte{2, 0}))
+	buf.Write(nsfeChunk("xtra", []byte{1, 2, 3}))
+	buf.Write(nsfeChunk("NEND", nil))
+	return buf.Bytes()
+}
+
+func TestNSFeMetadata(t *testing.T) {
+	file, err := loadNSF(bytes.NewReader(testNSFe(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.startingTrack != 2 {
+		t.Errorf("expected starting track 2, got %d", file.startingTrack)
+	}
+
+	info := file.info
+	if info.Title != "Title" || info.Artist != "Artist" || info.Copyright != "Copyright" || info.Ripper != "Ripper" {
+		t.Errorf("unexpected authors: %+v", info)
+	}
+	expected := []NSFTrack{
+		{Name: "One", Duration: 100 * time.Millisecond, Fade: 50 * time.Millisecond},
+		{Name: "Two"},
+		{Name: "Three", Duration: 50 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(info.Tracks, expected) {
+		t.Errorf("unexpected tracks:\n%+v\n%+v", info.Tracks, expected)
+	}
+	if !reflect.DeepEqual(info.Playlist, []int{2, 0}) {
+		t.Errorf("unexpected playlist %v", info.Playlist)
+	}
+}
+
+func TestNSFeUnsupportedChunk(t *testing.T) {
+	data := testNSFe(t)
+	// Insert a required chunk that isn't understood, ahead of NEND
+	data = append(data[:len(data)-8], nsfeChunk("ZZZZ", nil)...)
+	if _, err := loadNSF(bytes.NewReader(data)); err == nil {
+		t.Error("expected error loading NSFe with unknown required chunk")
+	}
+}
+
+func TestNSF2Metadata(t *testing.T) {
+	data := testNSF(t)
+	dataLength := len(data) - binary.Size(nsfHeader{})
+	// Version 2, with the program data length set
+	data[0x05] = 2
+	data[0x7D] = byte(dataLength)
+	data = append(data, nsfeChunk("tlbl", []byte("One\x00Two\x00Three\x00"))...)
+	data = append(data, nsfeChunk("NEND", nil)...)
+
+	file, err := loadNSF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := file.info.Tracks[1].Name; name != "Two" {
+		t.Errorf("expected track name %q, got %q", "Two", name)
+	}
+	if !reflect.DeepEqual(file.info.Playlist, []int{0, 1, 2}) {
+		t.Errorf("unexpected default playlist %v", file.info.Playlist)
+	}
+}
+
+func TestNSFAutoAdvance(t *testing.T) {
+	console, err := NewNSFConsole(bytes.NewReader(testNSFe(t)), nil, nil, nil, WithRate(0), WithAutoAdvance(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Track 3 plays for 50ms without a fade, then the playlist moves on to track 1. Note that
+	// RunFrames(n) runs through n+1 frames.
+	console.RunFrames(1)
+	if console.Track() != 2 {
+		t.Errorf("expected track 2 to still be playing, got %d", console.Track())
+	}
+	console.RunFrames(2)
+	if console.Track() != 0 {
+		t.Fatalf("expected to advance to track 0, got %d", console.Track())
+	}
+	if track := console.ram[0x11]; track != 0 {
+		t.Errorf("expected INIT to be called with track 0, got %d", track)
+	}
+
+	// Track 1 plays for 100ms then fades for 50ms, and is the end of the playlist
+	console.RunFrames(6)
+	if gain := console.apu.Gain(); gain <= 0 || gain >= 1 {
+		t.Errorf("expected track to be fading out, got gain %v", gain)
+	}
+	console.RunFrames(3)
+	if console.Track() != 0 {
+		t.Errorf("expected to remain on the last track, got %d", console.Track())
+	}
+	if gain := console.apu.Gain(); gain != 0 {
+		t.Errorf("expected output to be silenced, got gain %v", gain)
+	}
+}